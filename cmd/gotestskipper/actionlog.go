@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mitch000001/go-tools/testskipper"
+)
+
+// actionLog appends a timestamped line per changed test to a file, e.g.
+//
+//	2024-01-01T00:00:00Z skip pkg/foo_test.go:TestBar
+//
+// so that the actions of all runs form an audit trail
+type actionLog struct {
+	path  string
+	clock clock
+}
+
+// Append appends a line for every test in changed, which were changed by
+// action. The file is created if it does not exist.
+func (l *actionLog) Append(action string, changed []testskipper.FuncReport) error {
+	if len(changed) == 0 {
+		return nil
+	}
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	now := l.clock.Now().UTC().Format(time.RFC3339)
+	for _, funcReport := range changed {
+		if _, err := fmt.Fprintf(file, "%s %s %s:%s\n", now, action, funcReport.Position.Filename, funcReport.Name); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// expandArg resolves a command line argument to the paths to process.
+//
+// An argument naming an existing file or directory is always taken
+// literally, even if it contains glob metacharacters. An argument ending in
+// /... is expanded to the directory before it and all directories below
+// containing test files, see packageDirs. Otherwise, if it contains any
+// metacharacters, it is expanded as a glob pattern as understood by
+// filepath.Match, where a ** path element matches any number of
+// directories, see globRecursive. Other arguments are returned unchanged, so
+// that the error of accessing them is reported later on.
+func expandArg(arg string, includeVendor bool) ([]string, error) {
+	if _, err := os.Stat(arg); err == nil {
+		return []string{arg}, nil
+	}
+	if root := strings.TrimSuffix(arg, "/..."); root != arg {
+		return packageDirs(root, includeVendor)
+	}
+	if !strings.ContainsAny(arg, "*?[\\") {
+		return []string{arg}, nil
+	}
+	var matches []string
+	var err error
+	if containsDoubleStar(arg) {
+		matches, err = globRecursive(arg, includeVendor)
+	} else {
+		matches, err = filepath.Glob(arg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", arg, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%s: no files matched", arg)
+	}
+	return matches, nil
+}
+
+// packageDirs returns root and the directories below it which contain test
+// files. Like the go tool, testdata directories and directories starting
+// with . or _ are ignored, as well as vendor directories unless
+// includeVendor is true.
+func packageDirs(root string, includeVendor bool) ([]string, error) {
+	var dirs []string
+	seen := make(map[string]bool)
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			if dir := filepath.Dir(path); strings.HasSuffix(path, "_test.go") && !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+			return nil
+		}
+		if path != root && ignoredDir(entry.Name(), includeVendor) {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// ignoredDir reports whether the directory name is ignored when walking
+// directories recursively
+func ignoredDir(name string, includeVendor bool) bool {
+	if name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+		return true
+	}
+	return name == "vendor" && !includeVendor
+}
+
+// containsDoubleStar reports whether pattern has a ** path element
+func containsDoubleStar(pattern string) bool {
+	for _, element := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if element == "**" {
+			return true
+		}
+	}
+	return false
+}
+
+// globRecursive returns the paths matching pattern, in which a ** path
+// element matches zero or more directories, e.g. **/integration_*_test.go
+// matches the integration tests in the current directory and all
+// directories below. The directories ignored by packageDirs are not
+// searched.
+func globRecursive(pattern string, includeVendor bool) ([]string, error) {
+	elements := strings.Split(filepath.ToSlash(pattern), "/")
+	var rootElements []string
+	for len(elements) > 0 && !strings.ContainsAny(elements[0], "*?[\\") {
+		rootElements = append(rootElements, elements[0])
+		elements = elements[1:]
+	}
+	for _, element := range elements {
+		if _, err := filepath.Match(element, ""); err != nil {
+			return nil, err
+		}
+	}
+	root := filepath.FromSlash(strings.Join(rootElements, "/"))
+	switch {
+	case root == "" && len(rootElements) > 0:
+		root = string(filepath.Separator)
+	case root == "":
+		root = "."
+	}
+	var matches []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		if entry.IsDir() && ignoredDir(entry.Name(), includeVendor) {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if matchElements(elements, strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return matches, err
+}
+
+// matchElements reports whether the path elements match the pattern
+// elements, a ** element matching any number of path elements
+func matchElements(pattern, elements []string) bool {
+	if len(pattern) == 0 {
+		return len(elements) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(elements); i++ {
+			if matchElements(pattern[1:], elements[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(elements) == 0 {
+		return false
+	}
+	matched, _ := filepath.Match(pattern[0], elements[0])
+	return matched && matchElements(pattern[1:], elements[1:])
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestExpandArg(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a_test.go", "b_test.go", "[x]_test.go", "x_test.go"} {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte("package main\n"), 0644); err != nil {
+			panic(err)
+		}
+	}
+
+	tests := []struct {
+		arg      string
+		expected []string
+	}{
+		// literal file with glob metacharacters
+		{path.Join(dir, "[x]_test.go"), []string{path.Join(dir, "[x]_test.go")}},
+		// glob pattern
+		{path.Join(dir, "[ab]_test.go"), []string{path.Join(dir, "a_test.go"), path.Join(dir, "b_test.go")}},
+		// no metacharacters
+		{path.Join(dir, "missing_test.go"), []string{path.Join(dir, "missing_test.go")}},
+	}
+
+	for _, test := range tests {
+		actual, err := expandArg(test.arg, false)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		if strings.Join(actual, ",") != strings.Join(test.expected, ",") {
+			t.Fatalf("Expected %v for %s, got %v\n", test.expected, test.arg, actual)
+		}
+	}
+
+	// No matches
+	_, err = expandArg(path.Join(dir, "*_missing.go"), false)
+
+	if err == nil || !strings.HasSuffix(err.Error(), "no files matched") {
+		t.Fatalf("Expected 'no files matched' error, got %v\n", err)
+	}
+}
+
+func TestExpandArgRecursive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{
+		"a_test.go",
+		"foo/b_test.go",
+		"foo/bar/c_test.go",
+		"foo/bar/d_test.go",
+		"foo/baz/e.go",
+		"foo/testdata/f_test.go",
+		"foo/.hidden/g_test.go",
+		"foo/_ignored/h_test.go",
+		"vendor/x/i_test.go",
+		"z_test.go",
+	} {
+		if err := os.MkdirAll(path.Join(dir, path.Dir(name)), 0755); err != nil {
+			panic(err)
+		}
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte("package main\n"), 0644); err != nil {
+			panic(err)
+		}
+	}
+
+	tests := []struct {
+		arg           string
+		includeVendor bool
+		expected      []string
+	}{
+		{dir + "/...", false, []string{dir, path.Join(dir, "foo"), path.Join(dir, "foo/bar")}},
+		{dir + "/foo/...", false, []string{path.Join(dir, "foo"), path.Join(dir, "foo/bar")}},
+		{dir + "/...", true, []string{dir, path.Join(dir, "foo"), path.Join(dir, "foo/bar"), path.Join(dir, "vendor/x")}},
+		{dir + "/**/*_test.go", false, []string{
+			path.Join(dir, "a_test.go"),
+			path.Join(dir, "foo/b_test.go"),
+			path.Join(dir, "foo/bar/c_test.go"),
+			path.Join(dir, "foo/bar/d_test.go"),
+			path.Join(dir, "z_test.go"),
+		}},
+		{dir + "/foo/**/[cd]_test.go", false, []string{path.Join(dir, "foo/bar/c_test.go"), path.Join(dir, "foo/bar/d_test.go")}},
+		{dir + "/**/vendor/**/*_test.go", true, []string{path.Join(dir, "vendor/x/i_test.go")}},
+	}
+
+	for _, test := range tests {
+		actual, err := expandArg(test.arg, test.includeVendor)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		if strings.Join(actual, ",") != strings.Join(test.expected, ",") {
+			t.Fatalf("Expected %v for %s, got %v\n", test.expected, test.arg, actual)
+		}
+	}
+
+	// No matches below ignored directories
+	_, err = expandArg(path.Join(dir, "**/f_test.go"), false)
+
+	if err == nil || !strings.HasSuffix(err.Error(), "no files matched") {
+		t.Fatalf("Expected 'no files matched' error, got %v\n", err)
+	}
+
+	// Missing root
+	_, err = expandArg(path.Join(dir, "missing/..."), false)
+
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseAge parses an age like 7d or 36h. In addition to the units understood
+// by time.ParseDuration the unit d (days) is supported.
+func parseAge(age string) (time.Duration, error) {
+	if strings.HasSuffix(age, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(age, "d"))
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("invalid age %q", age)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	duration, err := time.ParseDuration(age)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid age %q", age)
+	}
+	return duration, nil
+}
+
+// blameFilter accepts test functions of which at least one line was last
+// modified after cutoff according to git blame
+type blameFilter struct {
+	cutoff time.Time
+	err    error
+}
+
+// Filter implements testskipper.FuncFilter. The first error encountered is
+// recorded in b.err and causes all further functions to be rejected.
+func (b *blameFilter) Filter(fileSet *token.FileSet, funcDecl *ast.FuncDecl) bool {
+	if b.err != nil {
+		return false
+	}
+	if fileSet == nil {
+		b.err = fmt.Errorf("no position information for %s", funcDecl.Name.Name)
+		return false
+	}
+	start := fileSet.Position(funcDecl.Pos())
+	end := fileSet.Position(funcDecl.End())
+	modified, err := lastModified(start.Filename, start.Line, end.Line)
+	if err != nil {
+		b.err = err
+		return false
+	}
+	return !modified.Before(b.cutoff)
+}
+
+// lastModified returns the most recent commit time of the lines from to to
+// of the file at path. Uncommitted lines count as modified now.
+func lastModified(path string, from, to int) (time.Time, error) {
+	cmd := exec.Command("git", "blame", "--porcelain", "-L", fmt.Sprintf("%d,%d", from, to), "--", filepath.Base(path))
+	cmd.Dir = filepath.Dir(path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: git blame: %s", path, strings.TrimSpace(stderr.String()))
+	}
+	var latest time.Time
+	lines := bufio.NewScanner(bytes.NewReader(out))
+	for lines.Scan() {
+		line := lines.Text()
+		if !strings.HasPrefix(line, "committer-time ") {
+			continue
+		}
+		seconds, err := strconv.ParseInt(strings.TrimPrefix(line, "committer-time "), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s: git blame: %v", path, err)
+		}
+		if modified := time.Unix(seconds, 0); modified.After(latest) {
+			latest = modified
+		}
+	}
+	return latest, lines.Err()
+}
+
+// checkGitWorkTree returns an error if path is not located inside a git
+// work tree
+func checkGitWorkTree(path string) error {
+	dir := path
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil || strings.TrimSpace(string(out)) != "true" {
+		return fmt.Errorf("%s: not inside a git work tree", path)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func git(t *testing.T, dir string, date string, args ...string) {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %s\n", args, out)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"7d":  7 * 24 * time.Hour,
+		"0d":  0,
+		"36h": 36 * time.Hour,
+	}
+	for age, expected := range tests {
+		actual, err := parseAge(age)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		if actual != expected {
+			t.Fatalf("Expected %v for %q, got %v\n", expected, age, actual)
+		}
+	}
+
+	for _, age := range []string{"", "d", "-1d", "7 days"} {
+		if _, err := parseAge(age); err == nil {
+			t.Fatalf("Expected an error for %q\n", age)
+		}
+	}
+}
+
+func TestRunNewerThan(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := `package main
+
+import "testing"
+
+func TestOld(t *testing.T) {
+	t.Log("old")
+}
+`
+	filePath := path.Join(dir, "foo_test.go")
+	if err := ioutil.WriteFile(filePath, []byte(src), 0644); err != nil {
+		panic(err)
+	}
+	git(t, dir, "2000-01-01T00:00:00Z", "init", "-q")
+	git(t, dir, "2000-01-01T00:00:00Z", "add", ".")
+	git(t, dir, "2000-01-01T00:00:00Z", "commit", "-q", "-m", "old")
+
+	src += `
+func TestNew(t *testing.T) {
+	t.Log("new")
+}
+`
+	if err := ioutil.WriteFile(filePath, []byte(src), 0644); err != nil {
+		panic(err)
+	}
+	git(t, dir, time.Now().Add(-48*time.Hour).Format(time.RFC3339), "commit", "-q", "-a", "-m", "new")
+
+	var stdout, stderr bytes.Buffer
+	exitCode := Run([]string{"-newer-than", "7d", filePath}, &stdout, &stderr)
+
+	if exitCode != 0 {
+		t.Fatalf("Expected exit code 0, got %d with stderr: %s\n", exitCode, stderr.String())
+	}
+
+	expected := `package main
+
+import "testing"
+
+func TestOld(t *testing.T) {
+	t.Log("old")
+}
+
+// gotestskipper:skip
+func TestNew(t *testing.T) {
+	t.Skip()
+
+	t.Log("new")
+}
+`
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	if replacer.Replace(expected) != replacer.Replace(stdout.String()) {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, stdout.String())
+	}
+
+	// Not a git work tree
+	noGitDir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(noGitDir)
+	noGitPath := path.Join(noGitDir, "foo_test.go")
+	if err := ioutil.WriteFile(noGitPath, []byte(src), 0644); err != nil {
+		panic(err)
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	exitCode = Run([]string{"-newer-than", "7d", noGitPath}, &stdout, &stderr)
+
+	if exitCode != 2 {
+		t.Fatalf("Expected exit code 2, got %d\n", exitCode)
+	}
+	if !strings.Contains(stderr.String(), "not inside a git work tree") {
+		t.Fatalf("Expected git work tree error, got '%s'\n", stderr.String())
+	}
+}
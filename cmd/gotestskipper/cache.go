@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// cacheEntry records the state of a file after it was processed
+type cacheEntry struct {
+	Hash   string `json:"hash"`
+	Action string `json:"action"`
+}
+
+// cache records the files which are already in the state the action of a
+// run would produce, so that later runs with the same action can skip them
+// as long as they are unchanged. It is keyed by absolute path.
+type cache struct {
+	path    string
+	action  string
+	entries map[string]cacheEntry
+}
+
+// loadCache reads the cache file at path for runs of action. A missing file
+// yields an empty cache.
+func loadCache(path, action string) (*cache, error) {
+	c := &cache{path: path, action: action, entries: make(map[string]cacheEntry)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Fresh reports whether the file at path is unchanged since it was recorded
+// for the action of the cache
+func (c *cache) Fresh(path string) bool {
+	entry, ok := c.entries[cacheKey(path)]
+	if !ok || entry.Action != c.action {
+		return false
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return entry.Hash == hashContent(content)
+}
+
+// Stale reports whether the file at path has to be processed, it is used
+// as testskipper.PathFilter
+func (c *cache) Stale(path string) bool {
+	return !c.Fresh(path)
+}
+
+// Record records content as the state of the file at path after the action
+// of the cache, replacing any earlier entry
+func (c *cache) Record(path string, content []byte) {
+	c.entries[cacheKey(path)] = cacheEntry{Hash: hashContent(content), Action: c.action}
+}
+
+// Save writes the cache back to its file
+func (c *cache) Save() error {
+	data, err := json.MarshalIndent(c.entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path, append(data, '\n'), 0644)
+}
+
+func cacheKey(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// outputFlags only control how and where results are written or reported,
+// so they do not change the state a file ends up in
+var outputFlags = map[string]bool{
+	"cache": true, "w": true, "o": true, "force": true, "l": true, "0": true,
+	"d": true, "diff-context": true, "format": true, "summary": true,
+	"progress": true, "log": true, "log-level": true,
+}
+
+// cacheAction describes the action of a run by its name and all explicitly
+// set flags affecting the result, so that runs with different options do
+// not share entries
+func cacheAction(action string, flags *flag.FlagSet) string {
+	var options []string
+	flags.Visit(func(f *flag.Flag) {
+		if !outputFlags[f.Name] {
+			options = append(options, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	sort.Strings(options)
+	return strings.Join(append([]string{action}, options...), " ")
+}
@@ -0,0 +1,15 @@
+package main
+
+import "time"
+
+// clock abstracts the current time, so that time dependent behavior can be
+// tested deterministically
+type clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mitch000001/go-tools/testskipper"
+)
+
+// defaultConfigFile is the config file read from the working directory if
+// no -config is given
+const defaultConfigFile = ".gotestskipper.yaml"
+
+// config holds the settings of a config file
+type config struct {
+	// flags holds the default values of flags, in the order of the file
+	flags []configFlag
+	// exclude holds the patterns of the files not to process, see excludes
+	exclude []string
+}
+
+// configFlag is the default value of the flag name
+type configFlag struct {
+	name  string
+	value string
+	line  int
+}
+
+// readConfig reads the config file at path
+func readConfig(path string) (*config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	c, err := parseConfig(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// parseConfig parses a config file from r. It understands the subset of
+// YAML needed to set flags and to list the excluded files, e.g.
+//
+//	# comment
+//	reason: "flaky on CI"
+//	tight-skip: true
+//	exclude:
+//	  - legacy
+//	  - "*_integration_test.go"
+//
+// Every key but exclude names a flag, whose value is given in the flag's
+// syntax. Values may be double or single quoted. YAML is parsed by hand, as
+// this tool has no dependencies outside of the standard library.
+func parseConfig(r io.Reader) (*config, error) {
+	c := &config{}
+	var list string
+	lines := bufio.NewScanner(r)
+	for number := 1; lines.Scan(); number++ {
+		line := strings.TrimRightFunc(lines.Text(), func(r rune) bool { return r == ' ' || r == '\t' || r == '\r' })
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok && line != trimmed {
+			if list == "" {
+				return nil, fmt.Errorf("line %d: list item outside of a list", number)
+			}
+			value, err := configValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", number, err)
+			}
+			c.exclude = append(c.exclude, value)
+			continue
+		}
+		if line != trimmed {
+			return nil, fmt.Errorf("line %d: unexpected indentation", number)
+		}
+		key, value, found := strings.Cut(trimmed, ":")
+		if !found || key == "" {
+			return nil, fmt.Errorf("line %d: expected <key>: <value>, got %q", number, trimmed)
+		}
+		value, err := configValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", number, err)
+		}
+		list = ""
+		if key == "exclude" {
+			if value != "" {
+				return nil, fmt.Errorf("line %d: exclude must be a list", number)
+			}
+			list = key
+			continue
+		}
+		c.flags = append(c.flags, configFlag{name: key, value: value, line: number})
+	}
+	if err := lines.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// configValue returns the scalar value s without surrounding white space,
+// quotes and trailing comments
+func configValue(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, `"`):
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil || !isComment(s[len(quoted):]) {
+			return "", fmt.Errorf("invalid quoted value %s", s)
+		}
+		return strconv.Unquote(quoted)
+	case strings.HasPrefix(s, "'"):
+		end := strings.Index(s[1:], "'")
+		if end == -1 || !isComment(s[end+2:]) {
+			return "", fmt.Errorf("invalid quoted value %s", s)
+		}
+		return s[1 : end+1], nil
+	}
+	if comment := strings.Index(s, " #"); comment != -1 {
+		s = s[:comment]
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// isComment reports whether s is empty or a comment, apart from white space
+func isComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || strings.HasPrefix(s, "#")
+}
+
+// apply sets the flags of the config which were not set on the command line
+func (c *config) apply(flags *flag.FlagSet) error {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, configFlag := range c.flags {
+		if configFlag.name == "config" || flags.Lookup(configFlag.name) == nil {
+			return fmt.Errorf("line %d: unknown flag %q", configFlag.line, configFlag.name)
+		}
+		if set[configFlag.name] {
+			continue
+		}
+		if err := flags.Set(configFlag.name, configFlag.value); err != nil {
+			return fmt.Errorf("line %d: invalid value %q for flag -%s: %v", configFlag.line, configFlag.value, configFlag.name, err)
+		}
+	}
+	return nil
+}
+
+// excludes reports whether the file at path is excluded by the config, see
+// testskipper.ExcludePaths for the patterns
+func (c *config) excludes(path string) bool {
+	return !testskipper.ExcludePaths(c.exclude...)(path)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	src := `# defaults
+---
+reason: "flaky on \"CI\"" # quoted
+skip-call-pattern: 'testutil.Skip'
+tight-skip: true
+exclude:
+  - legacy
+  - "*_integration_test.go"
+v: false
+`
+	config, err := parseConfig(strings.NewReader(src))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	expectedFlags := []configFlag{
+		{name: "reason", value: `flaky on "CI"`, line: 3},
+		{name: "skip-call-pattern", value: "testutil.Skip", line: 4},
+		{name: "tight-skip", value: "true", line: 5},
+		{name: "v", value: "false", line: 9},
+	}
+	if !reflect.DeepEqual(expectedFlags, config.flags) {
+		t.Fatalf("Expected flags %v, got %v\n", expectedFlags, config.flags)
+	}
+	expectedExclude := []string{"legacy", "*_integration_test.go"}
+	if !reflect.DeepEqual(expectedExclude, config.exclude) {
+		t.Fatalf("Expected exclude %v, got %v\n", expectedExclude, config.exclude)
+	}
+
+	for _, src := range []string{
+		"reason\n",
+		"  - legacy\n",
+		"exclude: legacy\n",
+		"reason: \"flaky\n",
+		"exclude:\n  - legacy\n  reason: flaky\n",
+	} {
+		if _, err := parseConfig(strings.NewReader(src)); err == nil {
+			t.Fatalf("Expected an error for \n`%s`\n", src)
+		}
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	reason := flags.String("reason", "", "")
+	write := flags.Bool("w", false, "")
+	unskip := flags.Bool("u", false, "")
+	if err := flags.Parse([]string{"-u=false"}); err != nil {
+		panic(err)
+	}
+	defaults := &config{flags: []configFlag{{"reason", "flaky", 1}, {"w", "true", 2}, {"u", "true", 3}}}
+
+	err := defaults.apply(flags)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if *reason != "flaky" || !*write || *unskip {
+		t.Fatalf("Expected reason 'flaky', w and not u, got '%s', %t, %t\n", *reason, *write, *unskip)
+	}
+
+	for _, invalid := range []*config{
+		{flags: []configFlag{{"missing", "", 1}}},
+		{flags: []configFlag{{"w", "maybe", 1}}},
+	} {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.Bool("w", false, "")
+		if err := invalid.apply(flags); err == nil {
+			t.Fatalf("Expected an error for %v\n", invalid.flags)
+		}
+	}
+}
+
+func TestConfigExcludes(t *testing.T) {
+	config := &config{exclude: []string{"legacy", "*_integration_test.go", "foo/bar_test.go"}}
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"legacy/foo_test.go", true},
+		{"pkg/legacy/sub/foo_test.go", true},
+		{"pkg/db_integration_test.go", true},
+		{"./pkg/foo/bar_test.go", true},
+		{"pkg/foo_test.go", false},
+		{"legacyfoo/foo_test.go", false},
+		{"foo/baz_test.go", false},
+	}
+	for _, test := range tests {
+		if actual := config.excludes(test.path); actual != test.expected {
+			t.Fatalf("Expected %s to be excluded %t, got %t\n", test.path, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// printCounts prints the number of tests matched by -count per package,
+// i.e. directory, and in total
+func (c *command) printCounts() {
+	packages := make([]string, 0, len(c.counts.Packages))
+	for pkg := range c.counts.Packages {
+		packages = append(packages, pkg)
+	}
+	sort.Strings(packages)
+	for _, pkg := range packages {
+		fmt.Fprintf(c.stdout, "%s: %s\n", pkg, countTests(c.counts.Packages[pkg]))
+	}
+	fmt.Fprintf(c.stdout, "total: %s in %d files, %d skipped\n", countTests(c.counts.Total), len(c.counts.Files), c.counts.Skipped)
+}
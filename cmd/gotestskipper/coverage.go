@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// coveragePolicyZero selects the test files of source files without any
+// covered statement
+const coveragePolicyZero = "zero"
+
+var coveragePolicies = map[string]bool{coveragePolicyZero: true}
+
+// coverage holds the number of covered statements per file of a coverage
+// profile, keyed by the import path of the file, e.g.
+// github.com/mitch000001/go-tools/testskipper/apply.go
+type coverage struct {
+	covered map[string]int
+}
+
+// readCoverage reads the coverage profile at path, as written by
+// go test -coverprofile
+func readCoverage(path string) (*coverage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	c, err := parseCoverage(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// parseCoverage parses a coverage profile from r. Apart from the mode line,
+// each line describes a block of a file, e.g.
+//
+//	github.com/foo/bar/bar.go:3.24,5.2 1 0
+//
+// with the file, the block, the number of statements and the count. Only
+// the profile format is parsed here, as golang.org/x/tools/cover is not a
+// dependency of this tool.
+func parseCoverage(r io.Reader) (*coverage, error) {
+	c := &coverage{covered: make(map[string]int)}
+	lines := bufio.NewScanner(r)
+	for number := 1; lines.Scan(); number++ {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" || number == 1 && strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		colon := strings.LastIndex(line, ":")
+		fields := strings.Fields(line[colon+1:])
+		if colon < 0 || len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: invalid block %q", number, line)
+		}
+		statements, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid number of statements %q", number, fields[1])
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid count %q", number, fields[2])
+		}
+		if count == 0 {
+			statements = 0
+		}
+		c.covered[line[:colon]] += statements
+	}
+	if err := lines.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// commonSuffix returns the number of trailing elements the slash separated
+// paths a and b have in common
+func commonSuffix(a, b string) int {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	n := 0
+	for n < len(as) && n < len(bs) && as[len(as)-1-n] == bs[len(bs)-1-n] {
+		n++
+	}
+	return n
+}
+
+// ZeroCovered reports whether the source file belonging to the test file at
+// path, e.g. foo.go for foo_test.go, is part of the profile without any
+// covered statement. It is used as testskipper.PathFilter.
+//
+// The import paths of a profile and the local paths only have a suffix in
+// common, so the file of the profile sharing the longest suffix of the
+// directory with the source file is taken, but at least its directory and
+// name. Profile files matching equally well all need to be uncovered.
+func (c *coverage) ZeroCovered(testPath string) bool {
+	abs, err := filepath.Abs(testPath)
+	if err != nil {
+		abs = testPath
+	}
+	source := strings.TrimSuffix(filepath.ToSlash(abs), "_test.go") + ".go"
+	best, covered := 1, 0
+	for name, statements := range c.covered {
+		n := commonSuffix(source, name)
+		switch {
+		case n > best:
+			best, covered = n, statements
+		case n == best && n > 1:
+			covered += statements
+		}
+	}
+	return best > 1 && covered == 0
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadCoverage(t *testing.T) {
+	coverage, err := readCoverage("testdata/cover.out")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		// the profile also has a covered testdata/uncovered.go of another
+		// package, which shares a shorter suffix with the local path
+		{"testdata/uncovered_test.go", true},
+		{"testdata/covered_test.go", false},
+		{"other/uncovered_test.go", false},
+		{"testdata/unknown_test.go", false},
+	}
+	for _, test := range tests {
+		if actual := coverage.ZeroCovered(test.path); actual != test.expected {
+			t.Fatalf("Expected %s to be zero covered %t, got %t\n", test.path, test.expected, actual)
+		}
+	}
+
+	_, err = parseCoverage(strings.NewReader("mode: set\ngithub.com/example/project/foo.go:3.24,5.2 1 x\n"))
+
+	if err == nil {
+		t.Fatalf("Expected an error for an invalid count\n")
+	}
+}
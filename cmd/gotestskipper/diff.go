@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const defaultDiffContext = 3
+
+type diffOpKind int
+
+const (
+	diffEqual diffOpKind = iota
+	diffDelete
+	diffInsert
+)
+
+type diffOp struct {
+	kind diffOpKind
+	line string
+}
+
+// splitLines splits src into lines, keeping the trailing newline of each line
+func splitLines(src []byte) []string {
+	if len(src) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(src), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes a shortest edit script transforming a into b using
+// Myers' algorithm
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, offset, d)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrack(trace [][]int, a, b []string, offset, d int) []diffOp {
+	var ops []diffOp
+	x, y := len(a), len(b)
+	for ; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{diffEqual, a[x]})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{diffInsert, b[y]})
+		} else {
+			x--
+			ops = append(ops, diffOp{diffDelete, a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp{diffEqual, a[x]})
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// unifiedDiff writes a unified diff between original and modified to w,
+// using context lines of surrounding context per hunk. It returns false if
+// the inputs do not differ.
+func unifiedDiff(w io.Writer, path string, original, modified []byte, context int) (bool, error) {
+	if bytes.Equal(original, modified) {
+		return false, nil
+	}
+	ops := diffLines(splitLines(original), splitLines(modified))
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "--- %s.orig\n+++ %s\n", path, path)
+	for start := 0; start < len(ops); {
+		// find the next change
+		for start < len(ops) && ops[start].kind == diffEqual {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		// extend the hunk while changes are within 2*context lines
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != diffEqual {
+				end = i + 1
+				continue
+			}
+			if i-end >= 2*context {
+				break
+			}
+		}
+		hunkStart := start - context
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		hunkEnd := end + context
+		if hunkEnd > len(ops) {
+			hunkEnd = len(ops)
+		}
+		writeHunk(&buffer, ops, hunkStart, hunkEnd)
+		start = hunkEnd
+	}
+	_, err := io.Copy(w, &buffer)
+	return true, err
+}
+
+func writeHunk(w io.Writer, ops []diffOp, start, end int) {
+	origLine, modLine := 1, 1
+	for _, op := range ops[:start] {
+		if op.kind != diffInsert {
+			origLine++
+		}
+		if op.kind != diffDelete {
+			modLine++
+		}
+	}
+	var origCount, modCount int
+	for _, op := range ops[start:end] {
+		if op.kind != diffInsert {
+			origCount++
+		}
+		if op.kind != diffDelete {
+			modCount++
+		}
+	}
+	if origCount == 0 {
+		origLine--
+	}
+	if modCount == 0 {
+		modLine--
+	}
+	fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", origLine, origCount, modLine, modCount)
+	for _, op := range ops[start:end] {
+		prefix := " "
+		switch op.kind {
+		case diffDelete:
+			prefix = "-"
+		case diffInsert:
+			prefix = "+"
+		}
+		line := op.line
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n\\ No newline at end of file\n"
+		}
+		fmt.Fprint(w, prefix+line)
+	}
+}
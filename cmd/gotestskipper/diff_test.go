@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	original := "package main\n\nfunc TestFoo(t *testing.T) {\n\tfoo()\n}\n"
+	modified := "package main\n\nfunc TestFoo(t *testing.T) {\n\tt.Skip()\n\n\tfoo()\n}\n"
+
+	var buffer bytes.Buffer
+	changed, err := unifiedDiff(&buffer, "foo_test.go", []byte(original), []byte(modified), defaultDiffContext)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if !changed {
+		t.Fatal("Expected changed to be true")
+	}
+
+	expected := "--- foo_test.go.orig\n" +
+		"+++ foo_test.go\n" +
+		"@@ -1,5 +1,7 @@\n" +
+		" package main\n" +
+		" \n" +
+		" func TestFoo(t *testing.T) {\n" +
+		"+\tt.Skip()\n" +
+		"+\n" +
+		" \tfoo()\n" +
+		" }\n"
+	if buffer.String() != expected {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, buffer.String())
+	}
+
+	// No changes
+	buffer.Reset()
+	changed, err = unifiedDiff(&buffer, "foo_test.go", []byte(original), []byte(original), defaultDiffContext)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if changed || buffer.Len() != 0 {
+		t.Fatalf("Expected no diff, got '%s'\n", buffer.String())
+	}
+}
+
+func TestUnifiedDiffContext(t *testing.T) {
+	original := "a\nb\nc\nd\ne\nf\ng\nh\n"
+	modified := "a\nb\nc\nd\nE\nf\ng\nh\n"
+
+	tests := []struct {
+		context  int
+		expected string
+	}{
+		{0, "@@ -5,1 +5,1 @@\n-e\n+E\n"},
+		{1, "@@ -4,3 +4,3 @@\n d\n-e\n+E\n f\n"},
+		{3, "@@ -2,7 +2,7 @@\n b\n c\n d\n-e\n+E\n f\n g\n h\n"},
+	}
+
+	for _, test := range tests {
+		var buffer bytes.Buffer
+		_, err := unifiedDiff(&buffer, "foo_test.go", []byte(original), []byte(modified), test.context)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		expected := "--- foo_test.go.orig\n+++ foo_test.go\n" + test.expected
+		if buffer.String() != expected {
+			t.Fatalf("Expected for context %d\n`%s`\n\n, got \n`%s`\n", test.context, expected, buffer.String())
+		}
+	}
+}
+
+func TestRunDiffContext(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	exitCode := Run([]string{"-d", "-diff-context", "-1", "foo_test.go"}, &stdout, &stderr)
+
+	if exitCode != exitCodeError {
+		t.Fatalf("Expected exit code %d, got %d\n", exitCodeError, exitCode)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"sort"
+
+	"github.com/mitch000001/go-tools/testskipper"
+)
+
+// testDecl is a test function declaration collected by -check-dupes
+type testDecl struct {
+	name     string
+	pkg      string
+	dir      string
+	position token.Position
+}
+
+// collectTests adds the test functions of report to the ones checked for
+// duplicate names
+func (c *command) collectTests(report *testskipper.Report) {
+	for _, funcReport := range report.Funcs {
+		c.tests = append(c.tests, testDecl{
+			name:     funcReport.Name,
+			pkg:      funcReport.Package,
+			dir:      filepath.Dir(funcReport.Position.Filename),
+			position: funcReport.Position,
+		})
+	}
+}
+
+// reportDuplicates prints every collected test declared with the name of
+// an earlier one, telling whether the earlier one is in the same package
+func (c *command) reportDuplicates() {
+	sort.Slice(c.tests, func(i, j int) bool {
+		a, b := c.tests[i], c.tests[j]
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if a.position.Filename != b.position.Filename {
+			return a.position.Filename < b.position.Filename
+		}
+		return a.position.Offset < b.position.Offset
+	})
+	first := 0
+	for i := 1; i < len(c.tests); i++ {
+		if c.tests[i].name != c.tests[first].name {
+			first = i
+			continue
+		}
+		test, other := c.tests[i], c.tests[first]
+		if test.dir == other.dir && test.pkg == other.pkg {
+			fmt.Fprintf(c.stdout, "%s: %s: also declared in the same package at %s\n", test.position, test.name, other.position)
+		} else {
+			fmt.Fprintf(c.stdout, "%s: %s: also declared in package %s at %s\n", test.position, test.name, other.pkg, other.position)
+		}
+		c.changesFound()
+	}
+}
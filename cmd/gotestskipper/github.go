@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitch000001/go-tools/testskipper"
+)
+
+const formatGitHub = "github"
+
+var actionParticiples = map[string]string{
+	"skip":      "skipped",
+	"unskip":    "unskipped",
+	"normalize": "normalized",
+	"rules":     "changed",
+	"fix":       "fixed",
+	"prune":     "pruned",
+}
+
+// writeGitHubAnnotations prints a GitHub Actions warning annotation for
+// every test function in report which was or would be changed. In list or
+// diff mode, unchanged test functions which are already skipped are
+// annotated as well.
+func (c *command) writeGitHubAnnotations(report *testskipper.Report) {
+	for _, funcReport := range report.Funcs {
+		var message string
+		switch {
+		case funcReport.Changed && c.write:
+			message = fmt.Sprintf("%s was %s", funcReport.Name, actionParticiples[c.action])
+		case funcReport.Changed:
+			message = fmt.Sprintf("%s would be %s", funcReport.Name, actionParticiples[c.action])
+		case funcReport.Skipped && (c.list || c.diff):
+			message = fmt.Sprintf("%s is skipped", funcReport.Name)
+		default:
+			continue
+		}
+		fmt.Fprintf(c.stdout, "::warning file=%s,line=%d::%s\n",
+			escapeAnnotationProperty(funcReport.Position.Filename),
+			funcReport.Position.Line,
+			escapeAnnotationData(message),
+		)
+	}
+}
+
+var annotationDataEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+var annotationPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+
+func escapeAnnotationData(s string) string {
+	return annotationDataEscaper.Replace(s)
+}
+
+func escapeAnnotationProperty(s string) string {
+	return annotationPropertyEscaper.Replace(s)
+}
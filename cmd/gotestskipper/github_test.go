@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestRunFormatGitHub(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	src := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+
+func TestBar(t *testing.T) {
+	t.Skip()
+}
+`
+	filePath := path.Join(dir, "foo_test.go")
+	if err := ioutil.WriteFile(filePath, []byte(src), 0644); err != nil {
+		panic(err)
+	}
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{
+			[]string{"-format", "github", filePath},
+			"::warning file=" + filePath + ",line=5::TestFoo would be skipped\n",
+		},
+		{
+			[]string{"-format", "github", "-u", filePath},
+			"::warning file=" + filePath + ",line=9::TestBar would be unskipped\n",
+		},
+		{
+			[]string{"-format", "github", "-u", "-l", filePath},
+			filePath + "\n" +
+				"::warning file=" + filePath + ",line=9::TestBar would be unskipped\n",
+		},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		Run(test.args, &stdout, &stderr)
+
+		if stdout.String() != test.expected {
+			t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", test.expected, stdout.String())
+		}
+	}
+
+	// Invalid format
+	var stdout, stderr bytes.Buffer
+	exitCode := Run([]string{"-format", "foo", filePath}, &stdout, &stderr)
+
+	if exitCode != 2 {
+		t.Fatalf("Expected exit code 2, got %d\n", exitCode)
+	}
+}
+
+func TestRunSkipCallPattern(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	src := `package main
+
+import (
+	"testing"
+
+	"example.com/testutil"
+)
+
+func TestFoo(t *testing.T) {
+	testutil.Skip(t, "flaky")
+	t.Log("foo")
+}
+`
+	filePath := path.Join(dir, "foo_test.go")
+	if err := ioutil.WriteFile(filePath, []byte(src), 0644); err != nil {
+		panic(err)
+	}
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{
+			[]string{"-format", "github", "-u", "-l", filePath},
+			"",
+		},
+		{
+			[]string{"-format", "github", "-u", "-l", "-skip-call-pattern", "testutil.Skip", filePath},
+			filePath + "\n" +
+				"::warning file=" + filePath + ",line=9::TestFoo would be unskipped\n",
+		},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		Run(test.args, &stdout, &stderr)
+
+		if stdout.String() != test.expected {
+			t.Fatalf("Expected for %v\n`%s`\n\n, got \n`%s`\n", test.args, test.expected, stdout.String())
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	exitCode := Run([]string{"-u", "-skip-call-pattern", "testutil.Skip()", filePath}, &stdout, &stderr)
+
+	if exitCode != exitCodeError {
+		t.Fatalf("Expected exit code %d for an invalid pattern, got %d\n", exitCodeError, exitCode)
+	}
+}
+
+func TestEscapeAnnotationProperty(t *testing.T) {
+	actual := escapeAnnotationProperty("a,b:c%d\n")
+	expected := "a%2Cb%3Ac%25d%0A"
+	if actual != expected {
+		t.Fatalf("Expected '%s', got '%s'\n", expected, actual)
+	}
+}
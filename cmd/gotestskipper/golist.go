@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// goTestList runs go test -list with pattern in the package containing path
+// and returns the names of the listed tests
+func goTestList(path, pattern string) ([]string, error) {
+	dir := path
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+	cmd := exec.Command("go", "test", "-list", pattern, ".")
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = strings.TrimSpace(string(out))
+		}
+		return nil, fmt.Errorf("%s: go test -list: %s", path, message)
+	}
+	return parseTestList(bytes.NewReader(out))
+}
+
+// parseTestList parses the output of go test -list, which prints one test
+// name per line followed by a summary line per package such as
+//
+//	ok  	github.com/example/project	0.004s
+func parseTestList(r io.Reader) ([]string, error) {
+	var names []string
+	lines := bufio.NewScanner(r)
+	for lines.Scan() {
+		fields := strings.Fields(lines.Text())
+		switch {
+		case len(fields) == 0:
+			continue
+		case len(fields) == 1 && token.IsIdentifier(fields[0]):
+			names = append(names, fields[0])
+		case fields[0] == "ok" || fields[0] == "?":
+			continue
+		default:
+			return nil, fmt.Errorf("unexpected go test -list output: %q", lines.Text())
+		}
+	}
+	return names, lines.Err()
+}
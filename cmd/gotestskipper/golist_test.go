@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTestList(t *testing.T) {
+	fixture, err := os.Open("testdata/go_test_list.txt")
+	if err != nil {
+		panic(err)
+	}
+	defer fixture.Close()
+
+	names, err := parseTestList(fixture)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	expected := []string{"TestFoo", "TestBar", "ExampleFoo"}
+	if !reflect.DeepEqual(expected, names) {
+		t.Fatalf("Expected names to equal\n%v\n\tgot\n%v\n", expected, names)
+	}
+
+	_, err = parseTestList(strings.NewReader("TestFoo\nFAIL\tgithub.com/example/project [build failed]\n"))
+
+	if err == nil {
+		t.Fatalf("Expected an error for unexpected output\n")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/mitch000001/go-tools/testskipper"
+)
+
+const formatJSON = "json"
+
+// jsonFile is the summary of a processed file printed by -format json
+type jsonFile struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`
+	// Functions are the names of the test functions which were or would
+	// be changed
+	Functions []string `json:"functions"`
+	Changed   bool     `json:"changed"`
+	// Imports are the imports which were or would be added to or removed
+	// from the file
+	Imports []jsonImport `json:"imports"`
+}
+
+// jsonImport is an import change of a file printed by -format json
+type jsonImport struct {
+	Path string `json:"path"`
+	// Change is either "added" or "removed"
+	Change string `json:"change"`
+}
+
+// collectJSON adds the summaries of the files in pathWriter, with the test
+// functions changed according to report, to the ones printed by writeJSON
+func (c *command) collectJSON(pathWriter testskipper.PathWriter, report *testskipper.Report) {
+	functions := make(map[string][]string)
+	for _, funcReport := range report.Changed() {
+		functions[funcReport.Position.Filename] = append(functions[funcReport.Position.Filename], funcReport.Name)
+	}
+	imports := make(map[string][]jsonImport)
+	for _, change := range report.Imports {
+		jsonChange := jsonImport{Path: change.Path, Change: "added"}
+		if change.Removed {
+			jsonChange.Change = "removed"
+		}
+		imports[change.Filename] = append(imports[change.Filename], jsonChange)
+	}
+	for _, path := range pathWriter.Paths() {
+		names := functions[path]
+		if names == nil {
+			names = []string{}
+		}
+		changes := imports[path]
+		if changes == nil {
+			changes = []jsonImport{}
+		}
+		c.jsonFiles = append(c.jsonFiles, jsonFile{
+			Path:      path,
+			Action:    c.action,
+			Functions: names,
+			Changed:   len(names) > 0,
+			Imports:   changes,
+		})
+	}
+}
+
+// writeJSON prints the collected summaries as a JSON array to stdout
+func (c *command) writeJSON() error {
+	files := c.jsonFiles
+	if files == nil {
+		files = []jsonFile{}
+	}
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(files)
+}
@@ -1,39 +1,154 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"go/ast"
+	"go/build"
+	"go/parser"
 	"go/scanner"
+	"go/token"
 	"io"
+	"io/ioutil"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/mitch000001/go-tools/testskipper"
 )
 
-var (
-	write    = flag.Bool("w", false, "write result to (source) file instead of stdout")
-	unskip   = flag.Bool("u", false, "unskips all skipped tests instead of skipping them")
-	exitCode = 0
+const (
+	exitCodeClean   = 0
+	exitCodeChanges = 1
+	exitCodeError   = 2
 )
 
-func usage() {
-	fmt.Fprintf(os.Stderr, "usage: test_skipper [flags] [path ...]\n")
-	flag.PrintDefaults()
-	os.Exit(2)
+func usage(flags *flag.FlagSet, w io.Writer) func() {
+	return func() {
+		fmt.Fprintf(w, "usage: test_skipper [flags] [path ...]\n")
+		fmt.Fprintf(w, "       test_skipper validate [path ...]\n")
+		fmt.Fprintf(w, "\nA path naming an existing file or directory is taken literally,\n")
+		fmt.Fprintf(w, "a path ending in /... is expanded to all package directories below,\n")
+		fmt.Fprintf(w, "otherwise it is expanded as a glob pattern, in which ** matches any\n")
+		fmt.Fprintf(w, "number of directories. Without a path, or for the path -, the\n")
+		fmt.Fprintf(w, "source is read from stdin and the result written to stdout.\n")
+		fmt.Fprintf(w, "\nThe exit status is 0 on success, also if no test matched, 1 if with\n")
+		fmt.Fprintf(w, "-l or -d any file would change, with -check-dupes any duplicate was\n")
+		fmt.Fprintf(w, "found or validate found any inconsistency, and 2 on any error.\n\n")
+		flags.PrintDefaults()
+	}
 }
 
 type OutputStrategy struct {
 	PathWriter testskipper.PathWriter
 }
 
+// WriteToFile writes the content of all buffers back to their files in the
+// order of their paths, keeping the mode of each file. Each file is replaced
+// atomically, so that a failing write leaves the original intact.
 func (o *OutputStrategy) WriteToFile() error {
-	for path, buffer := range o.PathWriter {
-		file, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0666)
+	for _, path := range o.PathWriter.Paths() {
+		if err := writeFileAtomic(path, o.PathWriter[path]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteToFileWithBackup is like WriteToFile, but first saves the original
+// content of each file to <path>.orig, which is replaced atomically as well.
+// A file is only overwritten after its backup was written. Files whose
+// content does not change are neither written nor backed up.
+func (o *OutputStrategy) WriteToFileWithBackup() error {
+	for _, path := range o.PathWriter.Paths() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		original, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		modified, err := ioutil.ReadAll(o.PathWriter[path])
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(original, modified) {
+			continue
+		}
+		if err := writeFileAtomicMode(path+".orig", bytes.NewReader(original), info.Mode().Perm()); err != nil {
+			return err
+		}
+		if err := writeFileAtomicMode(path, bytes.NewReader(modified), info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic writes the content of r into a temporary file in the
+// directory of the existing file at path and renames it over the file
+// after a successful write. The temporary file gets the mode of the file.
+func writeFileAtomic(path string, r io.Reader) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomicMode(path, r, info.Mode().Perm())
+}
+
+// writeFileAtomicMode is like writeFileAtomic, but the file at path need not
+// exist and gets the given mode
+func writeFileAtomicMode(path string, r io.Reader, mode os.FileMode) error {
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, r)
+	if err == nil {
+		err = file.Chmod(mode)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return nil
+}
+
+// WriteToDir writes the content of all buffers into dir, keeping the path of
+// each file relative to the working directory. Existing files are only
+// overwritten if force is true.
+func (o *OutputStrategy) WriteToDir(dir string, force bool) error {
+	for _, path := range o.PathWriter.Paths() {
+		buffer := o.PathWriter[path]
+		target, err := outputPath(dir, path)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(target); err == nil && !force {
+			return fmt.Errorf("%s: file exists, use -force to overwrite", target)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+			return err
+		}
+		file, err := os.Create(target)
 		if err != nil {
 			return err
 		}
 		_, err = io.Copy(file, buffer)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
 		if err != nil {
 			return err
 		}
@@ -41,9 +156,33 @@ func (o *OutputStrategy) WriteToFile() error {
 	return nil
 }
 
+// outputPath returns the path of the copy of path within dir. Paths outside
+// of the working directory are placed in dir by their absolute path.
+func outputPath(dir, path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = strings.TrimPrefix(abs, filepath.VolumeName(abs))
+	}
+	return filepath.Join(dir, rel), nil
+}
+
 func (o *OutputStrategy) WriteToStdout() error {
-	for _, buffer := range o.PathWriter {
-		_, err := io.Copy(os.Stdout, buffer)
+	return o.WriteToOutput(os.Stdout)
+}
+
+// WriteToOutput writes the content of all buffers to w in the order of
+// their paths
+func (o *OutputStrategy) WriteToOutput(w io.Writer) error {
+	for _, path := range o.PathWriter.Paths() {
+		_, err := io.Copy(w, o.PathWriter[path])
 		if err != nil {
 			return err
 		}
@@ -51,73 +190,977 @@ func (o *OutputStrategy) WriteToStdout() error {
 	return nil
 }
 
+const stubSkipReason = "not implemented"
+
+var skipForms = map[string]testskipper.SkipForm{
+	"skip":    testskipper.SkipFormSkip,
+	"skipnow": testskipper.SkipFormSkipNow,
+}
+
+var skipPositions = map[string]testskipper.SkipPosition{
+	"top":            testskipper.SkipPositionTop,
+	"after-parallel": testskipper.SkipPositionAfterParallel,
+	"after-cleanup":  testskipper.SkipPositionAfterCleanup,
+}
+
+// command holds the configuration and state of a single invocation
+type command struct {
+	write           bool
+	outputDir       string
+	tarFile         string
+	force           bool
+	unskip          bool
+	allSkips        bool
+	skipCallPattern string
+	skipCalls       []testskipper.SkipCall
+	template        string
+	skipTemplate    *testskipper.SkipTemplate
+	unskipNote      bool
+	unskipNested    bool
+	reason          string
+	afterCleanup    bool
+	tightSkip       bool
+	skipParallel    bool
+	backup          bool
+	fuzz            bool
+	afterSeeds      bool
+	bench           bool
+	benchPrefix     string
+	strict          bool
+	list            bool
+	nullSeparated   bool
+	diff            bool
+	diffContext     int
+	format          string
+	jsonFormat      bool
+	jsonFiles       []jsonFile
+	action          string
+	newerThan       string
+	fromGoList      string
+	fromFile        string
+	fromCoverage    string
+	coveragePolicy  string
+	coverage        *coverage
+	runPattern      string
+	runFilter       *regexp.Regexp
+	subtestPattern  string
+	names           *nameSet
+	logFormat       string
+	logLevel        string
+	paramType       string
+	nameParam       string
+	suite           string
+	normalize       string
+	fixExisting     bool
+	canonical       string
+	rulesFile       string
+	configFile      string
+	exclude         []string
+	prefixes        []string
+	buildContext    *build.Context
+	config          *config
+	skipHelpers     bool
+	testMainFiles   bool
+	testMain        bool
+	stubs           bool
+	checkDupes      bool
+	packages        bool
+	count           bool
+	counts          testskipper.TestCount
+	prune           bool
+	tests           []testDecl
+	skipIfImports   string
+	skipIfEnv       string
+	buildTags       string
+	includeVendor   bool
+	directives      string
+	surgical        bool
+	noGeneratedEdit bool
+	showProgress    bool
+	summary         bool
+	verbose         bool
+	changedTests    int
+	changedFiles    int
+	delta           testskipper.SkipDelta
+	imports         testskipper.ImportDelta
+	processed       map[string]bool
+	cacheFile       string
+	actionLogFile   string
+	actionLog       *actionLog
+	cache           *cache
+	progress        *progress
+	clock           clock
+	blame           *blameFilter
+	logger          *slog.Logger
+	stdin           io.Reader
+	stdout          io.Writer
+	stderr          io.Writer
+	failFast        bool
+	collectErrors   bool
+	errs            []error
+	exitCode        int
+}
+
 func main() {
-	flag.Usage = usage
-	flag.Parse()
+	os.Exit(Run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// Run executes the command with the given arguments, writing its output to
+// stdout and stderr, and returns the exit code.
+//
+// The exit code is 0 on success, including runs in which no test matched,
+// 1 if in list or diff mode any file would change and 2 on any error. If
+// the first argument is validate, the validate subcommand is run instead,
+// exiting with 1 if any inconsistency is found.
+func Run(args []string, stdout, stderr io.Writer) int {
+	return RunWithLogger(args, stdout, stderr, nil)
+}
+
+// RunWithLogger is like Run, but additionally logs the events of the run to
+// logger. A nil logger discards all events, unless logging to stderr is
+// enabled by the -log flag.
+func RunWithLogger(args []string, stdout, stderr io.Writer, logger *slog.Logger) int {
+	return runWithStdin(args, os.Stdin, stdout, stderr, logger)
+}
+
+// runWithStdin is like RunWithLogger, but reads the source for the path -
+// from stdin instead of os.Stdin
+func runWithStdin(args []string, stdin io.Reader, stdout, stderr io.Writer, logger *slog.Logger) int {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	cmd := &command{
+		stdin:     stdin,
+		stdout:    stdout,
+		stderr:    stderr,
+		clock:     realClock{},
+		logger:    logger,
+		processed: make(map[string]bool),
+	}
+	if len(args) > 0 && args[0] == "validate" {
+		return cmd.validate(args[1:])
+	}
+	return cmd.run(args)
+}
+
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// newLogHandler returns a handler writing records of at least the given
+// level in format, which is either text or json, to w
+func newLogHandler(w io.Writer, format, level string) (slog.Handler, error) {
+	logLevel, ok := logLevels[level]
+	if !ok {
+		return nil, fmt.Errorf("invalid -log-level %q", level)
+	}
+	options := &slog.HandlerOptions{Level: logLevel}
+	switch format {
+	case "text":
+		return slog.NewTextHandler(w, options), nil
+	case "json":
+		return slog.NewJSONHandler(w, options), nil
+	default:
+		return nil, fmt.Errorf("invalid -log format %q", format)
+	}
+}
+
+func (c *command) run(args []string) int {
+	flags := flag.NewFlagSet("gotestskipper", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = usage(flags, c.stderr)
+	flags.BoolVar(&c.write, "w", false, "write result to (source) file instead of stdout")
+	flags.BoolVar(&c.packages, "packages", false, "resolve the paths as package patterns with go list, acting on exactly the test files go test would compile, e.g. ./...")
+	flags.BoolVar(&c.includeVendor, "include-vendor", false, "also process vendor directories of arguments like ./...")
+	flags.BoolVar(&c.backup, "backup", false, "with -w, save the original content of each written file to <file>.orig")
+	flags.StringVar(&c.outputDir, "o", "", "write results into the given directory instead of stdout, with -tar into the given tar file")
+	flags.StringVar(&c.tarFile, "tar", "", "process the *_test.go files of the given tar archive and write a tar archive with the results")
+	flags.BoolVar(&c.force, "force", false, "with -o, overwrite existing files")
+	flags.BoolVar(&c.unskip, "u", false, "unskips all skipped tests instead of skipping them")
+	flags.BoolVar(&c.allSkips, "all-skips", false, "with -u, remove all leading skip statements and short mode guards")
+	flags.StringVar(&c.template, "template", "", "insert the skip call rendered from the given text/template instead of t.Skip(), e.g. testutil.Skip({{.Param}}); with -u, also remove such calls")
+	flags.StringVar(&c.skipCallPattern, "skip-call-pattern", "", "recognize calls of the given comma separated helpers like testutil.Skip(t, ...) as skips")
+	flags.BoolVar(&c.unskipNested, "unskip-nested", false, "with -u, also remove skips nested in if, for, switch and select statements")
+	flags.BoolVar(&c.unskipNote, "unskip-note", false, "with -u, add a '// re-enabled YYYY-MM-DD' comment above unskipped tests")
+	flags.StringVar(&c.skipIfEnv, "skip-if-env", "", "only skip the tests if the given environment variable is set, e.g. SKIP_FLAKY; with -u, remove only these conditional skips")
+	flags.StringVar(&c.reason, "reason", "", "pass the given reason to the inserted skips, e.g. 'flaky on CI #1234'")
+	flags.BoolVar(&c.afterCleanup, "after-cleanup", false, "place the skip after any leading t.Cleanup calls")
+	flags.BoolVar(&c.skipParallel, "skip-parallel", false, "also remove the t.Parallel() calls of skipped tests, which -u does not restore")
+	flags.BoolVar(&c.tightSkip, "tight-skip", false, "do not separate the inserted skip from the following statement by a blank line")
+	flags.Func("prefix", "act on the test functions with the given name prefix instead of Test, e.g. IntegrationTest, may be repeated", func(prefix string) error {
+		if prefix == "" {
+			return fmt.Errorf("empty prefix")
+		}
+		c.prefixes = append(c.prefixes, prefix)
+		return nil
+	})
+	flags.BoolVar(&c.fuzz, "fuzz", false, "also act on fuzz targets like FuzzFoo(f *testing.F)")
+	flags.BoolVar(&c.afterSeeds, "fuzz-skip-after-seeds", false, "place the skip of fuzz targets after any leading f.Add calls, implies -fuzz")
+	flags.BoolVar(&c.bench, "bench", false, "also act on benchmarks like BenchmarkFoo(b *testing.B)")
+	flags.StringVar(&c.benchPrefix, "bench-prefix", testskipper.DefaultBenchmarkPrefix, "with -bench, the name prefix of the benchmarks to act on")
+	flags.BoolVar(&c.strict, "strict", true, "only match test functions with a single *testing.T parameter")
+	flags.BoolVar(&c.list, "l", false, "list files whose content would change")
+	flags.BoolVar(&c.nullSeparated, "0", false, "separate the paths listed by -l with NUL instead of newline characters")
+	flags.BoolVar(&c.diff, "d", false, "display diffs instead of rewriting files")
+	flags.BoolVar(&c.diff, "diff", false, "same as -d")
+	flags.IntVar(&c.diffContext, "diff-context", defaultDiffContext, "with -d, the number of context lines around each change")
+	flags.StringVar(&c.format, "format", "", "print a summary in the given format instead of the sources: github or json")
+	flags.BoolVar(&c.jsonFormat, "json", false, "print a JSON summary of the processed files instead of the sources, like -format json")
+	flags.StringVar(&c.paramType, "param-type", "", "match test functions whose parameter has exactly this type, e.g. '*qt.T'")
+	flags.StringVar(&c.nameParam, "name-param", testskipper.DefaultParamName, "the name given to unnamed testing parameters of skipped tests")
+	flags.StringVar(&c.suite, "suite", "", "act on the test methods of the given suite type, e.g. MySuite, instead of test functions, or auto to detect testify suite types")
+	flags.BoolVar(&c.fixExisting, "fix-existing", false, "rewrite existing skips without a reason to the -canonical form instead of skipping")
+	flags.StringVar(&c.canonical, "canonical", "skip", "with -fix-existing, the form to rewrite skips to: skip or skipnow")
+	flags.StringVar(&c.normalize, "normalize", "", "move existing skips to a consistent position instead of skipping: top, after-parallel or after-cleanup")
+	flags.BoolVar(&c.skipHelpers, "skip-helpers", false, "also act on functions calling t.Helper()")
+	flags.BoolVar(&c.surgical, "surgical", false, "only re-print modified functions, keeping all other source as is")
+	flags.BoolVar(&c.prune, "prune", false, "collapse stacked leading skips to the first one instead of skipping, reporting the pruned tests to stderr")
+	flags.BoolVar(&c.count, "count", false, "print the number of matched tests per package and in total instead of skipping")
+	flags.BoolVar(&c.checkDupes, "check-dupes", false, "report tests declared with the same name more than once instead of skipping, exiting with 1 if any")
+	flags.BoolVar(&c.stubs, "stubs", false, "only act on tests with an empty body, skipping them as not implemented")
+	flags.StringVar(&c.directives, "respect-directive", "", "leave tests untouched whose doc comment carries any of the given comma separated directives, e.g. nolint,skip:keep")
+	flags.StringVar(&c.skipIfImports, "skip-if-imports", "", "only act on tests in files importing any of the given comma separated packages, e.g. database/sql,net/http")
+	flags.StringVar(&c.buildTags, "tag", "", "only act on tests in files whose build constraint requires any of the given comma separated tags, e.g. integration")
+	flags.BoolVar(&c.testMain, "testmain", false, "instead of skipping tests, make TestMain exit early if the environment variable given by -skip-if-env is set; with -u, remove this exit")
+	flags.BoolVar(&c.testMainFiles, "testmain-files", false, "only act on tests in files declaring a TestMain, leaving TestMain itself untouched")
+	flags.StringVar(&c.rulesFile, "rules", "", "apply the first matching rule of the given rules file to each test")
+	flags.BoolVar(&c.noGeneratedEdit, "no-generated-edit", false, "do not write files containing a //go:generate directive")
+	flags.BoolVar(&c.summary, "summary", false, "print the net number of added and removed skips to stderr")
+	flags.BoolVar(&c.showProgress, "progress", false, "periodically print the number of processed files to stderr")
+	flags.StringVar(&c.newerThan, "newer-than", "", "only act on tests modified within the given age according to git blame, e.g. 7d")
+	flags.StringVar(&c.fromGoList, "from-go-list", "", "only act on the tests listed by 'go test -list' with the given pattern")
+	flags.StringVar(&c.fromCoverage, "from-coverage", "", "only act on the test files selected from the given coverage profile by -coverage-policy, e.g. cover.out")
+	flags.StringVar(&c.coveragePolicy, "coverage-policy", coveragePolicyZero, "with -from-coverage, the policy selecting test files: zero, selecting the tests of source files without covered statements")
+	flags.StringVar(&c.runPattern, "run", "", "only act on the tests whose name matches the given regular expression, like go test -run")
+	flags.StringVar(&c.subtestPattern, "subtest", "", "skip the subtests run by t.Run with a literal name matching the given regular expression instead of whole tests")
+	flags.StringVar(&c.fromFile, "from-file", "", "only act on the tests named in the given file, one name or regular expression per line")
+	flags.StringVar(&c.cacheFile, "cache", "", "skip files which are unchanged since a run with the same flags, recorded in the given cache file, e.g. .gotestskipper.cache")
+	flags.StringVar(&c.actionLogFile, "action-log", "", "with -w or -o, append a timestamped line per changed test to the given file, e.g. actions.log")
+	flags.BoolVar(&c.failFast, "fail-fast", false, "stop processing at the first file which cannot be processed")
+	flags.BoolVar(&c.collectErrors, "collect-errors", false, "report all errors at the end instead of when they occur; the files of an argument with errors are still not written")
+	flags.BoolVar(&c.verbose, "v", false, "print the number of changed tests per file and in total to stderr")
+	flags.StringVar(&c.logFormat, "log", "", "log the events of the run to stderr in the given format: text or json")
+	flags.StringVar(&c.logLevel, "log-level", "info", "with -log, the minimum level to log: debug, info, warn or error")
+	flags.Func("exclude", "leave files matching the glob pattern untouched, without parsing them, may be repeated", func(pattern string) error {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return err
+		}
+		c.exclude = append(c.exclude, pattern)
+		return nil
+	})
+	flags.Func("build-tags", "only act on files built in the current build context with the given comma separated tags, leaving files excluded by GOOS, GOARCH or build constraints untouched", func(tags string) error {
+		buildContext := build.Default
+		if tags != "" {
+			buildContext.BuildTags = append(buildContext.BuildTags, strings.Split(tags, ",")...)
+		}
+		c.buildContext = &buildContext
+		return nil
+	})
+	flags.StringVar(&c.configFile, "config", "", "read default flags and excluded files from the given config file instead of "+defaultConfigFile+" in the working directory")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitCodeClean
+		}
+		return exitCodeError
+	}
+	if err := c.loadConfig(flags); err != nil {
+		c.report(err)
+		return c.exitCode
+	}
+
+	if c.skipCallPattern != "" {
+		for _, pattern := range strings.Split(c.skipCallPattern, ",") {
+			skipCall, err := testskipper.ParseSkipCall(pattern)
+			if err != nil {
+				c.report(err)
+				return c.exitCode
+			}
+			c.skipCalls = append(c.skipCalls, skipCall)
+		}
+	}
+
+	if c.template != "" {
+		skipTemplate, err := testskipper.ParseSkipTemplate(c.template)
+		if err != nil {
+			c.report(err)
+			return c.exitCode
+		}
+		c.skipTemplate = skipTemplate
+	}
 
 	var visitAction func(*ast.FuncDecl)
-	if *unskip {
-		visitAction = testskipper.UnskipTestVisitorAction
-	} else {
-		visitAction = testskipper.SkipTestVisitorAction
+	switch {
+	case c.rulesFile != "":
+		c.action = "rules"
+		rules, err := readRules(c.rulesFile)
+		if err != nil {
+			c.report(err)
+			return c.exitCode
+		}
+		visitAction = testskipper.RulesVisitAction(rules)
+	case c.normalize != "":
+		position, ok := skipPositions[c.normalize]
+		if !ok {
+			c.report(fmt.Errorf("invalid -normalize position %q", c.normalize))
+			return c.exitCode
+		}
+		visitAction = testskipper.NormalizeSkipVisitorAction(position)
+		c.action = "normalize"
+	case c.fixExisting:
+		form, ok := skipForms[c.canonical]
+		if !ok {
+			c.report(fmt.Errorf("invalid -canonical form %q", c.canonical))
+			return c.exitCode
+		}
+		visitAction = testskipper.FixExistingSkipsVisitorAction(form)
+		c.action = "fix"
+	case c.prune:
+		visitAction = testskipper.PruneSkipsVisitorAction(c.skipCalls...)
+		c.action = "prune"
+	case c.count:
+		visitAction = testskipper.CountVisitorAction
+		c.action = "count"
+	case c.checkDupes:
+		visitAction = func(*ast.FuncDecl) {}
+		c.action = "check-dupes"
+	case c.testMain && c.unskip:
+		visitAction = testskipper.UnskipTestMainVisitorAction(c.skipIfEnv)
+		c.action = "unskip"
+	case c.testMain:
+		visitAction = testskipper.SkipTestMainVisitorAction(c.skipIfEnv)
+		c.action = "skip"
+	case c.unskip && c.skipIfEnv != "":
+		visitAction = testskipper.UnskipIfEnvVisitorAction(c.skipIfEnv)
+		c.action = "unskip"
+	case c.unskip && c.allSkips:
+		visitAction = testskipper.UnskipAllTestVisitorActionWithSkipCalls(c.skipCalls...)
+		c.action = "unskip"
+	case c.unskip:
+		visitAction = testskipper.UnskipTestVisitorActionWithOptions(testskipper.UnskipOptions{Calls: c.skipCalls, Nested: c.unskipNested, Template: c.skipTemplate})
+		c.action = "unskip"
+	default:
+		opts := testskipper.SkipOptions{Reason: c.reason, EnvVar: c.skipIfEnv, AfterCleanup: c.afterCleanup, AfterSeeds: c.afterSeeds, ParamName: c.nameParam, Tight: c.tightSkip, RemoveParallel: c.skipParallel, Template: c.skipTemplate}
+		if c.stubs && c.reason == "" {
+			opts.Reason = stubSkipReason
+		}
+		visitAction = testskipper.SkipTestVisitorActionWithOptions(opts)
+		if c.subtestPattern != "" {
+			subtest, err := regexp.Compile(c.subtestPattern)
+			if err != nil {
+				c.report(fmt.Errorf("invalid -subtest pattern %q: %v", c.subtestPattern, err))
+				return c.exitCode
+			}
+			visitAction = testskipper.SkipSubtestsVisitorAction(subtest, opts)
+		}
+		c.action = "skip"
 	}
 
-	if flag.NArg() == 0 {
-		flag.Usage()
+	if c.logFormat != "" {
+		handler, err := newLogHandler(c.stderr, c.logFormat, c.logLevel)
+		if err != nil {
+			c.report(err)
+			return c.exitCode
+		}
+		c.logger = slog.New(handler)
+	}
+
+	if c.jsonFormat {
+		if c.format != "" && c.format != formatJSON {
+			c.report(fmt.Errorf("-json and -format %s are mutually exclusive", c.format))
+			return c.exitCode
+		}
+		c.format = formatJSON
 	}
 
-	for i := 0; i < flag.NArg(); i++ {
-		path := flag.Arg(i)
+	if c.format != "" && c.format != formatGitHub && c.format != formatJSON {
+		c.report(fmt.Errorf("invalid -format %q", c.format))
+		return c.exitCode
+	}
 
-		testFuncVisitor := testskipper.NewTestFuncVisitor(visitAction)
+	if c.diffContext < 0 {
+		c.report(fmt.Errorf("invalid -diff-context %d", c.diffContext))
+		return c.exitCode
+	}
 
-		pathWriter := make(testskipper.PathWriter)
-		output := &OutputStrategy{pathWriter}
+	if c.write && c.outputDir != "" {
+		c.report(fmt.Errorf("-w and -o are mutually exclusive"))
+		return c.exitCode
+	}
 
-		switch dir, err := os.Stat(path); {
-		case err != nil:
-			report(err)
-		case dir.IsDir():
-			if err := testskipper.WalkDir(path, pathWriter, testFuncVisitor); err != nil {
-				report(err)
-			} else {
-				err := writeOutput(output)
-				if err != nil {
-					report(err)
+	if c.backup && !c.write {
+		c.report(fmt.Errorf("-backup requires -w"))
+		return c.exitCode
+	}
+
+	if c.allSkips && !c.unskip {
+		c.report(fmt.Errorf("-all-skips requires -u"))
+		return c.exitCode
+	}
+
+	if c.testMain && !c.unskip && c.skipIfEnv == "" {
+		c.report(fmt.Errorf("-testmain requires -skip-if-env"))
+		return c.exitCode
+	}
+
+	if c.template != "" && c.reason != "" {
+		c.report(fmt.Errorf("-reason can not be combined with -template"))
+		return c.exitCode
+	}
+
+	if c.skipParallel && c.unskip {
+		c.report(fmt.Errorf("-skip-parallel can not be combined with -u"))
+		return c.exitCode
+	}
+
+	if c.subtestPattern != "" && c.unskip {
+		c.report(fmt.Errorf("-subtest can not be combined with -u"))
+		return c.exitCode
+	}
+
+	if c.reason != "" && c.unskip {
+		c.report(fmt.Errorf("-reason can not be combined with -u"))
+		return c.exitCode
+	}
+
+	if c.failFast && c.collectErrors {
+		c.report(fmt.Errorf("-fail-fast and -collect-errors are mutually exclusive"))
+		return c.exitCode
+	}
+
+	if c.unskipNested && !c.unskip {
+		c.report(fmt.Errorf("-unskip-nested requires -u"))
+		return c.exitCode
+	}
+
+	if c.unskipNote && !c.unskip {
+		c.report(fmt.Errorf("-unskip-note requires -u"))
+		return c.exitCode
+	}
+
+	if !token.IsIdentifier(c.nameParam) || c.nameParam == "_" {
+		c.report(fmt.Errorf("invalid -name-param %q", c.nameParam))
+		return c.exitCode
+	}
+
+	if c.paramType != "" {
+		if _, err := parser.ParseExpr(c.paramType); err != nil {
+			c.report(fmt.Errorf("invalid -param-type %q: %v", c.paramType, err))
+			return c.exitCode
+		}
+	}
+
+	if c.runPattern != "" {
+		run, err := regexp.Compile(c.runPattern)
+		if err != nil {
+			c.report(fmt.Errorf("invalid -run pattern %q: %v", c.runPattern, err))
+			return c.exitCode
+		}
+		c.runFilter = run
+	}
+
+	if c.fromFile != "" {
+		names, err := readNames(c.fromFile)
+		if err != nil {
+			c.report(err)
+			return c.exitCode
+		}
+		c.names = names
+	}
+
+	if c.fromCoverage != "" {
+		if !coveragePolicies[c.coveragePolicy] {
+			c.report(fmt.Errorf("invalid -coverage-policy %q", c.coveragePolicy))
+			return c.exitCode
+		}
+		coverage, err := readCoverage(c.fromCoverage)
+		if err != nil {
+			c.report(err)
+			return c.exitCode
+		}
+		c.coverage = coverage
+	}
+
+	if c.newerThan != "" {
+		age, err := parseAge(c.newerThan)
+		if err != nil {
+			c.report(err)
+			return c.exitCode
+		}
+		c.blame = &blameFilter{cutoff: c.clock.Now().Add(-age)}
+	}
+
+	if c.tarFile != "" {
+		if err := c.runTar(visitAction); err != nil {
+			c.report(err)
+		}
+		return c.finish()
+	}
+
+	if c.actionLogFile != "" {
+		c.actionLog = &actionLog{path: c.actionLogFile, clock: c.clock}
+	}
+
+	if c.cacheFile != "" {
+		cache, err := loadCache(c.cacheFile, cacheAction(c.action, flags))
+		if err != nil {
+			c.report(err)
+			return c.exitCode
+		}
+		c.cache = cache
+	}
+
+	if c.showProgress {
+		c.progress = newProgress(c.stderr, c.clock, defaultProgressInterval)
+	}
+
+	args = flags.Args()
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+	for _, arg := range args {
+		if arg == "-" {
+			c.processStdin(visitAction)
+			continue
+		}
+		paths, err := c.expand(arg)
+		if err != nil {
+			c.fail(err)
+			continue
+		}
+		for _, path := range paths {
+			if c.failFast && c.exitCode == exitCodeError {
+				return c.finish()
+			}
+			testFuncVisitor := c.newVisitor(visitAction)
+			if c.cache != nil {
+				testFuncVisitor.AddPathFilter(c.cache.Stale)
+			}
+			if c.coverage != nil {
+				testFuncVisitor.AddPathFilter(c.coverage.ZeroCovered)
+			}
+			if c.blame != nil {
+				if err := checkGitWorkTree(path); err != nil {
+					c.report(err)
+					continue
 				}
+				testFuncVisitor.AddFilter(c.blame.Filter)
 			}
-
-		default:
-			writer := pathWriter.ReadWriterForPath(path)
-			if err := testskipper.WalkFile(path, writer, testFuncVisitor); err != nil {
-				report(err)
-			} else {
-				err := writeOutput(output)
+			if c.fromGoList != "" {
+				names, err := goTestList(path, c.fromGoList)
 				if err != nil {
-					report(err)
+					c.report(err)
+					continue
 				}
+				set, err := newNameSet(names)
+				if err != nil {
+					c.report(err)
+					continue
+				}
+				testFuncVisitor.AddFilter(set.Filter)
+			}
+
+			c.processPath(path, testFuncVisitor)
+		}
+	}
+	return c.finish()
+}
+
+// expand returns the paths arg stands for, or with -packages the test files
+// of the packages matched by arg
+func (c *command) expand(arg string) ([]string, error) {
+	if c.packages {
+		return goListTestFiles(arg, c.buildContext)
+	}
+	return expandArg(arg, c.includeVendor)
+}
+
+// finish prints the final progress and summary and returns the exit code
+func (c *command) finish() int {
+	if c.progress != nil {
+		c.progress.Done()
+	}
+	if c.checkDupes {
+		c.reportDuplicates()
+	}
+	if c.count {
+		c.printCounts()
+	}
+	if len(c.errs) > 0 {
+		c.report(errors.Join(c.errs...))
+	}
+	if c.format == formatJSON && !c.checkDupes && !c.count {
+		if err := c.writeJSON(); err != nil {
+			c.report(err)
+		}
+	}
+	if c.summary {
+		fmt.Fprintf(c.stderr, "gotestskipper: %s\n", c.delta)
+		if imports := c.imports.String(); imports != "" {
+			fmt.Fprintf(c.stderr, "gotestskipper: %s\n", imports)
+		}
+	}
+	if c.verbose && !c.checkDupes && !c.count {
+		c.reportTotals()
+	}
+	if c.cache != nil {
+		if err := c.cache.Save(); err != nil {
+			c.report(err)
+		}
+	}
+	return c.exitCode
+}
+
+// newVisitor returns a visitor calling visitAction, configured by the flags
+func (c *command) newVisitor(visitAction testskipper.FuncVisitAction) testskipper.TestFuncVisitor {
+	testFuncVisitor := testskipper.NewTestFuncVisitor(visitAction)
+	c.configureVisitor(testFuncVisitor)
+	return testFuncVisitor
+}
+
+// configureVisitor applies the flags to testFuncVisitor
+func (c *command) configureVisitor(testFuncVisitor testskipper.TestFuncVisitor) {
+	testFuncVisitor.SetStrict(c.strict)
+	testFuncVisitor.SetParamType(c.paramType)
+	testFuncVisitor.SetSkipHelpers(c.skipHelpers)
+	testFuncVisitor.SetSurgical(c.surgical)
+	testFuncVisitor.SetSuite(c.suite)
+	testFuncVisitor.SetPrefixes(c.prefixes)
+	testFuncVisitor.SetTestMain(c.testMain)
+	testFuncVisitor.SetFuzz(c.fuzz || c.afterSeeds)
+	testFuncVisitor.SetBenchmarks(c.bench)
+	testFuncVisitor.SetBenchmarkPrefix(c.benchPrefix)
+	testFuncVisitor.SetCollectErrors(!c.failFast)
+	for _, skipCall := range c.skipCalls {
+		testFuncVisitor.AddSkipCall(skipCall)
+	}
+	if c.unskipNote {
+		testFuncVisitor.SetUnskipNote(c.clock.Now())
+	}
+	if c.runFilter != nil {
+		testFuncVisitor.AddFilter(testskipper.NameMatching(c.runFilter))
+	}
+	if c.names != nil {
+		testFuncVisitor.AddFilter(c.names.Filter)
+	}
+	if c.stubs {
+		testFuncVisitor.AddFilter(testskipper.IsStub)
+	}
+	if c.directives != "" {
+		testFuncVisitor.AddFilter(testskipper.WithoutDirective(strings.Split(c.directives, ",")...))
+	}
+	if c.skipIfImports != "" {
+		testFuncVisitor.AddFileFilter(testskipper.ImportsAny(strings.Split(c.skipIfImports, ",")...))
+	}
+	if c.testMainFiles {
+		testFuncVisitor.AddFileFilter(testskipper.HasTestMain)
+	}
+	if c.buildTags != "" {
+		testFuncVisitor.AddFileFilter(testskipper.HasBuildTag(strings.Split(c.buildTags, ",")...))
+	}
+	if len(c.exclude) > 0 || c.config != nil || c.buildContext != nil {
+		testFuncVisitor.AddPathFilter(c.included)
+	}
+}
+
+// included reports whether the file at path is neither excluded by -exclude
+// nor by the config and, with -build-tags, is built in the build context.
+// It is used as testskipper.PathFilter.
+func (c *command) included(path string) bool {
+	if c.config != nil && c.config.excludes(path) {
+		return false
+	}
+	if c.buildContext != nil && !testskipper.MatchBuildContext(c.buildContext)(path) {
+		return false
+	}
+	return testskipper.ExcludePaths(c.exclude...)(path)
+}
+
+// loadConfig reads the config file given by -config, or if present the
+// default config file of the working directory, and applies its flags
+// which were not set on the command line
+func (c *command) loadConfig(flags *flag.FlagSet) error {
+	path := c.configFile
+	if path == "" {
+		if _, err := os.Stat(defaultConfigFile); err != nil {
+			return nil
+		}
+		path = defaultConfigFile
+	}
+	config, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	if err := config.apply(flags); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	c.config = config
+	return nil
+}
+
+// processPath applies visitor to the file or directory at path and writes
+// the output
+func (c *command) processPath(path string, visitor testskipper.TestFuncVisitor) {
+	pathWriter := make(testskipper.PathWriter)
+	output := &OutputStrategy{pathWriter}
+	report := &testskipper.Report{}
+	visitor.SetReport(report)
+	c.logger.Debug("processing path", "path", path, "action", c.action)
+
+	dir, err := os.Stat(path)
+	switch {
+	case err != nil:
+		c.fail(err)
+		return
+	case dir.IsDir():
+		err = testskipper.WalkDir(path, pathWriter, visitor)
+	case c.cache != nil && c.cache.Fresh(path):
+		c.logger.Debug("skipping cached file", "path", path)
+		return
+	case c.coverage != nil && !c.coverage.ZeroCovered(path):
+		c.logger.Debug("skipping file not selected by coverage", "path", path)
+		return
+	case !c.included(path):
+		c.logger.Debug("skipping excluded file", "path", path)
+		return
+	default:
+		writer := pathWriter.ReadWriterForPath(path)
+		err = testskipper.WalkFile(path, writer, visitor)
+	}
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	c.dedup(pathWriter, report)
+	c.logger.Debug("processed path", "path", path, "files", len(pathWriter))
+	if c.progress != nil {
+		c.progress.Add(len(pathWriter))
+	}
+	if c.blame != nil && c.blame.err != nil {
+		c.report(c.blame.err)
+		c.blame.err = nil
+		return
+	}
+	if c.checkDupes {
+		c.collectTests(report)
+		return
+	}
+	if c.count {
+		c.counts = c.counts.Add(report.Count())
+		return
+	}
+	c.delta = c.delta.Add(report.Delta())
+	c.imports = c.imports.Add(report.ImportDelta())
+	for _, funcReport := range report.Changed() {
+		c.logger.Info("changed test", "action", c.action, "test", funcReport.Name, "file", funcReport.Position.Filename, "line", funcReport.Position.Line)
+		if c.prune {
+			fmt.Fprintf(c.stderr, "%s: %s: pruned duplicate skips\n", funcReport.Position, funcReport.Name)
+		}
+	}
+	if c.verbose {
+		c.reportCounts(report)
+	}
+	outputs := c.outputs(pathWriter)
+	if err := c.writeOutput(output, report); err != nil {
+		c.report(err)
+		return
+	}
+	c.record(outputs)
+	if c.actionLog != nil && (c.write || c.outputDir != "") {
+		var written []testskipper.FuncReport
+		for _, funcReport := range report.Changed() {
+			if _, ok := pathWriter[funcReport.Position.Filename]; ok {
+				written = append(written, funcReport)
 			}
 		}
+		if err := c.actionLog.Append(c.action, written); err != nil {
+			c.report(err)
+		}
 	}
-	os.Exit(exitCode)
 }
 
-func writeOutput(output *OutputStrategy) error {
-	if *write {
-		err := output.WriteToFile()
+// outputs returns the content of the buffers of pathWriter for the cache,
+// leaving the buffers intact
+func (c *command) outputs(pathWriter testskipper.PathWriter) map[string][]byte {
+	if c.cache == nil {
+		return nil
+	}
+	outputs := make(map[string][]byte, len(pathWriter))
+	for path, buffer := range pathWriter {
+		content, err := ioutil.ReadAll(buffer)
+		if err != nil {
+			continue
+		}
+		pathWriter[path] = bytes.NewBuffer(content)
+		outputs[path] = content
+	}
+	return outputs
+}
+
+// record records the files in the cache whose content on disk equals the
+// output of the action, i.e. which were written or need no change
+func (c *command) record(outputs map[string][]byte) {
+	for path, output := range outputs {
+		content, err := ioutil.ReadFile(path)
+		if err == nil && bytes.Equal(content, output) {
+			c.cache.Record(path, output)
+		}
+	}
+}
+
+// dedup removes the files which were already processed by an earlier
+// argument from pathWriter and report, so that overlapping arguments do not
+// process and write a file more than once per run
+func (c *command) dedup(pathWriter testskipper.PathWriter, report *testskipper.Report) {
+	duplicates := make(map[string]bool)
+	for path := range pathWriter {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			abs = path
+		}
+		if c.processed[abs] {
+			delete(pathWriter, path)
+			duplicates[path] = true
+			continue
+		}
+		c.processed[abs] = true
+	}
+	if len(duplicates) == 0 {
+		return
+	}
+	funcs := report.Funcs[:0]
+	for _, funcReport := range report.Funcs {
+		if !duplicates[funcReport.Position.Filename] {
+			funcs = append(funcs, funcReport)
+		}
+	}
+	report.Funcs = funcs
+	imports := report.Imports[:0]
+	for _, change := range report.Imports {
+		if !duplicates[change.Filename] {
+			imports = append(imports, change)
+		}
+	}
+	report.Imports = imports
+}
+
+func (c *command) writeOutput(output *OutputStrategy, report *testskipper.Report) error {
+	if c.list || c.diff {
+		err := c.checkOutput(output)
 		if err != nil {
 			return err
 		}
-	} else {
-		err := output.WriteToStdout()
+	}
+	switch c.format {
+	case formatGitHub:
+		c.writeGitHubAnnotations(report)
+	case formatJSON:
+		c.collectJSON(output.PathWriter, report)
+	}
+	switch {
+	case c.write:
+		c.checkGenerated(output)
+		if c.backup {
+			return output.WriteToFileWithBackup()
+		}
+		return output.WriteToFile()
+	case c.outputDir != "":
+		return output.WriteToDir(c.outputDir, c.force)
+	case c.list || c.diff || c.format != "":
+		return nil
+	default:
+		return output.WriteToOutput(c.stdout)
+	}
+}
+
+// checkOutput compares the buffers of output with the original files and
+// lists the changed paths or prints their diffs. The buffers are left intact
+// so they can be written afterwards.
+func (c *command) checkOutput(output *OutputStrategy) error {
+	for _, path := range output.PathWriter.Paths() {
+		original, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		modified, err := ioutil.ReadAll(output.PathWriter[path])
 		if err != nil {
 			return err
 		}
+		output.PathWriter[path] = bytes.NewBuffer(modified)
+		if bytes.Equal(original, modified) {
+			continue
+		}
+		c.changesFound()
+		if c.list {
+			c.listPath(path)
+		}
+		if c.diff {
+			_, err := unifiedDiff(c.stdout, path, original, modified, c.diffContext)
+			if err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
-func report(err error) {
-	scanner.PrintError(os.Stderr, err)
-	exitCode = 2
+// checkGenerated warns about files carrying a go:generate directive, as
+// edits to them may be overwritten. With -no-generated-edit these files are
+// removed from output, so that they are not written.
+func (c *command) checkGenerated(output *OutputStrategy) {
+	for _, path := range output.PathWriter.Paths() {
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil || !testskipper.HasGenerateDirective(file) {
+			continue
+		}
+		if c.noGeneratedEdit {
+			c.warn("%s: contains a //go:generate directive, not writing", path)
+			delete(output.PathWriter, path)
+		} else {
+			c.warn("%s: contains a //go:generate directive, edits may be overwritten", path)
+		}
+	}
+}
+
+// listPath prints path, terminated by a newline or with -0 by a NUL
+// character
+func (c *command) listPath(path string) {
+	separator := "\n"
+	if c.nullSeparated {
+		separator = "\x00"
+	}
+	fmt.Fprint(c.stdout, path+separator)
+}
+
+// changesFound records that a file would change, unless an error has
+// already been reported
+func (c *command) changesFound() {
+	if c.exitCode == exitCodeClean {
+		c.exitCode = exitCodeChanges
+	}
+}
+
+func (c *command) warn(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	fmt.Fprintf(c.stderr, "gotestskipper: %s\n", message)
+	c.logger.Warn(message)
+}
+
+// fail reports err of processing an argument. With -collect-errors, the
+// error is only recorded and reported by finish together with all others.
+func (c *command) fail(err error) {
+	if !c.collectErrors {
+		c.report(err)
+		return
+	}
+	c.errs = append(c.errs, err)
+	c.exitCode = exitCodeError
+}
+
+func (c *command) report(err error) {
+	scanner.PrintError(c.stderr, err)
+	c.logger.Error(err.Error())
+	c.exitCode = exitCodeError
 }
@@ -1,69 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"os"
-	"os/exec"
 	"path"
+	"sort"
 	"strings"
 	"testing"
 
 	"github.com/mitch000001/go-tools/testskipper"
 )
 
-func TestSkipTests(t *testing.T) {
-	t.Skip()
-	testDir := "/tmp/gotestskipper/"
-	src := `
-	package main
-
-	import "fmt"
-
-	func TestFoo(t *testing.T) {
-		s := "foo"
-		fmt.Println(s)
-	}`
-	fileCount := 2
-	withFixtureFiles(testDir, src, fileCount, func() {
-		cmd := exec.Command("go", "run", "main.go", "/tmp/gotestskipper")
-		rc, err := cmd.StdoutPipe()
-		if err != nil {
-			panic(err)
-		}
-		err = cmd.Start()
-		if err != nil {
-			t.Fatalf("Expected no error, got '%T' with message '%s'", err, err.Error())
-		}
-		expected := `
-		package main
-
-		import "fmt"
-
-		func TestFoo(t *testing.T) {
-			t.Skip()
-			s := "foo"
-			fmt.Println(s)
-		}`
-		allExpected := ""
-		for i := 1; i <= fileCount; i++ {
-			allExpected = allExpected + expected
-		}
-		bytes, err := ioutil.ReadAll(rc)
-		if err != nil {
-			t.Fatalf("Expected no error, got '%T' with message '%s'", err, err.Error())
-		}
-
-		replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
-		allExpected = replacer.Replace(allExpected)
-		actual := replacer.Replace(string(bytes))
-
-		if allExpected != actual {
-			t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", allExpected, actual)
-		}
-	})
-}
-
 func TestOutputStrategyWriteToFile(t *testing.T) {
 	// Valid path
 	path := "/tmp/bar"
@@ -115,6 +66,151 @@ func TestOutputStrategyWriteToFile(t *testing.T) {
 	}
 }
 
+func TestOutputStrategyWriteToFileKeepsMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, mode := range []os.FileMode{0600, 0644, 0755} {
+		filePath := path.Join(dir, "foo_test.go")
+		if err := ioutil.WriteFile(filePath, []byte{}, mode); err != nil {
+			panic(err)
+		}
+		// the mode passed to WriteFile is subject to the umask
+		if err := os.Chmod(filePath, mode); err != nil {
+			panic(err)
+		}
+
+		pWriter := make(testskipper.PathWriter)
+		pWriter.ReadWriterForPath(filePath).Write([]byte("foo"))
+		err := (&OutputStrategy{pWriter}).WriteToFile()
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		info, err := os.Stat(filePath)
+		if err != nil {
+			panic(err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Fatalf("Expected mode %v, got %v\n", mode, info.Mode().Perm())
+		}
+	}
+}
+
+func TestOutputStrategyWriteToFileWithBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	filePath := path.Join(dir, "foo_test.go")
+	original := "package main\n"
+	if err := ioutil.WriteFile(filePath, []byte(original), 0600); err != nil {
+		panic(err)
+	}
+	if err := os.Chmod(filePath, 0600); err != nil {
+		panic(err)
+	}
+	content := "package main\n\n// rewritten\n"
+	pWriter := make(testskipper.PathWriter)
+	pWriter.ReadWriterForPath(filePath).Write([]byte(content))
+
+	err = (&OutputStrategy{pWriter}).WriteToFileWithBackup()
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	fileContent, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if string(fileContent) != content {
+		t.Fatalf("Expected fileContent '%s', got '%s'\n", content, fileContent)
+	}
+	backup, err := ioutil.ReadFile(filePath + ".orig")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if string(backup) != original {
+		t.Fatalf("Expected backup '%s', got '%s'\n", original, backup)
+	}
+	info, err := os.Stat(filePath + ".orig")
+	if err != nil {
+		panic(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected mode %v, got %v\n", os.FileMode(0600), info.Mode().Perm())
+	}
+
+	// An unchanged file is not backed up
+	if err := os.Remove(filePath + ".orig"); err != nil {
+		panic(err)
+	}
+	pWriter.ReadWriterForPath(filePath).Write([]byte(content))
+	err = (&OutputStrategy{pWriter}).WriteToFileWithBackup()
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if _, err := os.Stat(filePath + ".orig"); !os.IsNotExist(err) {
+		t.Fatalf("Expected no backup of an unchanged file, got '%v'\n", err)
+	}
+}
+
+// failingReadWriter yields content and then fails with err
+type failingReadWriter struct {
+	content string
+	err     error
+}
+
+func (f *failingReadWriter) Read(p []byte) (int, error) {
+	if f.content == "" {
+		return 0, f.err
+	}
+	n := copy(p, f.content)
+	f.content = f.content[n:]
+	return n, nil
+}
+
+func (f *failingReadWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func TestOutputStrategyWriteToFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	filePath := path.Join(dir, "foo_test.go")
+	original := "package main\n"
+	if err := ioutil.WriteFile(filePath, []byte(original), 0644); err != nil {
+		panic(err)
+	}
+
+	pWriter := testskipper.PathWriter{filePath: &failingReadWriter{content: "package ma", err: fmt.Errorf("disk full")}}
+	err = (&OutputStrategy{pWriter}).WriteToFile()
+
+	if err == nil || err.Error() != "disk full" {
+		t.Fatalf("Expected error 'disk full', got '%v'\n", err)
+	}
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		panic(err)
+	}
+	if string(content) != original {
+		t.Fatalf("Expected the original content '%s', got '%s'\n", original, content)
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		panic(err)
+	}
+	if len(infos) != 1 {
+		t.Fatalf("Expected the temporary file to be removed, got %d files\n", len(infos))
+	}
+}
+
 func TestOutputStrategyWriteToStdout(t *testing.T) {
 	path := "/tmp/bar"
 	content := "foo"
@@ -150,6 +246,27 @@ func TestOutputStrategyWriteToStdout(t *testing.T) {
 	}
 }
 
+func TestOutputStrategyWriteToOutputOrder(t *testing.T) {
+	pWriter := make(testskipper.PathWriter)
+	for _, path := range []string{"/tmp/d", "/tmp/b", "/tmp/e", "/tmp/a", "/tmp/c"} {
+		_, err := pWriter.ReadWriterForPath(path).Write([]byte(path + "\n"))
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+	}
+	var buffer bytes.Buffer
+
+	err := (&OutputStrategy{pWriter}).WriteToOutput(&buffer)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	expected := "/tmp/a\n/tmp/b\n/tmp/c\n/tmp/d\n/tmp/e\n"
+	if buffer.String() != expected {
+		t.Fatalf("Expected output '%s', got '%s'\n", expected, buffer.String())
+	}
+}
+
 func withFixtureFiles(dir string, src string, fileCount int, testFunc func()) {
 	err := os.Mkdir(dir, 0777)
 	if err != nil {
@@ -170,3 +287,505 @@ func withFixtureFiles(dir string, src string, fileCount int, testFunc func()) {
 	}()
 	testFunc()
 }
+
+func TestRunExitCodes(t *testing.T) {
+	testDir := "/tmp/gotestskipper/"
+	src := `
+	package main
+
+	import "testing"
+
+	func TestFoo(t *testing.T) {
+		t.Log("foo")
+	}
+	`
+	withFixtureFiles(testDir, src, 1, func() {
+		cleanFile := path.Join(testDir, "clean_test.go")
+		err := ioutil.WriteFile(cleanFile, []byte("package main\n"), 0644)
+		if err != nil {
+			panic(err)
+		}
+		tests := []struct {
+			args     []string
+			exitCode int
+		}{
+			{[]string{"-l", cleanFile}, 0},
+			{[]string{"-d", cleanFile}, 0},
+			{[]string{"-l", path.Join(testDir, "go1_test.go")}, 1},
+			{[]string{"-d", path.Join(testDir, "go1_test.go")}, 1},
+			{[]string{"-l", testDir}, 1},
+			{[]string{"-l", path.Join(testDir, "missing_test.go")}, 2},
+			{[]string{"-d", path.Join(testDir, "missing_test.go"), path.Join(testDir, "go1_test.go")}, 2},
+		}
+
+		for _, test := range tests {
+			var stdout, stderr bytes.Buffer
+			exitCode := Run(test.args, &stdout, &stderr)
+
+			if exitCode != test.exitCode {
+				t.Fatalf("Expected exit code %d for %v, got %d\n", test.exitCode, test.args, exitCode)
+			}
+		}
+	})
+}
+
+func TestRunGenerateDirective(t *testing.T) {
+	testDir := "/tmp/gotestskipper/"
+	src := `//go:generate go run gen.go
+
+package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+`
+	withFixtureFiles(testDir, src, 1, func() {
+		filePath := path.Join(testDir, "go1_test.go")
+
+		var stdout, stderr bytes.Buffer
+		exitCode := Run([]string{"-w", filePath}, &stdout, &stderr)
+
+		if exitCode != 0 {
+			t.Fatalf("Expected exit code 0, got %d\n", exitCode)
+		}
+		if !strings.Contains(stderr.String(), "go1_test.go: contains a //go:generate directive, edits may be overwritten") {
+			t.Fatalf("Expected a warning, got '%s'\n", stderr.String())
+		}
+		content, _ := ioutil.ReadFile(filePath)
+		if !strings.Contains(string(content), "t.Skip()") {
+			t.Fatalf("Expected file to be written, got \n`%s`\n", content)
+		}
+
+		// Refuse to write
+		if err := ioutil.WriteFile(filePath, []byte(src), 0644); err != nil {
+			panic(err)
+		}
+		stderr.Reset()
+		exitCode = Run([]string{"-w", "-no-generated-edit", filePath}, &stdout, &stderr)
+
+		if exitCode != 0 {
+			t.Fatalf("Expected exit code 0, got %d\n", exitCode)
+		}
+		if !strings.Contains(stderr.String(), "go1_test.go: contains a //go:generate directive, not writing") {
+			t.Fatalf("Expected a warning, got '%s'\n", stderr.String())
+		}
+		content, _ = ioutil.ReadFile(filePath)
+		if string(content) != src {
+			t.Fatalf("Expected file to be untouched, got \n`%s`\n", content)
+		}
+	})
+}
+
+func TestRunListNullSeparated(t *testing.T) {
+	testDir := "/tmp/gotestskipper/"
+	src := `
+	package main
+
+	import "testing"
+
+	func TestFoo(t *testing.T) {
+		t.Log("foo")
+	}
+	`
+	withFixtureFiles(testDir, src, 1, func() {
+		spacedPath := path.Join(testDir, "with space_test.go")
+		err := ioutil.WriteFile(spacedPath, []byte(src), 0644)
+		if err != nil {
+			panic(err)
+		}
+
+		var stdout, stderr bytes.Buffer
+		exitCode := Run([]string{"-l", "-0", testDir}, &stdout, &stderr)
+
+		if exitCode != 1 {
+			t.Fatalf("Expected exit code 1, got %d\n", exitCode)
+		}
+		output := stdout.String()
+		if strings.Contains(output, "\n") {
+			t.Fatalf("Expected no newlines in output, got %q\n", output)
+		}
+		if !strings.HasSuffix(output, "\x00") {
+			t.Fatalf("Expected output to be NUL terminated, got %q\n", output)
+		}
+		paths := strings.Split(strings.TrimSuffix(output, "\x00"), "\x00")
+		sort.Strings(paths)
+		expected := []string{path.Join(testDir, "go1_test.go"), spacedPath}
+		if strings.Join(paths, ",") != strings.Join(expected, ",") {
+			t.Fatalf("Expected paths %q, got %q\n", expected, paths)
+		}
+	})
+}
+
+func TestRunSummary(t *testing.T) {
+	testDir := "/tmp/gotestskipper/"
+	src := `
+	package main
+
+	import "testing"
+
+	func TestFoo(t *testing.T) {
+		t.Log("foo")
+	}
+
+	func TestBar(t *testing.T) {
+		t.Skip()
+	}
+	`
+	withFixtureFiles(testDir, src, 2, func() {
+		var stdout, stderr bytes.Buffer
+		exitCode := Run([]string{"-l", "-summary", testDir}, &stdout, &stderr)
+
+		if exitCode != 1 {
+			t.Fatalf("Expected exit code 1, got %d\n", exitCode)
+		}
+		expected := "gotestskipper: net +2 skips, -0 skips (2 already skipped)\n"
+		if stderr.String() != expected {
+			t.Fatalf("Expected '%s', got '%s'\n", expected, stderr.String())
+		}
+	})
+}
+
+func TestRunSummaryImports(t *testing.T) {
+	testDir := "/tmp/gotestskipper/"
+	src := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+`
+	withFixtureFiles(testDir, src, 3, func() {
+		var stdout, stderr bytes.Buffer
+		exitCode := Run([]string{"-l", "-summary", "-skip-if-env", "SKIP_FLAKY", testDir}, &stdout, &stderr)
+
+		if exitCode != 1 {
+			t.Fatalf("Expected exit code 1, got %d: %s\n", exitCode, stderr.String())
+		}
+		expected := "gotestskipper: net +3 skips, -0 skips (0 already skipped)\ngotestskipper: added import os to 3 files\n"
+		if stderr.String() != expected {
+			t.Fatalf("Expected '%s', got '%s'\n", expected, stderr.String())
+		}
+	})
+}
+
+func TestRunSkipUnskipRoundTrip(t *testing.T) {
+	testDir := "/tmp/gotestskipper/"
+	src := `package main
+
+import "testing"
+
+// TestB does b.
+func TestB(t *testing.T) {
+	t.Parallel()
+	t.Log("b")
+}
+`
+	withFixtureFiles(testDir, src, 1, func() {
+		filePath := path.Join(testDir, "go1_test.go")
+		for _, args := range [][]string{{"-w"}, {"-w", "-surgical"}, {"-w", "-tight-skip"}} {
+			var stdout, stderr bytes.Buffer
+			if exitCode := Run(append(args, filePath), &stdout, &stderr); exitCode != 0 {
+				t.Fatalf("Expected exit code 0, got %d: %s\n", exitCode, stderr.String())
+			}
+			if exitCode := Run(append(args[:1:1], "-u", filePath), &stdout, &stderr); exitCode != 0 {
+				t.Fatalf("Expected exit code 0, got %d: %s\n", exitCode, stderr.String())
+			}
+
+			content, _ := ioutil.ReadFile(filePath)
+			if string(content) != src {
+				t.Fatalf("Expected %v to restore \n`%s`\n\n, got \n`%s`\n", args, src, content)
+			}
+		}
+	})
+}
+
+func TestRunOverlappingArgs(t *testing.T) {
+	testDir := "/tmp/gotestskipper/"
+	src := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+`
+	withFixtureFiles(testDir, src, 2, func() {
+		filePath := path.Join(testDir, "go1_test.go")
+
+		var stdout, stderr bytes.Buffer
+		exitCode := Run([]string{"-w", "-summary", testDir, filePath, "/tmp/gotestskipper/../gotestskipper"}, &stdout, &stderr)
+
+		if exitCode != 0 {
+			t.Fatalf("Expected exit code 0, got %d: %s\n", exitCode, stderr.String())
+		}
+		for _, name := range []string{"go1_test.go", "go2_test.go"} {
+			content, _ := ioutil.ReadFile(path.Join(testDir, name))
+			if count := strings.Count(string(content), "t.Skip()"); count != 1 {
+				t.Fatalf("Expected %s to be skipped once, got \n`%s`\n", name, content)
+			}
+		}
+		expected := "gotestskipper: net +2 skips, -0 skips (0 already skipped)\n"
+		if stderr.String() != expected {
+			t.Fatalf("Expected '%s', got '%s'\n", expected, stderr.String())
+		}
+	})
+}
+
+func TestRunCache(t *testing.T) {
+	testDir := "/tmp/gotestskipper/"
+	src := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+`
+	withFixtureFiles(testDir, src, 2, func() {
+		cachePath := path.Join(testDir, ".gotestskipper.cache")
+		args := []string{"-w", "-summary", "-cache", cachePath, testDir}
+
+		var stdout, stderr bytes.Buffer
+		exitCode := Run(args, &stdout, &stderr)
+
+		if exitCode != 0 {
+			t.Fatalf("Expected exit code 0, got %d: %s\n", exitCode, stderr.String())
+		}
+
+		stderr.Reset()
+		exitCode = Run(args, &stdout, &stderr)
+
+		if exitCode != 0 {
+			t.Fatalf("Expected exit code 0, got %d: %s\n", exitCode, stderr.String())
+		}
+		expected := "gotestskipper: net +0 skips, -0 skips (0 already skipped)\n"
+		if stderr.String() != expected {
+			t.Fatalf("Expected unchanged files to be skipped with '%s', got '%s'\n", expected, stderr.String())
+		}
+		for _, name := range []string{"go1_test.go", "go2_test.go"} {
+			content, _ := ioutil.ReadFile(path.Join(testDir, name))
+			if count := strings.Count(string(content), "t.Skip()"); count != 1 {
+				t.Fatalf("Expected %s to be skipped once, got \n`%s`\n", name, content)
+			}
+		}
+
+		// a changed file invalidates its entry
+		changedPath := path.Join(testDir, "go2_test.go")
+		content, _ := ioutil.ReadFile(changedPath)
+		content = append(content, "\nfunc TestBar(t *testing.T) {\n\tt.Log(\"bar\")\n}\n"...)
+		if err := ioutil.WriteFile(changedPath, content, 0644); err != nil {
+			panic(err)
+		}
+		stderr.Reset()
+		exitCode = Run(args, &stdout, &stderr)
+
+		if exitCode != 0 {
+			t.Fatalf("Expected exit code 0, got %d: %s\n", exitCode, stderr.String())
+		}
+		unchanged, _ := ioutil.ReadFile(path.Join(testDir, "go1_test.go"))
+		if count := strings.Count(string(unchanged), "t.Skip()"); count != 1 {
+			t.Fatalf("Expected go1_test.go to be skipped once, got \n`%s`\n", unchanged)
+		}
+		changed, _ := ioutil.ReadFile(changedPath)
+		if !strings.Contains(string(changed), "func TestBar(t *testing.T) {\n\tt.Skip()") {
+			t.Fatalf("Expected the changed file to be processed, got \n`%s`\n", changed)
+		}
+	})
+}
+
+func TestRunNameParam(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	src := `package main
+
+import "testing"
+
+func TestFoo(*testing.T) {
+	println("foo")
+}
+`
+	filePath := path.Join(dir, "foo_test.go")
+	if err := ioutil.WriteFile(filePath, []byte(src), 0644); err != nil {
+		panic(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	exitCode := Run([]string{"-name-param", "tt", filePath}, &stdout, &stderr)
+
+	if exitCode != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s\n", exitCode, stderr.String())
+	}
+	expected := "func TestFoo(tt *testing.T) {\n\ttt.Skip()\n"
+	if !strings.Contains(stdout.String(), expected) {
+		t.Fatalf("Expected output to contain \n`%s`\n\n, got \n`%s`\n", expected, stdout.String())
+	}
+
+	for _, name := range []string{"", "_", "1t"} {
+		stdout.Reset()
+		stderr.Reset()
+		exitCode := Run([]string{"-name-param", name, filePath}, &stdout, &stderr)
+
+		if exitCode != exitCodeError {
+			t.Fatalf("Expected exit code %d for %q, got %d\n", exitCodeError, name, exitCode)
+		}
+	}
+}
+
+// recordingHandler is a slog.Handler collecting all records
+type recordingHandler struct {
+	records []slog.Record
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordingHandler) Handle(_ context.Context, record slog.Record) error {
+	h.records = append(h.records, record)
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *recordingHandler) WithGroup(string) slog.Handler { return h }
+
+func TestRunWithLogger(t *testing.T) {
+	testDir := "/tmp/gotestskipper/"
+	src := `//go:generate go run gen.go
+
+package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+`
+	withFixtureFiles(testDir, src, 1, func() {
+		filePath := path.Join(testDir, "go1_test.go")
+		handler := &recordingHandler{}
+
+		var stdout, stderr bytes.Buffer
+		exitCode := RunWithLogger([]string{"-w", filePath, path.Join(testDir, "missing_test.go")}, &stdout, &stderr, slog.New(handler))
+
+		if exitCode != 2 {
+			t.Fatalf("Expected exit code 2, got %d\n", exitCode)
+		}
+		var events []string
+		for _, record := range handler.records {
+			event := record.Level.String() + " " + record.Message
+			record.Attrs(func(attr slog.Attr) bool {
+				if attr.Key == "test" {
+					event += " " + attr.Value.String()
+				}
+				return true
+			})
+			events = append(events, event)
+		}
+		expected := []string{
+			"DEBUG processing path",
+			"DEBUG processed path",
+			"INFO changed test TestFoo",
+			"WARN " + filePath + ": contains a //go:generate directive, edits may be overwritten",
+			"DEBUG processing path",
+			"ERROR stat " + path.Join(testDir, "missing_test.go") + ": no such file or directory",
+		}
+		if strings.Join(events, "\n") != strings.Join(expected, "\n") {
+			t.Fatalf("Expected events\n%s\n\tgot\n%s\n", strings.Join(expected, "\n"), strings.Join(events, "\n"))
+		}
+	})
+}
+
+func TestRunLogFormat(t *testing.T) {
+	testDir := "/tmp/gotestskipper/"
+	src := `
+	package main
+
+	import "testing"
+
+	func TestFoo(t *testing.T) {
+		t.Log("foo")
+	}
+	`
+	withFixtureFiles(testDir, src, 1, func() {
+		var stdout, stderr bytes.Buffer
+		exitCode := Run([]string{"-l", "-log", "json", testDir}, &stdout, &stderr)
+
+		if exitCode != 1 {
+			t.Fatalf("Expected exit code 1, got %d\n", exitCode)
+		}
+		if !strings.Contains(stderr.String(), `"msg":"changed test","action":"skip","test":"TestFoo"`) {
+			t.Fatalf("Expected a JSON record, got '%s'\n", stderr.String())
+		}
+		if strings.Contains(stderr.String(), "processing path") {
+			t.Fatalf("Expected no debug records, got '%s'\n", stderr.String())
+		}
+
+		exitCode = Run([]string{"-log", "xml", testDir}, &stdout, &stderr)
+
+		if exitCode != 2 {
+			t.Fatalf("Expected exit code 2, got %d\n", exitCode)
+		}
+	})
+}
+
+func TestOutputStrategyWriteToDir(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(outDir)
+	path := "/tmp/bar"
+	content := "foo"
+
+	pWriter := make(testskipper.PathWriter)
+	writer := pWriter.ReadWriterForPath(path)
+	_, err = writer.Write([]byte(content))
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+
+	strategy := &OutputStrategy{pWriter}
+	err = strategy.WriteToDir(outDir, false)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	target, _ := outputPath(outDir, path)
+	fileContent, err := ioutil.ReadFile(target)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if string(fileContent) != content {
+		t.Fatalf("Expected fileContent '%s', got '%s'\n", content, fileContent)
+	}
+
+	// No clobber
+	pWriter = make(testskipper.PathWriter)
+	pWriter.ReadWriterForPath(path).Write([]byte("bar"))
+	strategy = &OutputStrategy{pWriter}
+	err = strategy.WriteToDir(outDir, false)
+
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	fileContent, _ = ioutil.ReadFile(target)
+	if string(fileContent) != content {
+		t.Fatalf("Expected fileContent '%s' to be preserved, got '%s'\n", content, fileContent)
+	}
+
+	// Force
+	err = strategy.WriteToDir(outDir, true)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	fileContent, _ = ioutil.ReadFile(target)
+	if string(fileContent) != "bar" {
+		t.Fatalf("Expected fileContent 'bar', got '%s'\n", fileContent)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// nameSet holds test names and name patterns. Looking up a name is a
+// constant time operation, regardless of the number of names, plus one
+// match per pattern.
+type nameSet struct {
+	names    map[string]struct{}
+	patterns []*regexp.Regexp
+}
+
+// newNameSet returns a nameSet of the given names. Entries which are not
+// valid Go identifiers are compiled as regular expressions.
+func newNameSet(entries []string) (*nameSet, error) {
+	set := &nameSet{names: make(map[string]struct{}, len(entries))}
+	for _, entry := range entries {
+		if token.IsIdentifier(entry) {
+			set.names[entry] = struct{}{}
+			continue
+		}
+		pattern, err := regexp.Compile(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", entry, err)
+		}
+		set.patterns = append(set.patterns, pattern)
+	}
+	return set, nil
+}
+
+// Contains reports whether name is one of the names of s or matches one of
+// its patterns
+func (s *nameSet) Contains(name string) bool {
+	if _, ok := s.names[name]; ok {
+		return true
+	}
+	for _, pattern := range s.patterns {
+		if pattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter implements testskipper.FuncFilter
+func (s *nameSet) Filter(fileSet *token.FileSet, funcDecl *ast.FuncDecl) bool {
+	return s.Contains(funcDecl.Name.Name)
+}
+
+// readNames reads the names file at path
+func readNames(path string) (*nameSet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	set, err := parseNames(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return set, nil
+}
+
+// parseNames reads a nameSet from r. Each non-empty line not starting with #
+// holds either a test name or a regular expression matching test names,
+// e.g.
+//
+//	TestFoo
+//	^TestIntegration
+func parseNames(r io.Reader) (*nameSet, error) {
+	var entries []string
+	lines := bufio.NewScanner(r)
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	if err := lines.Err(); err != nil {
+		return nil, err
+	}
+	return newNameSet(entries)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+	"testing"
+)
+
+func TestParseNames(t *testing.T) {
+	namesFile := `
+	# quarantined tests
+	TestFoo
+	^TestIntegration
+	`
+	names, err := parseNames(strings.NewReader(namesFile))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	tests := map[string]bool{
+		"TestFoo":            true,
+		"TestFooBar":         false,
+		"TestIntegrationFoo": true,
+		"TestFooIntegration": false,
+		"TestBar":            false,
+	}
+	for name, expected := range tests {
+		if actual := names.Filter(nil, &ast.FuncDecl{Name: ast.NewIdent(name)}); actual != expected {
+			t.Fatalf("Expected %v for %s, got %v\n", expected, name, actual)
+		}
+	}
+
+	_, err = parseNames(strings.NewReader("Test(Foo\n"))
+
+	if err == nil {
+		t.Fatalf("Expected an error for an invalid pattern\n")
+	}
+}
+
+// sizedNameSet returns a nameSet of size distinct names
+func sizedNameSet(tb testing.TB, size int) *nameSet {
+	entries := make([]string, size)
+	for i := range entries {
+		entries[i] = fmt.Sprintf("TestName%d", i)
+	}
+	names, err := newNameSet(entries)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return names
+}
+
+// TestNameSetLookupAllocs checks that a lookup in a large nameSet
+// allocates no more than one in a small nameSet. The time of a lookup is
+// measured by BenchmarkNameSet instead.
+func TestNameSetLookupAllocs(t *testing.T) {
+	funcDecl := &ast.FuncDecl{Name: ast.NewIdent("TestMissing")}
+	allocs := func(size int) float64 {
+		names := sizedNameSet(t, size)
+		return testing.AllocsPerRun(100, func() {
+			names.Filter(nil, funcDecl)
+		})
+	}
+
+	small := allocs(100)
+	large := allocs(50000)
+
+	if large != small {
+		t.Fatalf("Expected %v allocations per lookup for 50000 names, got %v\n", small, large)
+	}
+}
+
+// BenchmarkNameSet measures the cost of filtering a single function. It
+// must not grow with the number of names.
+func BenchmarkNameSet(b *testing.B) {
+	for _, size := range []int{100, 50000} {
+		names := sizedNameSet(b, size)
+		funcDecl := &ast.FuncDecl{Name: ast.NewIdent("TestMissing")}
+		b.Run(fmt.Sprintf("%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				names.Filter(nil, funcDecl)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// listedPackage is the part of a package printed by go list -json which
+// -packages reads
+type listedPackage struct {
+	Dir          string
+	TestGoFiles  []string
+	XTestGoFiles []string
+}
+
+// goListTestFiles runs go list with the package pattern and returns the
+// test files go test would compile for the matched packages, including the
+// ones of external _test packages. The tags of buildContext are passed on,
+// buildContext may be nil.
+func goListTestFiles(pattern string, buildContext *build.Context) ([]string, error) {
+	args := []string{"list", "-json=Dir,TestGoFiles,XTestGoFiles"}
+	if buildContext != nil && len(buildContext.BuildTags) > 0 {
+		args = append(args, "-tags", strings.Join(buildContext.BuildTags, ","))
+	}
+	cmd := exec.Command("go", append(args, "--", pattern)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return nil, fmt.Errorf("%s: go list: %s", pattern, message)
+	}
+	return parsePackageList(bytes.NewReader(out))
+}
+
+// parsePackageList parses the packages printed by go list -json, a stream of
+// JSON objects, and returns the paths of their test files
+func parsePackageList(r io.Reader) ([]string, error) {
+	var paths []string
+	decoder := json.NewDecoder(r)
+	for {
+		var pkg listedPackage
+		err := decoder.Decode(&pkg)
+		if err == io.EOF {
+			return paths, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unexpected go list output: %v", err)
+		}
+		for _, name := range append(pkg.TestGoFiles, pkg.XTestGoFiles...) {
+			paths = append(paths, filepath.Join(pkg.Dir, name))
+		}
+	}
+}
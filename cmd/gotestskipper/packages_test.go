@@ -0,0 +1,80 @@
+package main
+
+import (
+	"go/build"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePackageList(t *testing.T) {
+	fixture, err := os.Open("testdata/go_list.json")
+	if err != nil {
+		panic(err)
+	}
+	defer fixture.Close()
+
+	paths, err := parsePackageList(fixture)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	expected := []string{filepath.Join("/src/foo", "foo_test.go"), filepath.Join("/src/foo", "foo_ext_test.go")}
+	if !reflect.DeepEqual(expected, paths) {
+		t.Fatalf("Expected paths to equal\n%v\n\tgot\n%v\n", expected, paths)
+	}
+
+	_, err = parsePackageList(strings.NewReader("{\"Dir\": "))
+
+	if err == nil {
+		t.Fatalf("Expected an error for unexpected output\n")
+	}
+}
+
+func TestGoListTestFiles(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not available")
+	}
+	t.Setenv("GO111MODULE", "on")
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOTOOLCHAIN", "local")
+	dir, err := filepath.Abs("testdata/packages")
+	if err != nil {
+		panic(err)
+	}
+	t.Chdir(dir)
+
+	tests := []struct {
+		tags     []string
+		expected []string
+	}{
+		{nil, []string{"foo_test.go", "foo_ext_test.go", "bar/bar_test.go"}},
+		{[]string{"integration"}, []string{"foo_test.go", "integration_test.go", "foo_ext_test.go", "bar/bar_test.go"}},
+	}
+	for _, test := range tests {
+		buildContext := build.Default
+		buildContext.BuildTags = test.tags
+
+		paths, err := goListTestFiles("./...", &buildContext)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		var expected []string
+		for _, name := range test.expected {
+			expected = append(expected, filepath.Join(dir, name))
+		}
+		if !reflect.DeepEqual(expected, paths) {
+			t.Fatalf("Expected paths with tags %v to equal\n%v\n\tgot\n%v\n", test.tags, expected, paths)
+		}
+	}
+
+	_, err = goListTestFiles("./missing", nil)
+
+	if err == nil || !strings.Contains(err.Error(), "./missing: go list: ") {
+		t.Fatalf("Expected a go list error, got %v\n", err)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+const defaultProgressInterval = time.Second
+
+// progress reports the number of processed files, printing at most once
+// per interval
+type progress struct {
+	output      io.Writer
+	clock       clock
+	interval    time.Duration
+	files       int
+	lastPrinted time.Time
+}
+
+func newProgress(output io.Writer, clock clock, interval time.Duration) *progress {
+	return &progress{
+		output:   output,
+		clock:    clock,
+		interval: interval,
+	}
+}
+
+// Add records n more processed files and prints the total if the interval
+// has passed since the last print
+func (p *progress) Add(n int) {
+	p.files += n
+	now := p.clock.Now()
+	if !p.lastPrinted.IsZero() && now.Sub(p.lastPrinted) < p.interval {
+		return
+	}
+	p.lastPrinted = now
+	p.print()
+}
+
+// Done prints the final total
+func (p *progress) Done() {
+	p.print()
+}
+
+func (p *progress) print() {
+	fmt.Fprintf(p.output, "gotestskipper: processed %d files\n", p.files)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.now = f.now.Add(d)
+}
+
+func TestProgress(t *testing.T) {
+	var buffer bytes.Buffer
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	p := newProgress(&buffer, clock, time.Second)
+
+	p.Add(1) // printed, first update
+	clock.Advance(500 * time.Millisecond)
+	p.Add(2) // throttled
+	clock.Advance(500 * time.Millisecond)
+	p.Add(3) // printed, interval passed
+	clock.Advance(100 * time.Millisecond)
+	p.Add(4) // throttled
+	p.Done()
+
+	expected := []string{
+		"gotestskipper: processed 1 files",
+		"gotestskipper: processed 6 files",
+		"gotestskipper: processed 10 files",
+	}
+	actual := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", strings.Join(expected, "\n"), buffer.String())
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mitch000001/go-tools/testskipper"
+)
+
+// readRules reads the rules file at path
+func readRules(path string) ([]testskipper.Rule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rules, err := parseRules(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return rules, nil
+}
+
+// parseRules reads rules from r. Each non-empty line not starting with #
+// defines one rule of the form
+//
+//	<kind>:<pattern> <action> [reason]
+//
+// where kind is one of name, regex or comment and action is one of skip,
+// short or unskip. The pattern and reason may be quoted, e.g.
+//
+//	regex:Integration skip "integration test"
+//	comment:"takes long" short
+//	name:TestFoo unskip
+func parseRules(r io.Reader) ([]testskipper.Rule, error) {
+	var rules []testskipper.Rule
+	lines := bufio.NewScanner(r)
+	for lineNumber := 1; lines.Scan(); lineNumber++ {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := parseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, lines.Err()
+}
+
+func parseRule(line string) (testskipper.Rule, error) {
+	var rule testskipper.Rule
+	fields, err := splitQuoted(line)
+	if err != nil {
+		return rule, err
+	}
+	if len(fields) < 2 || len(fields) > 3 {
+		return rule, fmt.Errorf("expected <kind>:<pattern> <action> [reason], got %q", line)
+	}
+	kind, pattern, found := strings.Cut(fields[0], ":")
+	if !found {
+		return rule, fmt.Errorf("missing matcher kind in %q", fields[0])
+	}
+	pattern, err = unquote(pattern)
+	if err != nil {
+		return rule, err
+	}
+	switch kind {
+	case "name":
+		rule.Matcher = testskipper.NameMatcher(pattern)
+	case "regex":
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return rule, err
+		}
+		rule.Matcher = testskipper.RegexpMatcher(re)
+	case "comment":
+		rule.Matcher = testskipper.CommentMatcher(pattern)
+	default:
+		return rule, fmt.Errorf("unknown matcher kind %q", kind)
+	}
+	var reason string
+	if len(fields) == 3 {
+		reason = fields[2]
+	}
+	switch fields[1] {
+	case "skip":
+		rule.Action = testskipper.SkipTestVisitorActionWithReason(reason)
+	case "short":
+		rule.Action = testskipper.SkipInShortModeVisitorAction(reason)
+	case "unskip":
+		rule.Action = testskipper.UnskipTestVisitorAction
+	default:
+		return rule, fmt.Errorf("unknown action %q", fields[1])
+	}
+	return rule, nil
+}
+
+// splitQuoted splits s at white space, keeping double quoted strings
+// together and unquoting them
+func splitQuoted(s string) ([]string, error) {
+	var fields []string
+	for {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			return fields, nil
+		}
+		if s[0] == '"' {
+			quoted, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted string in %q", s)
+			}
+			field, _ := strconv.Unquote(quoted)
+			fields = append(fields, field)
+			s = s[len(quoted):]
+			continue
+		}
+		end := strings.IndexFunc(s, unicode.IsSpace)
+		if end == -1 {
+			end = len(s)
+		}
+		// a quoted pattern following the matcher kind, e.g. comment:"takes long"
+		if colon := strings.Index(s[:end], ":\""); colon != -1 {
+			quoted, err := strconv.QuotedPrefix(s[colon+1:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted string in %q", s)
+			}
+			end = colon + 1 + len(quoted)
+		}
+		fields = append(fields, s[:end])
+		s = s[end:]
+	}
+}
+
+func unquote(s string) (string, error) {
+	if strings.HasPrefix(s, "\"") {
+		return strconv.Unquote(s)
+	}
+	return s, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/mitch000001/go-tools/testskipper"
+)
+
+func TestParseRules(t *testing.T) {
+	rulesFile := `
+	# skip integration tests, guard slow ones
+	regex:Integration skip "integration"
+	comment:"takes long" short
+	`
+	rules, err := parseRules(strings.NewReader(rulesFile))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if len(rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %d\n", len(rules))
+	}
+
+	src := `
+	package main
+
+	import "testing"
+
+	func TestIntegrationFoo(t *testing.T) {
+		t.Log("foo")
+	}
+
+	// TestBar takes long
+	func TestBar(t *testing.T) {
+		t.Log("bar")
+	}`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+
+	ast.Walk(testskipper.NewTestFuncVisitor(testskipper.RulesVisitAction(rules)), file)
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+
+	expected := `
+	package main
+
+	import "testing"
+
+	// gotestskipper:skip
+	func TestIntegrationFoo(t *testing.T) {
+		t.Skip("integration")
+
+		t.Log("foo")
+	}
+
+	// TestBar takes long
+	// gotestskipper:skip
+	func TestBar(t *testing.T) {
+		if testing.Short() {
+			t.Skip()
+		}
+
+		t.Log("bar")
+	}`
+	expected = replacer.Replace(expected)
+	actual := replacer.Replace(buffer.String())
+
+	if expected != actual {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+	}
+
+	// Invalid rules
+	for _, rulesFile := range []string{
+		"regex:Foo",
+		"Foo skip",
+		"foo:Bar skip",
+		"regex:( skip",
+		"name:Foo delete",
+		`name:Foo skip "unterminated`,
+	} {
+		if _, err := parseRules(strings.NewReader(rulesFile)); err == nil {
+			t.Fatalf("Expected an error for `%s`\n", rulesFile)
+		}
+	}
+}
@@ -0,0 +1,910 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	runSrc = `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+`
+	runSkippedSrc = `package main
+
+import "testing"
+
+// gotestskipper:skip
+func TestFoo(t *testing.T) {
+	t.Skip()
+
+	t.Log("foo")
+}
+`
+	runTightSkippedSrc = `package main
+
+import "testing"
+
+// gotestskipper:skip
+func TestFoo(t *testing.T) {
+	t.Skip()
+	t.Log("foo")
+}
+`
+	runBenchSrc = `package main
+
+import "testing"
+
+func BenchmarkFoo(b *testing.B) {
+	b.Log("foo")
+}
+`
+	runBenchSkippedSrc = `package main
+
+import "testing"
+
+// gotestskipper:skip
+func BenchmarkFoo(b *testing.B) {
+	b.Skip()
+
+	b.Log("foo")
+}
+`
+	runDiff = "--- {dir}/foo_test.go.orig\n" +
+		"+++ {dir}/foo_test.go\n" +
+		"@@ -2,6 +2,9 @@\n" +
+		" \n" +
+		" import \"testing\"\n" +
+		" \n" +
+		"+// gotestskipper:skip\n" +
+		" func TestFoo(t *testing.T) {\n" +
+		"+\tt.Skip()\n" +
+		"+\n" +
+		" \tt.Log(\"foo\")\n" +
+		" }\n"
+)
+
+// TestRun documents the contract of the command by running it on temporary
+// files. In args and the expectations, {dir} is replaced by the directory
+// holding the files.
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		// stdin is the source read for the path -
+		stdin    string
+		args     []string
+		exitCode int
+		// stdout is compared ignoring whitespace
+		stdout string
+		// stderr has to be contained in the actual stderr
+		stderr string
+		// expected file contents after the run compared ignoring
+		// whitespace, unlisted files are expected to be unchanged
+		expected map[string]string
+	}{
+		{
+			name:   "skip file to stdout",
+			files:  map[string]string{"foo_test.go": runSrc},
+			args:   []string{"{dir}/foo_test.go"},
+			stdout: runSkippedSrc,
+		},
+		{
+			name:     "skip file in place without blank line",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-w", "-tight-skip", "{dir}/foo_test.go"},
+			expected: map[string]string{"foo_test.go": runTightSkippedSrc},
+		},
+		{
+			name:   "skip directory to stdout",
+			files:  map[string]string{"foo_test.go": runSrc, "bar_test.go": runSrc},
+			args:   []string{"{dir}"},
+			stdout: runSkippedSrc + runSkippedSrc,
+		},
+		{
+			name:     "skip file in place",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-w", "{dir}/foo_test.go"},
+			expected: map[string]string{"foo_test.go": runSkippedSrc},
+		},
+		{
+			name:     "skip directory in place",
+			files:    map[string]string{"foo_test.go": runSrc, "bar_test.go": runSrc},
+			args:     []string{"-w", "{dir}"},
+			expected: map[string]string{"foo_test.go": runSkippedSrc, "bar_test.go": runSkippedSrc},
+		},
+		{
+			name:     "unskip directory in place",
+			files:    map[string]string{"foo_test.go": runSkippedSrc, "bar_test.go": runSkippedSrc},
+			args:     []string{"-u", "-w", "{dir}"},
+			expected: map[string]string{"foo_test.go": runSrc, "bar_test.go": runSrc},
+		},
+		{
+			name:     "skip packages recursively in place",
+			files:    map[string]string{"foo_test.go": runSrc, "bar/bar_test.go": runSrc, "bar/testdata/baz_test.go": runSrc},
+			args:     []string{"-w", "{dir}/..."},
+			expected: map[string]string{"foo_test.go": runSkippedSrc, "bar/bar_test.go": runSkippedSrc},
+		},
+		{
+			name:     "skip glob in place",
+			files:    map[string]string{"foo_test.go": runSrc, "bar.go": runSrc},
+			args:     []string{"-w", "{dir}/*_test.go"},
+			expected: map[string]string{"foo_test.go": runSkippedSrc},
+		},
+		{
+			name:     "skip benchmarks in place",
+			files:    map[string]string{"foo_test.go": runBenchSrc},
+			args:     []string{"-w", "-bench", "{dir}/foo_test.go"},
+			expected: map[string]string{"foo_test.go": runBenchSkippedSrc},
+		},
+		{
+			name:     "skip with reason",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-w", "-reason", `flaky on "CI" #1234`, "{dir}/foo_test.go"},
+			expected: map[string]string{"foo_test.go": strings.Replace(runSkippedSrc, "t.Skip()", `t.Skip("flaky on \"CI\" #1234")`, 1)},
+		},
+		{
+			name:     "config file",
+			files:    map[string]string{"foo_test.go": runSrc, "legacy/bar_test.go": runSrc, "gotestskipper.yaml": "reason: \"flaky\"\nw: true\nexclude:\n  - legacy\n"},
+			args:     []string{"-config", "{dir}/gotestskipper.yaml", "{dir}/foo_test.go", "{dir}/legacy"},
+			expected: map[string]string{"foo_test.go": strings.Replace(runSkippedSrc, "t.Skip()", `t.Skip("flaky")`, 1)},
+		},
+		{
+			name:     "exclude",
+			files:    map[string]string{"foo_test.go": runSrc, "bar_generated_test.go": "package\n", "legacy/baz_test.go": runSrc},
+			args:     []string{"-w", "-exclude", "*_generated_test.go", "-exclude", "legacy", "{dir}", "{dir}/legacy/baz_test.go"},
+			expected: map[string]string{"foo_test.go": runSkippedSrc},
+		},
+		{
+			name:     "exclude invalid pattern",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-exclude", "[", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "syntax error in pattern",
+		},
+		{
+			name:     "build tags",
+			files:    map[string]string{"foo_test.go": runSrc, "bar_test.go": "//go:build integration\n\n" + runSrc, "baz_test.go": "//go:build ignore\n\n" + runSrc},
+			args:     []string{"-w", "-build-tags", "integration", "{dir}", "{dir}/baz_test.go"},
+			expected: map[string]string{"foo_test.go": runSkippedSrc, "bar_test.go": "//go:build integration\n\n" + runSkippedSrc},
+		},
+		{
+			name:     "build context without tags",
+			files:    map[string]string{"foo_test.go": runSrc, "bar_test.go": "//go:build integration\n\n" + runSrc},
+			args:     []string{"-w", "-build-tags=", "{dir}"},
+			expected: map[string]string{"foo_test.go": runSkippedSrc},
+		},
+		{
+			name:     "flags override config file",
+			files:    map[string]string{"foo_test.go": runSrc, "gotestskipper.yaml": "reason: flaky\nw: true\n"},
+			args:     []string{"-config", "{dir}/gotestskipper.yaml", "-reason", "slow", "{dir}/foo_test.go"},
+			expected: map[string]string{"foo_test.go": strings.Replace(runSkippedSrc, "t.Skip()", `t.Skip("slow")`, 1)},
+		},
+		{
+			name:     "config file with unknown flag",
+			files:    map[string]string{"foo_test.go": runSrc, "gotestskipper.yaml": "reasons: flaky\n"},
+			args:     []string{"-config", "{dir}/gotestskipper.yaml", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   `{dir}/gotestskipper.yaml: line 1: unknown flag "reasons"`,
+		},
+		{
+			name:     "skip if env",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-w", "-skip-if-env", "SKIP_FLAKY", "{dir}/foo_test.go"},
+			expected: map[string]string{"foo_test.go": strings.NewReplacer(`import "testing"`, "import (\n\t\"os\"\n\t\"testing\"\n)", "t.Skip()", "if os.Getenv(\"SKIP_FLAKY\") != \"\" {\n\t\tt.Skip()\n\t}").Replace(runSkippedSrc)},
+		},
+		{
+			name:     "unskip if env",
+			files:    map[string]string{"foo_test.go": strings.Replace(runSkippedSrc, "t.Skip()", "if os.Getenv(\"SKIP_FLAKY\") != \"\" {\nt.Skip()\n}\nt.Skip()", 1)},
+			args:     []string{"-u", "-w", "-skip-if-env", "SKIP_FLAKY", "{dir}/foo_test.go"},
+			expected: map[string]string{"foo_test.go": runSkippedSrc},
+		},
+		{
+			name:  "json summary",
+			files: map[string]string{"foo_test.go": runSrc, "bar_test.go": runSkippedSrc},
+			args:  []string{"-json", "-w", "{dir}"},
+			stdout: `[
+  {
+    "path": "{dir}/bar_test.go",
+    "action": "skip",
+    "functions": [],
+    "changed": false,
+    "imports": []
+  },
+  {
+    "path": "{dir}/foo_test.go",
+    "action": "skip",
+    "functions": [
+      "TestFoo"
+    ],
+    "changed": true,
+    "imports": []
+  }
+]
+`,
+			expected: map[string]string{"foo_test.go": runSkippedSrc},
+		},
+		{
+			name:  "json summary of nothing",
+			files: map[string]string{"foo_test.go": runSrc},
+			args:  []string{"-format", "json", "-run", "TestBar", "{dir}/foo_test.go"},
+			stdout: `[
+  {
+    "path": "{dir}/foo_test.go",
+    "action": "skip",
+    "functions": [],
+    "changed": false,
+    "imports": []
+  }
+]
+`,
+		},
+		{
+			name:  "json summary of import changes",
+			files: map[string]string{"foo_test.go": runSrc},
+			args:  []string{"-json", "-skip-if-env", "SKIP_FLAKY", "{dir}/foo_test.go"},
+			stdout: `[
+  {
+    "path": "{dir}/foo_test.go",
+    "action": "skip",
+    "functions": [
+      "TestFoo"
+    ],
+    "changed": true,
+    "imports": [
+      {
+        "path": "os",
+        "change": "added"
+      }
+    ]
+  }
+]
+`,
+		},
+		{
+			name:     "json and other format",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-json", "-format", "github", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "-json and -format github are mutually exclusive",
+		},
+		{
+			name:     "unskip with reason",
+			files:    map[string]string{"foo_test.go": strings.Replace(runSkippedSrc, "t.Skip()", `t.Skip("flaky on CI #1234")`, 1)},
+			args:     []string{"-u", "-w", "{dir}/foo_test.go"},
+			expected: map[string]string{"foo_test.go": runSrc},
+		},
+		{
+			name:   "unskip file to stdout",
+			files:  map[string]string{"foo_test.go": runSkippedSrc},
+			args:   []string{"-u", "{dir}/foo_test.go"},
+			stdout: runSrc,
+		},
+		{
+			name:     "unskip file in place",
+			files:    map[string]string{"foo_test.go": runSkippedSrc},
+			args:     []string{"-u", "-w", "{dir}/foo_test.go"},
+			expected: map[string]string{"foo_test.go": runSrc},
+		},
+		{
+			name:     "skip tests matching -run",
+			files:    map[string]string{"foo_test.go": runSrc, "bar_test.go": strings.Replace(runSrc, "TestFoo", "TestNetworkFoo", 1)},
+			args:     []string{"-w", "-run", "TestNetwork.*", "{dir}"},
+			expected: map[string]string{"bar_test.go": strings.Replace(runSkippedSrc, "TestFoo", "TestNetworkFoo", 1)},
+		},
+		{
+			name:     "unskip tests matching -run",
+			files:    map[string]string{"foo_test.go": runSkippedSrc, "bar_test.go": strings.Replace(runSkippedSrc, "TestFoo", "TestNetworkFoo", 1)},
+			args:     []string{"-u", "-w", "-run", "^TestNetwork", "{dir}"},
+			expected: map[string]string{"bar_test.go": strings.Replace(runSrc, "TestFoo", "TestNetworkFoo", 1)},
+		},
+		{
+			name:     "invalid -run pattern",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-w", "-run", "Test(", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "invalid -run pattern \"Test(\"",
+		},
+		{
+			name:     "unskip nested skip",
+			files:    map[string]string{"foo_test.go": strings.Replace(runSrc, "\tt.Log", "\tif testing.Short() {\n\t\tt.Skip()\n\t}\n\tt.Log", 1)},
+			args:     []string{"-u", "-unskip-nested", "-w", "{dir}/foo_test.go"},
+			expected: map[string]string{"foo_test.go": strings.Replace(runSrc, "\tt.Log", "\tif testing.Short() {\n\t}\n\tt.Log", 1)},
+		},
+		{
+			name:     "verbose counts per file",
+			files:    map[string]string{"foo_test.go": runSrc + "\nfunc TestBar(t *testing.T) {\n\tt.Log(\"bar\")\n}\n", "bar_test.go": runSkippedSrc},
+			args:     []string{"-v", "-l", "{dir}"},
+			exitCode: 1,
+			stdout:   "{dir}/foo_test.go\n",
+			stderr:   "gotestskipper: {dir}/foo_test.go: skipped 2 tests\ngotestskipper: skipped 2 tests in 1 files\n",
+		},
+		{
+			name:     "skip tests with build tag",
+			files:    map[string]string{"foo_test.go": "//go:build integration\n\n" + runSrc, "bar_test.go": "// +build integration\n\n" + runSrc, "baz_test.go": runSrc},
+			args:     []string{"-w", "-tag", "integration", "{dir}"},
+			expected: map[string]string{"foo_test.go": "//go:build integration\n\n" + runSkippedSrc, "bar_test.go": "//go:build integration\n// +build integration\n\n" + runSkippedSrc},
+		},
+		{
+			name:     "skip subtests in place",
+			files:    map[string]string{"foo_test.go": strings.Replace(runSrc, "\tt.Log(\"foo\")\n", "\tt.Run(\"network\", func(st *testing.T) {\n\t\tst.Log(\"foo\")\n\t})\n", 1)},
+			args:     []string{"-w", "-subtest", "^net", "{dir}/foo_test.go"},
+			expected: map[string]string{"foo_test.go": strings.Replace(runSrc, "\tt.Log(\"foo\")\n", "\tt.Run(\"network\", func(st *testing.T) {\n\t\tst.Skip()\n\n\t\tst.Log(\"foo\")\n\t})\n", 1)},
+		},
+		{
+			name:     "list changed file",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-l", "{dir}/foo_test.go"},
+			exitCode: 1,
+			stdout:   "{dir}/foo_test.go\n",
+		},
+		{
+			name:  "list unchanged file",
+			files: map[string]string{"foo_test.go": runSrc},
+			args:  []string{"-u", "-l", "{dir}/foo_test.go"},
+		},
+		{
+			name:     "diff changed file",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-d", "{dir}/foo_test.go"},
+			exitCode: 1,
+			stdout:   runDiff,
+		},
+		{
+			name:     "diff changed file with long flag",
+			files:    map[string]string{"foo_test.go": runSrc, "bar_test.go": runSkippedSrc},
+			args:     []string{"-diff", "{dir}/foo_test.go", "{dir}/bar_test.go"},
+			exitCode: 1,
+			stdout:   runDiff,
+		},
+		{
+			name:     "list only files with changed tests",
+			files:    map[string]string{"foo_test.go": runSrc, "bar_test.go": "package main\nimport \"testing\"\nfunc TestBar(t *testing.T) {\n   t.Skip()\n}\n"},
+			args:     []string{"-l", "{dir}"},
+			exitCode: 1,
+			stdout:   "{dir}/foo_test.go\n",
+		},
+		{
+			name:     "list nothing for unformatted skipped file",
+			files:    map[string]string{"bar_test.go": "package main\nimport \"testing\"\nfunc TestBar(t *testing.T) {\n   t.Skip()\n}\n"},
+			args:     []string{"-l", "-w", "{dir}/bar_test.go"},
+			expected: map[string]string{"bar_test.go": "package main\nimport \"testing\"\nfunc TestBar(t *testing.T) {\n   t.Skip()\n}\n"},
+		},
+		{
+			name:     "diff and write changed file",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-d", "-w", "{dir}/foo_test.go"},
+			exitCode: 1,
+			stdout:   runDiff,
+			expected: map[string]string{"foo_test.go": runSkippedSrc},
+		},
+		{
+			name:     "write into output directory",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-o", "{dir}/out", "{dir}/foo_test.go"},
+			expected: map[string]string{"out/{dir}/foo_test.go": runSkippedSrc},
+		},
+		{
+			name:     "unknown flag",
+			args:     []string{"-unknown", "{dir}"},
+			exitCode: 2,
+			stderr:   "flag provided but not defined: -unknown",
+		},
+		{
+			name:     "missing file",
+			args:     []string{"{dir}/missing_test.go"},
+			exitCode: 2,
+			stderr:   "missing_test.go",
+		},
+		{
+			name:     "continue after error",
+			files:    map[string]string{"foo_test.go": runSrc, "bar_test.go": "package main\n\nfunc TestBar(\n"},
+			args:     []string{"-w", "{dir}/bar_test.go", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "bar_test.go:",
+			expected: map[string]string{"foo_test.go": runSkippedSrc},
+		},
+		{
+			name:     "fail fast",
+			files:    map[string]string{"foo_test.go": runSrc, "bar_test.go": "package main\n\nfunc TestBar(\n"},
+			args:     []string{"-w", "-fail-fast", "{dir}/bar_test.go", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "bar_test.go:",
+		},
+		{
+			name:     "collect errors",
+			files:    map[string]string{"a_test.go": runSrc, "b_test.go": "package main\n\nfunc TestBar(\n", "c_test.go": "package main\n\nfunc TestBaz(\n"},
+			args:     []string{"-w", "-collect-errors", "{dir}"},
+			exitCode: 2,
+			stderr:   "c_test.go:",
+		},
+		{
+			name:     "fail fast and collect errors",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-fail-fast", "-collect-errors", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "-fail-fast and -collect-errors are mutually exclusive",
+		},
+		{
+			name:     "invalid source",
+			files:    map[string]string{"foo_test.go": "package main\n\nfunc TestFoo(\n"},
+			args:     []string{"-w", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "foo_test.go:",
+		},
+		{
+			name:     "conflicting output flags",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-w", "-o", "{dir}/out", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "-w and -o are mutually exclusive",
+		},
+		{
+			name: "skip directory to stdout in order of the paths",
+			files: map[string]string{
+				"c_test.go": strings.Replace(runSrc, "TestFoo", "TestC", 1),
+				"a_test.go": strings.Replace(runSrc, "TestFoo", "TestA", 1),
+				"b_test.go": strings.Replace(runSrc, "TestFoo", "TestB", 1),
+			},
+			args: []string{"{dir}"},
+			stdout: strings.Replace(runSkippedSrc, "TestFoo", "TestA", 1) +
+				strings.Replace(runSkippedSrc, "TestFoo", "TestB", 1) +
+				strings.Replace(runSkippedSrc, "TestFoo", "TestC", 1),
+		},
+		{
+			name:   "skip stdin without path",
+			stdin:  runSrc,
+			stdout: runSkippedSrc,
+		},
+		{
+			name:   "skip stdin for -",
+			files:  map[string]string{"foo_test.go": runSrc},
+			stdin:  runSrc,
+			args:   []string{"-", "{dir}/foo_test.go"},
+			stdout: runSkippedSrc + runSkippedSrc,
+		},
+		{
+			name:     "list stdin",
+			stdin:    runSrc,
+			args:     []string{"-l"},
+			exitCode: 1,
+			stdout:   "<standard input>\n",
+		},
+		{
+			name:   "list unchanged stdin",
+			stdin:  runSkippedSrc,
+			args:   []string{"-l", "-"},
+			stdout: "",
+		},
+		{
+			name:     "diff stdin",
+			stdin:    runSrc,
+			args:     []string{"-d"},
+			exitCode: 1,
+			stdout:   "--- <standard input>.orig\n+++ <standard input>\n@@ -2,6 +2,9 @@\n \n import \"testing\"\n \n+// gotestskipper:skip\n func TestFoo(t *testing.T) {\n+\tt.Skip()\n+\n \tt.Log(\"foo\")\n }\n",
+		},
+		{
+			name:     "write stdin",
+			stdin:    runSrc,
+			args:     []string{"-w"},
+			exitCode: 2,
+			stderr:   "cannot use -w with standard input",
+		},
+		{
+			name:     "newer-than stdin",
+			stdin:    runSrc,
+			args:     []string{"-newer-than", "7d", "-"},
+			exitCode: 2,
+			stderr:   "cannot use -newer-than or -from-go-list with standard input",
+		},
+		{
+			name:     "from-go-list stdin",
+			stdin:    runSrc,
+			args:     []string{"-from-go-list", "Foo"},
+			exitCode: 2,
+			stderr:   "cannot use -newer-than or -from-go-list with standard input",
+		},
+		{
+			name:     "cache stdin",
+			files:    map[string]string{"foo_test.go": runSrc},
+			stdin:    runSrc,
+			args:     []string{"-cache", "{dir}/.cache"},
+			exitCode: 2,
+			stderr:   "cannot use -cache or -from-coverage with standard input",
+		},
+		{
+			name:     "invalid stdin",
+			args:     []string{"-l"},
+			exitCode: 2,
+			stderr:   "<standard input>:",
+		},
+		{
+			name:     "skip parallel",
+			files:    map[string]string{"foo_test.go": strings.Replace(runSrc, "\tt.Log", "\tt.Parallel()\n\tt.Log", 1)},
+			args:     []string{"-w", "-skip-parallel", "{dir}/foo_test.go"},
+			expected: map[string]string{"foo_test.go": runSkippedSrc},
+		},
+		{
+			name:     "skip parallel with unskip",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-u", "-skip-parallel", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "-skip-parallel can not be combined with -u",
+		},
+		{
+			name:     "subtest with unskip",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-u", "-subtest", "^net", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "-subtest can not be combined with -u",
+		},
+		{
+			name:     "reason with unskip",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-u", "-reason", "flaky", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "-reason can not be combined with -u",
+		},
+		{
+			name:     "prefixes",
+			files:    map[string]string{"foo_test.go": strings.Replace(runSrc+"\nfunc IntegrationTestBar(t *testing.T) {\n\tt.Log(\"bar\")\n}\n", "TestFoo", "TesticularFoo", 1)},
+			args:     []string{"-w", "-prefix", "Test", "-prefix", "IntegrationTest", "{dir}/foo_test.go"},
+			expected: map[string]string{"foo_test.go": strings.Replace(runSrc+"\n// gotestskipper:skip\nfunc IntegrationTestBar(t *testing.T) {\n\tt.Skip()\n\n\tt.Log(\"bar\")\n}\n", "TestFoo", "TesticularFoo", 1)},
+		},
+		{
+			name:     "empty prefix",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-prefix", "", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "empty prefix",
+		},
+		{
+			name:  "no matching tests",
+			files: map[string]string{"foo_test.go": "package main\n\nfunc helper() {}\n"},
+			args:  []string{"-w", "{dir}/foo_test.go"},
+		},
+		{
+			name:  "clean file in list mode",
+			files: map[string]string{"foo_test.go": runSkippedSrc},
+			args:  []string{"-l", "{dir}/foo_test.go"},
+		},
+		{
+			name:     "changes in list mode",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-l", "{dir}/foo_test.go"},
+			exitCode: 1,
+			stdout:   "{dir}/foo_test.go\n",
+		},
+		{
+			name:     "parse error",
+			files:    map[string]string{"foo_test.go": "package\n"},
+			args:     []string{"-l", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "foo_test.go:",
+		},
+		{
+			name:   "help",
+			args:   []string{"-h"},
+			stderr: "The exit status is 0 on success",
+		},
+		{
+			name:     "unknown flag",
+			args:     []string{"-unknown"},
+			exitCode: 2,
+			stderr:   "flag provided but not defined: -unknown",
+		},
+		{
+			name:     "testmain",
+			files:    map[string]string{"main_test.go": "package main\n\nimport \"testing\"\n\nfunc TestMain(m *testing.M) {\n\tm.Run()\n}\n\nfunc TestFoo(t *testing.T) {\n\tt.Log(\"foo\")\n}\n"},
+			args:     []string{"-w", "-testmain", "-skip-if-env", "SKIP_PACKAGE", "{dir}/main_test.go"},
+			expected: map[string]string{"main_test.go": "package main\n\nimport (\n\t\"os\"\n\t\"testing\"\n)\n\nfunc TestMain(m *testing.M) {\n\tif os.Getenv(\"SKIP_PACKAGE\") != \"\" {\n\t\tos.Exit(0)\n\t}\n\n\tm.Run()\n}\n\nfunc TestFoo(t *testing.T) {\n\tt.Log(\"foo\")\n}\n"},
+		},
+		{
+			name:     "testmain without env var",
+			files:    map[string]string{"main_test.go": runSrc},
+			args:     []string{"-testmain", "{dir}/main_test.go"},
+			exitCode: 2,
+			stderr:   "-testmain requires -skip-if-env",
+		},
+		{
+			name:     "backup",
+			files:    map[string]string{"foo_test.go": runSrc, "bar_test.go": runSkippedSrc},
+			args:     []string{"-w", "-backup", "{dir}"},
+			expected: map[string]string{"foo_test.go": runSkippedSrc, "foo_test.go.orig": runSrc},
+		},
+		{
+			name:     "backup without write",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-backup", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "-backup requires -w",
+		},
+		{
+			name: "detected suite",
+			files: map[string]string{"foo_test.go": `package foo
+
+import "github.com/stretchr/testify/suite"
+
+type FooSuite struct {
+	suite.Suite
+}
+
+func (*FooSuite) TestFoo() {}
+`},
+			args: []string{"-w", "-suite", "auto", "{dir}"},
+			expected: map[string]string{"foo_test.go": `package foo
+
+import "github.com/stretchr/testify/suite"
+
+type FooSuite struct {
+	suite.Suite
+}
+
+// gotestskipper:skip
+func (s *FooSuite) TestFoo() { s.T().Skip() }
+`},
+		},
+		{
+			name:     "template",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-w", "-template", "{{.Param}}.SkipNow()", "{dir}"},
+			expected: map[string]string{"foo_test.go": strings.Replace(runSkippedSrc, "t.Skip()", "t.SkipNow()", 1)},
+		},
+		{
+			name:     "unskip template",
+			files:    map[string]string{"foo_test.go": strings.Replace(runSkippedSrc, "t.Skip()", "testutil.Skip(t)", 1)},
+			args:     []string{"-w", "-u", "-template", "testutil.Skip({{.Param}})", "{dir}"},
+			expected: map[string]string{"foo_test.go": runSrc},
+		},
+		{
+			name:     "invalid template",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-template", "{{.Param}}", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "skip template \"{{.Param}}\" does not render a call",
+		},
+		{
+			name:     "template with reason",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-template", "{{.Param}}.SkipNow()", "-reason", "flaky", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "-reason can not be combined with -template",
+		},
+		{
+			name:     "count",
+			files:    map[string]string{"foo/foo_test.go": runSrc, "foo/bar_test.go": runSkippedSrc, "bar/bar_test.go": runSrc},
+			args:     []string{"-w", "-count", "{dir}/..."},
+			stdout:   "{dir}/bar: 1 test\n{dir}/foo: 2 tests\ntotal: 3 tests in 3 files, 1 skipped\n",
+			expected: map[string]string{"foo/foo_test.go": runSrc, "foo/bar_test.go": runSkippedSrc, "bar/bar_test.go": runSrc},
+		},
+		{
+			name:   "count stdin",
+			stdin:  runSrc,
+			args:   []string{"-count"},
+			stdout: ".: 1 test\ntotal: 1 test in 1 files, 0 skipped\n",
+		},
+		{
+			name:     "all skips without unskip",
+			files:    map[string]string{"foo_test.go": runSrc},
+			args:     []string{"-all-skips", "{dir}/foo_test.go"},
+			exitCode: 2,
+			stderr:   "-all-skips requires -u",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "gotestskipper")
+			if err != nil {
+				panic(err)
+			}
+			defer os.RemoveAll(dir)
+			expand := strings.NewReplacer("{dir}", dir).Replace
+			for name, src := range test.files {
+				if err := os.MkdirAll(path.Dir(path.Join(dir, name)), 0755); err != nil {
+					panic(err)
+				}
+				if err := ioutil.WriteFile(path.Join(dir, name), []byte(src), 0644); err != nil {
+					panic(err)
+				}
+			}
+			var args []string
+			for _, arg := range test.args {
+				args = append(args, expand(arg))
+			}
+
+			var stdout, stderr bytes.Buffer
+			exitCode := runWithStdin(args, strings.NewReader(test.stdin), &stdout, &stderr, nil)
+
+			if exitCode != test.exitCode {
+				t.Fatalf("Expected exit code %d, got %d: %s\n", test.exitCode, exitCode, stderr.String())
+			}
+			if expand(test.stdout) != stdout.String() {
+				t.Fatalf("Expected stdout \n`%s`\n\n, got \n`%s`\n", expand(test.stdout), stdout.String())
+			}
+			if !strings.Contains(stderr.String(), expand(test.stderr)) {
+				t.Fatalf("Expected stderr to contain '%s', got '%s'\n", expand(test.stderr), stderr.String())
+			}
+			files := make(map[string]string)
+			for name, src := range test.files {
+				files[name] = src
+			}
+			for name, src := range test.expected {
+				files[name] = src
+			}
+			for name, src := range files {
+				content, err := ioutil.ReadFile(path.Join(dir, expand(name)))
+				if err != nil {
+					t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+				}
+				if string(content) != src {
+					t.Fatalf("Expected %s to be \n`%s`\n\n, got \n`%s`\n", name, src, content)
+				}
+			}
+		})
+	}
+}
+
+func TestRunCheckDupes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"foo/a_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {}\n\nfunc TestBar(t *testing.T) {}\n",
+		"foo/b_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {}\n",
+		"bar/a_test.go": "package bar\n\nimport \"testing\"\n\nfunc TestBar(t *testing.T) {}\n",
+	}
+	for name, src := range files {
+		if err := os.MkdirAll(path.Join(dir, path.Dir(name)), 0755); err != nil {
+			panic(err)
+		}
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(src), 0644); err != nil {
+			panic(err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	exitCode := Run([]string{"-check-dupes", path.Join(dir, "foo"), path.Join(dir, "bar")}, &stdout, &stderr)
+
+	if exitCode != 1 {
+		t.Fatalf("Expected exit code 1, got %d: %s\n", exitCode, stderr.String())
+	}
+	expected := strings.NewReplacer("{dir}", dir).Replace(
+		"{dir}/foo/a_test.go:7:1: TestBar: also declared in package bar at {dir}/bar/a_test.go:5:1\n" +
+			"{dir}/foo/b_test.go:5:1: TestFoo: also declared in the same package at {dir}/foo/a_test.go:5:1\n")
+	if stdout.String() != expected {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, stdout.String())
+	}
+	for name, src := range files {
+		content, _ := ioutil.ReadFile(path.Join(dir, name))
+		if string(content) != src {
+			t.Fatalf("Expected %s to be unchanged, got \n`%s`\n", name, content)
+		}
+	}
+
+	// Without duplicates the exit code is 0
+	stdout.Reset()
+	exitCode = Run([]string{"-check-dupes", path.Join(dir, "bar")}, &stdout, &stderr)
+
+	if exitCode != 0 || stdout.String() != "" {
+		t.Fatalf("Expected exit code 0 and no output, got %d: '%s'\n", exitCode, stdout.String())
+	}
+}
+
+func TestRunActionLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	filePath := path.Join(dir, "foo_test.go")
+	src := runSrc + "\nfunc TestBar(t *testing.T) {\n\tt.Log(\"bar\")\n}\n"
+	if err := ioutil.WriteFile(filePath, []byte(src), 0644); err != nil {
+		panic(err)
+	}
+	logPath := path.Join(dir, "actions.log")
+
+	for _, args := range [][]string{
+		{"-w", "-action-log", logPath, filePath},
+		{"-u", "-w", "-action-log", logPath, filePath},
+		// nothing is changed, nothing is logged
+		{"-u", "-w", "-action-log", logPath, filePath},
+		// nothing is written, nothing is logged
+		{"-l", "-action-log", logPath, filePath},
+	} {
+		var stdout, stderr bytes.Buffer
+		if exitCode := Run(args, &stdout, &stderr); exitCode == exitCodeError {
+			t.Fatalf("Expected no error for %v, got: %s\n", args, stderr.String())
+		}
+	}
+
+	content, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	expected := []string{
+		"skip " + filePath + ":TestFoo",
+		"skip " + filePath + ":TestBar",
+		"unskip " + filePath + ":TestFoo",
+		"unskip " + filePath + ":TestBar",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got \n`%s`\n", len(expected), content)
+	}
+	for i, line := range lines {
+		fields := strings.SplitN(line, " ", 2)
+		if _, err := time.Parse(time.RFC3339, fields[0]); err != nil || !strings.HasSuffix(fields[0], "Z") {
+			t.Fatalf("Expected line to start with a UTC timestamp, got '%s'\n", line)
+		}
+		if len(fields) != 2 || fields[1] != expected[i] {
+			t.Fatalf("Expected line to end with '%s', got '%s'\n", expected[i], line)
+		}
+	}
+}
+
+func TestRunPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	filePath := path.Join(dir, "foo_test.go")
+	src := "package main\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {\n\tt.Skip()\n\tt.Skip()\n\tt.Skip()\n\tt.Log(\"foo\")\n}\n"
+	if err := ioutil.WriteFile(filePath, []byte(src), 0644); err != nil {
+		panic(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	exitCode := Run([]string{"-prune", "-w", filePath}, &stdout, &stderr)
+
+	if exitCode != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s\n", exitCode, stderr.String())
+	}
+	expected := filePath + ":5:1: TestFoo: pruned duplicate skips\n"
+	if stderr.String() != expected {
+		t.Fatalf("Expected '%s', got '%s'\n", expected, stderr.String())
+	}
+	content, _ := ioutil.ReadFile(filePath)
+	if count := strings.Count(string(content), "t.Skip()"); count != 1 {
+		t.Fatalf("Expected a single skip, got \n`%s`\n", content)
+	}
+}
+
+func TestRunStdoutOnlySource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"bar_test.go", "foo_test.go"} {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(runSrc), 0644); err != nil {
+			panic(err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	exitCode := Run([]string{"-v", "-summary", "-log", "text", "-log-level", "debug", dir}, &stdout, &stderr)
+
+	if exitCode != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s\n", exitCode, stderr.String())
+	}
+	// diagnostics go to stderr only, stdout holds nothing but the sources
+	if expected := runSkippedSrc + runSkippedSrc; stdout.String() != expected {
+		t.Fatalf("Expected stdout \n`%s`\n\n, got \n`%s`\n", expected, stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "skipped 2 tests in 2 files") || !strings.Contains(stderr.String(), "processed path") {
+		t.Fatalf("Expected diagnostics on stderr, got \n`%s`\n", stderr.String())
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/mitch000001/go-tools/testskipper"
+)
+
+// stdinName is the file name used for the source read from stdin
+const stdinName = "<standard input>"
+
+// processStdin applies visitAction to the source read from stdin and writes
+// the result to stdout, or lists or diffs it with -l and -d
+func (c *command) processStdin(visitAction testskipper.FuncVisitAction) {
+	switch {
+	case c.write:
+		c.report(fmt.Errorf("cannot use -w with standard input"))
+		return
+	case c.outputDir != "":
+		c.report(fmt.Errorf("cannot use -o with standard input"))
+		return
+	case c.blame != nil || c.fromGoList != "":
+		c.report(fmt.Errorf("cannot use -newer-than or -from-go-list with standard input"))
+		return
+	case c.cache != nil || c.coverage != nil:
+		c.report(fmt.Errorf("cannot use -cache or -from-coverage with standard input"))
+		return
+	}
+	src, err := ioutil.ReadAll(c.stdin)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	visitor := c.newVisitor(visitAction)
+	report := &testskipper.Report{}
+	visitor.SetReport(report)
+	var buffer bytes.Buffer
+	if err := testskipper.WalkReader(bytes.NewReader(src), stdinName, &buffer, visitor); err != nil {
+		c.fail(err)
+		return
+	}
+	if c.count {
+		c.counts = c.counts.Add(report.Count())
+		return
+	}
+	c.delta = c.delta.Add(report.Delta())
+	c.imports = c.imports.Add(report.ImportDelta())
+	if c.verbose {
+		c.reportCounts(report)
+	}
+	switch c.format {
+	case formatGitHub:
+		c.writeGitHubAnnotations(report)
+	case formatJSON:
+		c.collectJSON(testskipper.PathWriter{stdinName: &buffer}, report)
+	}
+	if c.list || c.diff {
+		if bytes.Equal(src, buffer.Bytes()) {
+			return
+		}
+		c.changesFound()
+		if c.list {
+			c.listPath(stdinName)
+		}
+		if c.diff {
+			if _, err := unifiedDiff(c.stdout, stdinName, src, buffer.Bytes(), c.diffContext); err != nil {
+				c.report(err)
+			}
+		}
+		return
+	}
+	if c.format == "" {
+		if _, err := c.stdout.Write(buffer.Bytes()); err != nil {
+			c.report(err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/mitch000001/go-tools/testskipper"
+)
+
+// runTar processes the tar archive given by -tar and writes the resulting
+// archive to the file given by -o or to stdout
+func (c *command) runTar(visitAction testskipper.FuncVisitAction) error {
+	switch {
+	case c.write:
+		return fmt.Errorf("-tar and -w are mutually exclusive")
+	case c.list || c.diff:
+		return fmt.Errorf("-tar can not be combined with -l or -d")
+	case c.blame != nil || c.fromGoList != "":
+		return fmt.Errorf("-tar can not be combined with -newer-than or -from-go-list")
+	}
+	in, err := os.Open(c.tarFile)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out := c.stdout
+	if c.outputDir != "" {
+		if _, err := os.Stat(c.outputDir); err == nil && !c.force {
+			return fmt.Errorf("%s: file exists, use -force to overwrite", c.outputDir)
+		}
+		file, err := os.Create(c.outputDir)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	processor := testskipper.NewProcessor(visitAction, func(visitor testskipper.TestFuncVisitor) {
+		c.configureVisitor(visitor)
+		if c.coverage != nil {
+			visitor.AddPathFilter(c.coverage.ZeroCovered)
+		}
+	})
+	return c.processTar(tar.NewReader(in), tar.NewWriter(out), processor)
+}
+
+// processTar copies all entries of in to out, passing the content of
+// regular *_test.go files through processor. Entries which fail to process
+// are reported and copied verbatim, like the entries rejected by the path
+// filters.
+func (c *command) processTar(in *tar.Reader, out *tar.Writer, processor *testskipper.Processor) error {
+	for {
+		header, err := in.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		content, err := ioutil.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		if c.buildContext != nil {
+			// the entries are not on disk, their build constraints are
+			// read from their content
+			buildContext := *c.buildContext
+			buildContext.OpenFile = func(string) (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewReader(content)), nil
+			}
+			c.buildContext = &buildContext
+		}
+		if header.Typeflag == tar.TypeReg && strings.HasSuffix(header.Name, "_test.go") {
+			result, err := processor.ProcessFile(header.Name, content)
+			if err != nil {
+				c.report(err)
+			} else {
+				content = result.Files[header.Name]
+				header.Size = int64(len(content))
+				c.delta = c.delta.Add(result.Report.Delta())
+				c.imports = c.imports.Add(result.Report.ImportDelta())
+			}
+		}
+		if err := out.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := out.Write(content); err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
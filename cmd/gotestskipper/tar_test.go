@@ -0,0 +1,230 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunTar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testSrc := "package foo\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {\n\tt.Log(\"foo\")\n}\n"
+	entries := []struct {
+		name    string
+		content string
+	}{
+		{"foo/foo.go", "package foo\n\nfunc TestHelper() {}\n"},
+		{"foo/foo_test.go", testSrc},
+		{"README", "not go\n"},
+	}
+	var archive bytes.Buffer
+	writer := tar.NewWriter(&archive)
+	for _, entry := range entries {
+		err := writer.WriteHeader(&tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.content)), Typeflag: tar.TypeReg})
+		if err != nil {
+			panic(err)
+		}
+		if _, err := writer.Write([]byte(entry.content)); err != nil {
+			panic(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		panic(err)
+	}
+	inPath := filepath.Join(dir, "in.tar")
+	if err := ioutil.WriteFile(inPath, archive.Bytes(), 0644); err != nil {
+		panic(err)
+	}
+	outPath := filepath.Join(dir, "out.tar")
+
+	var stdout, stderr bytes.Buffer
+	exitCode := Run([]string{"-tar", inPath, "-o", outPath}, &stdout, &stderr)
+
+	if exitCode != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s\n", exitCode, stderr.String())
+	}
+	out, err := os.Open(outPath)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	defer out.Close()
+	expected := map[string]string{
+		"foo/foo.go":      entries[0].content,
+		"foo/foo_test.go": "package foo\n\nimport \"testing\"\n\n// gotestskipper:skip\nfunc TestFoo(t *testing.T) {\n\tt.Skip()\n\n\tt.Log(\"foo\")\n}\n",
+		"README":          entries[2].content,
+	}
+	reader := tar.NewReader(out)
+	var names []string
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		content, _ := ioutil.ReadAll(reader)
+		if string(content) != expected[header.Name] {
+			t.Fatalf("Expected %s to equal \n`%s`\n\n, got \n`%s`\n", header.Name, expected[header.Name], content)
+		}
+		names = append(names, header.Name)
+	}
+	if len(names) != len(entries) {
+		t.Fatalf("Expected %d entries, got %v\n", len(entries), names)
+	}
+
+	// Existing output files are not overwritten
+	exitCode = Run([]string{"-tar", inPath, "-o", outPath}, &stdout, &stderr)
+
+	if exitCode != 2 {
+		t.Fatalf("Expected exit code 2 for an existing output file, got %d\n", exitCode)
+	}
+
+	// Unskipping the output to stdout removes the skip again
+	stdout.Reset()
+	exitCode = Run([]string{"-tar", outPath, "-u"}, &stdout, &stderr)
+
+	if exitCode != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s\n", exitCode, stderr.String())
+	}
+	reader = tar.NewReader(&stdout)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		content, _ := ioutil.ReadAll(reader)
+		if header.Name == "foo/foo_test.go" && strings.Contains(string(content), "t.Skip()") {
+			t.Fatalf("Expected %s to be unskipped, got \n`%s`\n", header.Name, content)
+		}
+	}
+}
+
+func TestRunTarExclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testSrc := "package foo\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {\n\tt.Log(\"foo\")\n}\n"
+	inPath := filepath.Join(dir, "in.tar")
+	writeTar(inPath, [][2]string{{"foo/foo_test.go", testSrc}, {"foo/gen_test.go", "not go\n"}})
+
+	var stdout, stderr bytes.Buffer
+	exitCode := Run([]string{"-tar", inPath, "-exclude", "gen_test.go"}, &stdout, &stderr)
+
+	if exitCode != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s\n", exitCode, stderr.String())
+	}
+	entries := readTar(t, &stdout)
+	if content := entries["foo/gen_test.go"]; content != "not go\n" {
+		t.Fatalf("Expected the excluded entry to be copied unchanged, got \n`%s`\n", content)
+	}
+	if content := entries["foo/foo_test.go"]; !strings.Contains(content, "t.Skip()") {
+		t.Fatalf("Expected foo/foo_test.go to be skipped, got \n`%s`\n", content)
+	}
+}
+
+func TestRunTarBuildTagsAndCoverage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testSrc := "package testdata\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {\n\tt.Log(\"foo\")\n}\n"
+	tests := []struct {
+		name    string
+		args    []string
+		entries [][2]string
+		skipped map[string]bool
+	}{
+		{
+			name:    "build tags",
+			args:    []string{"-build-tags", "integration"},
+			entries: [][2]string{{"foo_test.go", testSrc}, {"bar_test.go", "//go:build integration\n\n" + testSrc}, {"baz_test.go", "//go:build ignore\n\n" + testSrc}},
+			skipped: map[string]bool{"foo_test.go": true, "bar_test.go": true},
+		},
+		{
+			name:    "from coverage",
+			args:    []string{"-from-coverage", "testdata/cover.out"},
+			entries: [][2]string{{"testdata/uncovered_test.go", testSrc}, {"testdata/covered_test.go", testSrc}},
+			skipped: map[string]bool{"testdata/uncovered_test.go": true},
+		},
+	}
+
+	for _, test := range tests {
+		inPath := filepath.Join(dir, "in.tar")
+		writeTar(inPath, test.entries)
+
+		var stdout, stderr bytes.Buffer
+		exitCode := Run(append([]string{"-tar", inPath}, test.args...), &stdout, &stderr)
+
+		if exitCode != 0 {
+			t.Fatalf("Expected %s to exit with code 0, got %d: %s\n", test.name, exitCode, stderr.String())
+		}
+		entries := readTar(t, &stdout)
+		for _, entry := range test.entries {
+			if skipped := strings.Contains(entries[entry[0]], "t.Skip()"); skipped != test.skipped[entry[0]] {
+				t.Fatalf("Expected %s to skip %s %t, got \n`%s`\n", test.name, entry[0], test.skipped[entry[0]], entries[entry[0]])
+			}
+			if !test.skipped[entry[0]] && entries[entry[0]] != entry[1] {
+				t.Fatalf("Expected %s to copy %s unchanged, got \n`%s`\n", test.name, entry[0], entries[entry[0]])
+			}
+		}
+	}
+}
+
+// writeTar writes a tar archive with the given name and content pairs to
+// path
+func writeTar(path string, entries [][2]string) {
+	var archive bytes.Buffer
+	writer := tar.NewWriter(&archive)
+	for _, entry := range entries {
+		err := writer.WriteHeader(&tar.Header{Name: entry[0], Mode: 0644, Size: int64(len(entry[1])), Typeflag: tar.TypeReg})
+		if err != nil {
+			panic(err)
+		}
+		if _, err := writer.Write([]byte(entry[1])); err != nil {
+			panic(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(path, archive.Bytes(), 0644); err != nil {
+		panic(err)
+	}
+}
+
+// readTar returns the content of the entries of the tar archive in r by
+// their names
+func readTar(t *testing.T, r io.Reader) map[string]string {
+	entries := make(map[string]string)
+	reader := tar.NewReader(r)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		content, _ := ioutil.ReadAll(reader)
+		entries[header.Name] = string(content)
+	}
+}
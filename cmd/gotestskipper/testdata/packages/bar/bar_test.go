@@ -0,0 +1,5 @@
+package bar
+
+import "testing"
+
+func TestBar(t *testing.T) {}
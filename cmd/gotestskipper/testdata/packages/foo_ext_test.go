@@ -0,0 +1,5 @@
+package foo_test
+
+import "testing"
+
+func TestFooExternal(t *testing.T) {}
@@ -0,0 +1,5 @@
+package foo
+
+import "testing"
+
+func TestFoo(t *testing.T) {}
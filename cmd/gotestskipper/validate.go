@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mitch000001/go-tools/testskipper"
+)
+
+// validate implements the validate subcommand, which reports test
+// functions whose marker comment and skip statement do not match
+func (c *command) validate(args []string) int {
+	flags := flag.NewFlagSet("gotestskipper validate", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	includeVendor := flags.Bool("include-vendor", false, "also search vendor directories when searching recursively")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: gotestskipper validate [flags] [path ...]\n")
+		fmt.Fprintf(c.stderr, "\nA path ending in /... is searched recursively.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitCodeClean
+		}
+		return exitCodeError
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitCodeError
+	}
+	for _, arg := range flags.Args() {
+		paths, err := testFiles(arg, *includeVendor)
+		if err != nil {
+			c.report(err)
+			continue
+		}
+		for _, path := range paths {
+			fileSet := token.NewFileSet()
+			file, err := parser.ParseFile(fileSet, path, nil, parser.ParseComments)
+			if err != nil {
+				c.report(err)
+				continue
+			}
+			for _, inconsistency := range testskipper.Validate(fileSet, file) {
+				fmt.Fprintln(c.stdout, inconsistency)
+				c.changesFound()
+			}
+		}
+	}
+	return c.exitCode
+}
+
+// testFiles returns the test files at arg, which is either a file, a
+// directory or a directory followed by /... to include all subdirectories.
+// Vendor directories are only included if includeVendor is true. The paths
+// are sorted.
+func testFiles(arg string, includeVendor bool) ([]string, error) {
+	root, recursive := strings.TrimSuffix(arg, "/..."), strings.HasSuffix(arg, "/...")
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{root}, nil
+	}
+	var paths []string
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path == root {
+				return nil
+			}
+			if !recursive || ignoredDir(entry.Name(), includeVendor) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(path, "_test.go") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	sort.Strings(paths)
+	return paths, err
+}
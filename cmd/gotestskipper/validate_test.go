@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestRunValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotestskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"foo_test.go": `package foo
+
+import "testing"
+
+// gotestskipper:skip
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+`,
+		"sub/bar_test.go": `package bar
+
+import "testing"
+
+func TestBar(t *testing.T) {
+	t.Skip()
+}
+`,
+		"sub/baz_test.go": `package bar
+
+import "testing"
+
+// gotestskipper:skip
+func TestBaz(t *testing.T) {
+	t.Skip()
+}
+`,
+		"vendor/example.com/quux/quux_test.go": `package quux
+
+import "testing"
+
+func TestQuux(t *testing.T) {
+	t.Skip()
+}
+`,
+		"testdata/qux_test.go": `package qux
+
+import "testing"
+
+func TestQux(t *testing.T) {
+	t.Skip()
+}
+`,
+	}
+	for name, src := range files {
+		filePath := path.Join(dir, name)
+		if err := os.MkdirAll(path.Dir(filePath), 0777); err != nil {
+			panic(err)
+		}
+		if err := ioutil.WriteFile(filePath, []byte(src), 0644); err != nil {
+			panic(err)
+		}
+	}
+
+	tests := []struct {
+		args     []string
+		exitCode int
+		output   string
+	}{
+		{[]string{"validate", dir}, 1, path.Join(dir, "foo_test.go") + ":6:1: TestFoo: marker without skip\n"},
+		{[]string{"validate", dir + "/..."}, 1, path.Join(dir, "foo_test.go") + ":6:1: TestFoo: marker without skip\n" +
+			path.Join(dir, "sub/bar_test.go") + ":5:1: TestBar: skip without marker\n"},
+		{[]string{"validate", "-include-vendor", dir + "/..."}, 1, path.Join(dir, "foo_test.go") + ":6:1: TestFoo: marker without skip\n" +
+			path.Join(dir, "sub/bar_test.go") + ":5:1: TestBar: skip without marker\n" +
+			path.Join(dir, "vendor/example.com/quux/quux_test.go") + ":5:1: TestQuux: skip without marker\n"},
+		{[]string{"validate", path.Join(dir, "vendor/example.com/quux")}, 1, path.Join(dir, "vendor/example.com/quux/quux_test.go") + ":5:1: TestQuux: skip without marker\n"},
+		{[]string{"validate", path.Join(dir, "sub/baz_test.go")}, 0, ""},
+		{[]string{"validate", path.Join(dir, "missing")}, 2, ""},
+		{[]string{"validate"}, 2, ""},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		exitCode := Run(test.args, &stdout, &stderr)
+
+		if exitCode != test.exitCode {
+			t.Fatalf("Expected exit code %d for %v, got %d\n", test.exitCode, test.args, exitCode)
+		}
+		if stdout.String() != test.output {
+			t.Fatalf("Expected output for %v\n`%s`\n\n, got \n`%s`\n", test.args, test.output, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mitch000001/go-tools/testskipper"
+)
+
+// reportCounts prints the number of tests changed by the action per file
+// of report with -v and adds them to the totals printed by finish
+func (c *command) reportCounts(report *testskipper.Report) {
+	counts := make(map[string]int)
+	for _, funcReport := range report.Changed() {
+		counts[funcReport.Position.Filename]++
+	}
+	files := make([]string, 0, len(counts))
+	for file := range counts {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		fmt.Fprintf(c.stderr, "gotestskipper: %s: %s %s\n", file, actionParticiples[c.action], countTests(counts[file]))
+		c.changedTests += counts[file]
+	}
+	c.changedFiles += len(files)
+}
+
+// reportTotals prints the total number of tests changed by the action
+func (c *command) reportTotals() {
+	fmt.Fprintf(c.stderr, "gotestskipper: %s %s in %d files\n", actionParticiples[c.action], countTests(c.changedTests), c.changedFiles)
+}
+
+// countTests returns n followed by test or tests
+func countTests(n int) string {
+	if n == 1 {
+		return "1 test"
+	}
+	return fmt.Sprintf("%d tests", n)
+}
@@ -0,0 +1,573 @@
+package testskipper
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"math"
+	"strconv"
+)
+
+// DefaultParamName is the name ApplySkip gives an unnamed testing parameter
+const DefaultParamName = "t"
+
+// detachedPos is a position outside of any file. The printer takes a
+// statement at this position to be far above the following statement and
+// separates them by a blank line, while comments following the statement
+// are still printed below it.
+const detachedPos = token.Pos(math.MaxInt32)
+
+// SkipOptions configures the statement inserted by ApplySkip
+type SkipOptions struct {
+	// Reason is passed to the Skip call if not empty
+	Reason string
+	// ShortMode guards the Skip call with testing.Short()
+	ShortMode bool
+	// EnvVar guards the Skip call with os.Getenv(EnvVar) != "" instead, so
+	// that the test is only skipped if the environment variable is set. A
+	// TestFuncVisitor adds the import of os to the file if missing.
+	EnvVar string
+	// AfterCleanup places the statement after any leading t.Cleanup calls,
+	// so that the cleanups are still registered
+	AfterCleanup bool
+	// AfterSeeds places the statement of a fuzz target after any leading
+	// f.Add calls adding to the seed corpus
+	AfterSeeds bool
+	// ParamName is the name given to an unnamed or blank testing parameter.
+	// If empty, it is b for a *testing.B, f for a *testing.F and
+	// DefaultParamName otherwise.
+	ParamName string
+	// Tight omits the blank line between the statement and the following
+	// statement
+	Tight bool
+	// RemoveParallel also removes the t.Parallel() statements of the
+	// function body. This is one-directional, ApplyUnskip does not restore
+	// them, as their place is lost.
+	RemoveParallel bool
+	// Template, if not nil, renders the skip call inserted instead of
+	// t.Skip(), Reason is ignored then
+	Template *SkipTemplate
+}
+
+// ApplySkip inserts a
+//
+//	t.Skip()
+//
+// statement as the first statement of decl, or with opts.AfterCleanup and
+// opts.AfterSeeds after any leading t.Cleanup and f.Add calls. The name of
+// the testing parameter and the qualifier of the testing package are taken
+// from the first parameter of decl, so that aliased imports are respected.
+// An unnamed or blank testing parameter is named opts.ParamName first.
+// With opts.RemoveParallel the t.Parallel() statements of the body are
+// removed. If the statement at that place already is a skip statement, decl
+// is otherwise left unchanged. Otherwise the SkipMarker line comment is
+// added to the doc comment of decl.
+//
+// fileSet is only used to add position information to errors and may be
+// nil.
+func ApplySkip(fileSet *token.FileSet, decl *ast.FuncDecl, opts SkipOptions) error {
+	nameTestingParam(decl, opts.ParamName)
+	target, err := testingTargetOf(fileSet, decl)
+	if err != nil {
+		return err
+	}
+	if opts.RemoveParallel {
+		removeMethodCallStmts(decl, target, "Parallel")
+	}
+	skipped := map[string]bool{"Cleanup": opts.AfterCleanup, "Add": opts.AfterSeeds}
+	index := 0
+	for index < len(decl.Body.List) && isMethodCallStmt(decl.Body.List[index], target, skipped) {
+		index++
+	}
+	// skipping is idempotent, a function which is already skipped is left
+	// unchanged
+	if index < len(decl.Body.List) {
+		if stmt := decl.Body.List[index]; isSkipCallStmt(stmt, target) || opts.Template.matches(stmt, target) || isShortModeGuard(stmt, target, nil) || isEnvGuard(stmt, target, nil, "") {
+			return nil
+		}
+	}
+	// anchor the new statement at the opening brace or the end of the
+	// preceding statement, so that comments following it stay below it. If
+	// another statement follows, it is detached instead to be separated by
+	// a blank line. A TestFuncVisitor moves it below a trailing comment of
+	// the preceding statement, see placeInsertedStmts.
+	pos := decl.Body.Lbrace
+	switch {
+	case !opts.Tight && index < len(decl.Body.List):
+		pos = detachedPos
+	case index > 0:
+		pos = decl.Body.List[index-1].End()
+	}
+	skipExpr := skipTestExpr(target, opts.Reason, pos)
+	if opts.Template != nil {
+		if skipExpr, err = opts.Template.expr(target, pos); err != nil {
+			return err
+		}
+	}
+	var stmt ast.Stmt = &ast.ExprStmt{X: skipExpr}
+	switch {
+	case opts.EnvVar != "":
+		stmt = &ast.IfStmt{
+			If:   pos,
+			Cond: envSetExpr(opts.EnvVar, pos),
+			Body: &ast.BlockStmt{Lbrace: pos, List: []ast.Stmt{stmt}, Rbrace: pos},
+		}
+	case opts.ShortMode:
+		var shortFunc ast.Expr = &ast.Ident{NamePos: pos, Name: "Short"}
+		if qualifier := target.qualifier; qualifier != "" {
+			shortFunc = &ast.SelectorExpr{X: &ast.Ident{NamePos: pos, Name: qualifier}, Sel: &ast.Ident{NamePos: pos, Name: "Short"}}
+		}
+		stmt = &ast.IfStmt{
+			If:   pos,
+			Cond: &ast.CallExpr{Fun: shortFunc, Lparen: pos, Rparen: pos},
+			Body: &ast.BlockStmt{Lbrace: pos, List: []ast.Stmt{stmt}, Rbrace: pos},
+		}
+	}
+	insertStmt(decl, index, stmt)
+	addSkipMarker(decl)
+	return nil
+}
+
+// UnskipOptions configures the statement removed by ApplyUnskipWithOptions
+type UnskipOptions struct {
+	// Calls are skip helpers whose calls are removed like skip statements
+	Calls []SkipCall
+	// Nested also removes skip statements nested in the blocks of if, for,
+	// switch and select statements. Function literals are never entered.
+	Nested bool
+	// Template is a skip template whose calls are removed like skip
+	// statements, if not nil
+	Template *SkipTemplate
+}
+
+// ApplyUnskip removes the first
+//
+//	t.Skip(...)
+//	t.Skipf(...)
+//	t.SkipNow()
+//
+// statement from the statement list of the function body of decl, wherever
+// it is in the list. A call of any of the given skip helpers is removed as
+// well. Skips nested in other statements are left untouched, see
+// ApplyUnskipWithOptions. The SkipMarker of decl is removed if no leading
+// skip statement is left.
+//
+// fileSet is only used to add position information to errors and may be
+// nil.
+func ApplyUnskip(fileSet *token.FileSet, decl *ast.FuncDecl, calls ...SkipCall) error {
+	return ApplyUnskipWithOptions(fileSet, decl, UnskipOptions{Calls: calls})
+}
+
+// ApplyUnskipWithOptions removes the first skip statement from decl like
+// ApplyUnskip, searching the nested blocks as well with opts.Nested. The
+// statements are searched in source order.
+func ApplyUnskipWithOptions(fileSet *token.FileSet, decl *ast.FuncDecl, opts UnskipOptions) error {
+	target, err := testingTargetOf(fileSet, decl)
+	if err != nil {
+		return err
+	}
+	isSkip := func(stmt ast.Stmt) bool {
+		return isSkipStmt(stmt, target, opts.Calls) || opts.Template.matches(stmt, target)
+	}
+	if removeFirstStmt(&decl.Body.List, isSkip, opts.Nested) {
+		unmarkUnskipped(decl, target, isSkip)
+	}
+	return nil
+}
+
+// removeFirstStmt removes the first statement of list matched by match and
+// reports whether one was removed. With nested, the statement lists of
+// nested blocks are searched as well.
+func removeFirstStmt(list *[]ast.Stmt, match func(ast.Stmt) bool, nested bool) bool {
+	for i, stmt := range *list {
+		if match(stmt) {
+			*list = append((*list)[:i], (*list)[i+1:]...)
+			return true
+		}
+		if !nested {
+			continue
+		}
+		for _, block := range nestedStmtLists(stmt) {
+			if removeFirstStmt(block, match, nested) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nestedStmtLists returns the statement lists directly nested in stmt
+func nestedStmtLists(stmt ast.Stmt) []*[]ast.Stmt {
+	switch stmt := stmt.(type) {
+	case *ast.BlockStmt:
+		return []*[]ast.Stmt{&stmt.List}
+	case *ast.LabeledStmt:
+		return nestedStmtLists(stmt.Stmt)
+	case *ast.IfStmt:
+		lists := []*[]ast.Stmt{&stmt.Body.List}
+		if stmt.Else != nil {
+			lists = append(lists, nestedStmtLists(stmt.Else)...)
+		}
+		return lists
+	case *ast.ForStmt:
+		return []*[]ast.Stmt{&stmt.Body.List}
+	case *ast.RangeStmt:
+		return []*[]ast.Stmt{&stmt.Body.List}
+	case *ast.SwitchStmt:
+		return clauseStmtLists(stmt.Body)
+	case *ast.TypeSwitchStmt:
+		return clauseStmtLists(stmt.Body)
+	case *ast.SelectStmt:
+		return clauseStmtLists(stmt.Body)
+	}
+	return nil
+}
+
+// clauseStmtLists returns the statement lists of the case and comm clauses
+// of body
+func clauseStmtLists(body *ast.BlockStmt) []*[]ast.Stmt {
+	var lists []*[]ast.Stmt
+	for _, clause := range body.List {
+		switch clause := clause.(type) {
+		case *ast.CaseClause:
+			lists = append(lists, &clause.Body)
+		case *ast.CommClause:
+			lists = append(lists, &clause.Body)
+		}
+	}
+	return lists
+}
+
+// ApplyUnskipAll removes all leading skip statements from decl, regardless
+// of how they were added. These are calls of
+//
+//	t.Skip(...)
+//	t.Skipf(...)
+//	t.SkipNow()
+//
+// and of any of the given skip helpers, as well as such calls guarded by
+//
+//	if testing.Short() {
+//		t.Skip()
+//	}
+//
+// or by a check of an environment variable, see ApplyUnskipEnvGuard. The
+// SkipMarker of decl is removed if no leading skip statement is left.
+//
+// fileSet is only used to add position information to errors and may be
+// nil.
+func ApplyUnskipAll(fileSet *token.FileSet, decl *ast.FuncDecl, calls ...SkipCall) error {
+	target, err := testingTargetOf(fileSet, decl)
+	if err != nil {
+		return err
+	}
+	isSkip := func(stmt ast.Stmt) bool {
+		return isSkipStmt(stmt, target, calls) || isShortModeGuard(stmt, target, calls) || isEnvGuard(stmt, target, calls, "")
+	}
+	for len(decl.Body.List) > 0 && isSkip(decl.Body.List[0]) {
+		decl.Body.List = decl.Body.List[1:]
+	}
+	unmarkUnskipped(decl, target, isSkip)
+	return nil
+}
+
+// ApplyUnskipEnvGuard removes the first
+//
+//	if os.Getenv("envVar") != "" {
+//		t.Skip()
+//	}
+//
+// statement, as inserted by ApplySkip with SkipOptions.EnvVar, from the
+// statement list of the function body of decl. Other skip statements are
+// left untouched and keep the SkipMarker of decl.
+//
+// fileSet is only used to add position information to errors and may be
+// nil.
+func ApplyUnskipEnvGuard(fileSet *token.FileSet, decl *ast.FuncDecl, envVar string) error {
+	target, err := testingTargetOf(fileSet, decl)
+	if err != nil {
+		return err
+	}
+	isGuard := func(stmt ast.Stmt) bool {
+		return isEnvGuard(stmt, target, nil, envVar)
+	}
+	if removeFirstStmt(&decl.Body.List, isGuard, false) {
+		unmarkUnskipped(decl, target, isGuard)
+	}
+	return nil
+}
+
+// envSetExpr builds the expression
+//
+//	os.Getenv("envVar") != ""
+//
+// with all positions set to pos
+func envSetExpr(envVar string, pos token.Pos) ast.Expr {
+	return &ast.BinaryExpr{
+		X: &ast.CallExpr{
+			Fun:    &ast.SelectorExpr{X: &ast.Ident{NamePos: pos, Name: "os"}, Sel: &ast.Ident{NamePos: pos, Name: "Getenv"}},
+			Lparen: pos,
+			Args:   []ast.Expr{&ast.BasicLit{ValuePos: pos, Kind: token.STRING, Value: strconv.Quote(envVar)}},
+			Rparen: pos,
+		},
+		OpPos: pos,
+		Op:    token.NEQ,
+		Y:     &ast.BasicLit{ValuePos: pos, Kind: token.STRING, Value: `""`},
+	}
+}
+
+// isEnvGuard reports whether stmt is an if statement without else branch
+// checking os.Getenv("envVar") != "", or any environment variable if envVar
+// is empty, whose body only consists of skip calls
+func isEnvGuard(stmt ast.Stmt, target testingTarget, calls []SkipCall, envVar string) bool {
+	ifStmt, ok := stmt.(*ast.IfStmt)
+	if !ok || ifStmt.Init != nil || ifStmt.Else != nil || len(ifStmt.Body.List) == 0 || !isEnvSetExpr(ifStmt.Cond, envVar) {
+		return false
+	}
+	for _, stmt := range ifStmt.Body.List {
+		if !isSkipStmt(stmt, target, calls) {
+			return false
+		}
+	}
+	return true
+}
+
+// isEnvSetExpr reports whether expr is the expression built by envSetExpr
+// for envVar, or for any environment variable if envVar is empty
+func isEnvSetExpr(expr ast.Expr, envVar string) bool {
+	cond, ok := expr.(*ast.BinaryExpr)
+	if !ok || cond.Op != token.NEQ {
+		return false
+	}
+	if empty, ok := cond.Y.(*ast.BasicLit); !ok || empty.Kind != token.STRING || (empty.Value != `""` && empty.Value != "``") {
+		return false
+	}
+	call, ok := cond.X.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return false
+	}
+	fun, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || fun.Sel.Name != "Getenv" {
+		return false
+	}
+	if pkg, ok := fun.X.(*ast.Ident); !ok || pkg.Name != "os" {
+		return false
+	}
+	name, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || name.Kind != token.STRING {
+		return false
+	}
+	value, err := strconv.Unquote(name.Value)
+	return err == nil && (envVar == "" || value == envVar)
+}
+
+var skipMethods = map[string]bool{"Skip": true, "Skipf": true, "SkipNow": true}
+
+// isSkipCallStmt reports whether stmt is a call of any of the skip methods
+// on target, with any arguments
+func isSkipCallStmt(stmt ast.Stmt, target testingTarget) bool {
+	return isMethodCallStmt(stmt, target, skipMethods)
+}
+
+// isShortModeGuard reports whether stmt is an if statement without else
+// branch checking testing.Short() whose body only consists of skip calls
+func isShortModeGuard(stmt ast.Stmt, target testingTarget, calls []SkipCall) bool {
+	ifStmt, ok := stmt.(*ast.IfStmt)
+	if !ok || ifStmt.Init != nil || ifStmt.Else != nil || len(ifStmt.Body.List) == 0 {
+		return false
+	}
+	call, ok := ifStmt.Cond.(*ast.CallExpr)
+	if !ok || len(call.Args) != 0 {
+		return false
+	}
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		if fun.Name != "Short" {
+			return false
+		}
+	case *ast.SelectorExpr:
+		if fun.Sel.Name != "Short" {
+			return false
+		}
+	default:
+		return false
+	}
+	for _, stmt := range ifStmt.Body.List {
+		if !isSkipStmt(stmt, target, calls) {
+			return false
+		}
+	}
+	return true
+}
+
+// testingTargetOf returns the testing target of decl. For test functions
+// this is the first parameter, which is expected to be the named testing
+// parameter. For suite methods without parameters it is the named receiver.
+func testingTargetOf(fileSet *token.FileSet, decl *ast.FuncDecl) (testingTarget, error) {
+	if decl.Body == nil {
+		return testingTarget{}, funcError(fileSet, decl, "has no body")
+	}
+	params := decl.Type.Params.List
+	if decl.Recv != nil && len(params) == 0 {
+		receivers := decl.Recv.List
+		if len(receivers) == 0 || len(receivers[0].Names) == 0 || receivers[0].Names[0].Name == "_" {
+			return testingTarget{}, funcError(fileSet, decl, "has no named receiver")
+		}
+		return testingTarget{name: receivers[0].Names[0].Name, suite: true, qualifier: defaultTestImport}, nil
+	}
+	if len(params) == 0 || len(params[0].Names) == 0 {
+		return testingTarget{}, funcError(fileSet, decl, "has no named testing parameter")
+	}
+	return testingTarget{name: params[0].Names[0].Name, qualifier: testingQualifier(params[0])}, nil
+}
+
+// nameTestingParam names the first parameter of decl name, or the default
+// name for its type if name is empty, if it is unnamed or blank. If the
+// name is already used within decl, e.g. by a local variable, a number is
+// appended, as in t2. As parameters must either all be named or all be
+// unnamed, any further unnamed parameters are named _. The receiver of a
+// suite test method is named likewise, see nameSuiteReceiver.
+func nameTestingParam(decl *ast.FuncDecl, name string) {
+	params := decl.Type.Params.List
+	if decl.Recv != nil && len(decl.Recv.List) == 1 && len(params) == 0 && decl.Body != nil {
+		nameSuiteReceiver(decl)
+		return
+	}
+	if decl.Body == nil || len(params) == 0 {
+		return
+	}
+	if len(params[0].Names) > 0 && params[0].Names[0].Name != "_" {
+		return
+	}
+	if name == "" {
+		name = defaultParamName(params[0].Type)
+	}
+	name = unusedName(decl, name)
+	if len(params[0].Names) > 0 {
+		params[0].Names[0].Name = name
+		return
+	}
+	params[0].Names = []*ast.Ident{{NamePos: params[0].Type.Pos(), Name: name}}
+	for _, param := range params[1:] {
+		if len(param.Names) == 0 {
+			param.Names = []*ast.Ident{{NamePos: param.Type.Pos(), Name: "_"}}
+		}
+	}
+}
+
+// unusedName returns name, or if an identifier of that name occurs in decl
+// the first of name2, name3, ... which does not
+func unusedName(decl *ast.FuncDecl, name string) string {
+	used := make(map[string]bool)
+	ast.Inspect(decl, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Ident); ok {
+			used[ident.Name] = true
+		}
+		return true
+	})
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		candidate = name + strconv.Itoa(i)
+	}
+	return candidate
+}
+
+// nameSuiteReceiver names the receiver of the suite test method decl
+// suiteReceiverName if it is unnamed or blank
+func nameSuiteReceiver(decl *ast.FuncDecl) {
+	recv := decl.Recv.List[0]
+	if len(recv.Names) > 0 && recv.Names[0].Name != "_" {
+		return
+	}
+	name := unusedName(decl, suiteReceiverName)
+	if len(recv.Names) > 0 {
+		recv.Names[0].Name = name
+		return
+	}
+	recv.Names = []*ast.Ident{{NamePos: recv.Type.Pos(), Name: name}}
+}
+
+// defaultParamName returns the conventional name of a testing parameter of
+// type paramType
+func defaultParamName(paramType ast.Expr) string {
+	if star, ok := paramType.(*ast.StarExpr); ok {
+		typeName := star.X
+		if selector, ok := typeName.(*ast.SelectorExpr); ok {
+			typeName = selector.Sel
+		}
+		if ident, ok := typeName.(*ast.Ident); ok {
+			for _, kind := range funcKinds {
+				if kind.typeName == ident.Name {
+					return kind.paramName
+				}
+			}
+		}
+	}
+	return DefaultParamName
+}
+
+// removeMethodCallStmts removes the statements of the function body of
+// decl which call method on the testing parameter target
+func removeMethodCallStmts(decl *ast.FuncDecl, target testingTarget, method string) {
+	methods := map[string]bool{method: true}
+	list := decl.Body.List[:0]
+	for _, stmt := range decl.Body.List {
+		if !isMethodCallStmt(stmt, target, methods) {
+			list = append(list, stmt)
+		}
+	}
+	decl.Body.List = list
+}
+
+func funcError(fileSet *token.FileSet, decl *ast.FuncDecl, message string) error {
+	if fileSet != nil && decl.Pos().IsValid() {
+		return fmt.Errorf("%s: %s %s", fileSet.Position(decl.Pos()), decl.Name.Name, message)
+	}
+	return fmt.Errorf("%s %s", decl.Name.Name, message)
+}
+
+// skipTestExpr builds a
+//
+//	t.Skip("reason")
+//
+// call expression on target with all positions set to pos. The expression
+// is built by hand, as positions from a separately parsed source would
+// confuse the printer when the expression is inserted into another file.
+func skipTestExpr(target testingTarget, reason string, pos token.Pos) ast.Expr {
+	call := &ast.CallExpr{
+		Fun: &ast.SelectorExpr{
+			X:   target.expr(pos),
+			Sel: &ast.Ident{NamePos: pos, Name: "Skip"},
+		},
+		Lparen: pos,
+		Rparen: pos,
+	}
+	if reason != "" {
+		call.Args = []ast.Expr{&ast.BasicLit{ValuePos: pos, Kind: token.STRING, Value: strconv.Quote(reason)}}
+	}
+	return call
+}
+
+// testingQualifier returns the name of the testing package as used in the
+// type of param, e.g. "testing" for *testing.T or "" for a dot import
+func testingQualifier(param *ast.Field) string {
+	paramType := param.Type
+	if star, ok := paramType.(*ast.StarExpr); ok {
+		paramType = star.X
+	}
+	if selector, ok := paramType.(*ast.SelectorExpr); ok {
+		if ident, ok := selector.X.(*ast.Ident); ok {
+			return ident.Name
+		}
+	}
+	return ""
+}
+
+// insertStmt inserts stmt as the statement at index of the function body
+func insertStmt(f *ast.FuncDecl, index int, stmt ast.Stmt) {
+	newBodyList := make([]ast.Stmt, 0, len(f.Body.List)+1)
+	newBodyList = append(newBodyList, f.Body.List[:index]...)
+	newBodyList = append(newBodyList, stmt)
+	newBodyList = append(newBodyList, f.Body.List[index:]...)
+	f.Body.List = newBodyList
+}
@@ -0,0 +1,844 @@
+package testskipper
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func parseFuncDecl(t *testing.T, src string) (*token.FileSet, *ast.File, *ast.FuncDecl) {
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "foo_test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Error parsing source code: `%s`", src)
+	}
+	for _, decl := range file.Decls {
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+			return fileSet, file, funcDecl
+		}
+	}
+	t.Fatalf("No function declaration in source code: `%s`", src)
+	return nil, nil, nil
+}
+
+func TestApplySkip(t *testing.T) {
+	src := `
+	package main
+
+	import tst "testing"
+
+	func TestFoo(tt *tst.T) {
+		tt.Log("foo")
+	}`
+
+	tests := []struct {
+		opts     SkipOptions
+		expected string
+	}{
+		{SkipOptions{}, `tt.Skip()`},
+		{SkipOptions{Reason: "flaky"}, `tt.Skip("flaky")`},
+		{SkipOptions{ShortMode: true}, `if tst.Short() { tt.Skip() }`},
+	}
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+
+	for _, test := range tests {
+		fileSet, file, funcDecl := parseFuncDecl(t, src)
+
+		err := ApplySkip(fileSet, funcDecl, test.opts)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+
+		var buffer bytes.Buffer
+		printer.Fprint(&buffer, fileSet, file)
+		assertOnlySkipChanged(t, src, buffer.String())
+
+		expected := replacer.Replace(`
+		package main
+
+		import tst "testing"
+
+		func TestFoo(tt *tst.T) {
+			` + test.expected + `
+
+			tt.Log("foo")
+		}`)
+		actual := replacer.Replace(withoutSkipMarkers(buffer.String()))
+
+		if expected != actual {
+			t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+		}
+	}
+
+	// Missing testing parameter
+	fileSet, _, funcDecl := parseFuncDecl(t, "package main\n\nfunc TestFoo() {}\n")
+
+	err := ApplySkip(fileSet, funcDecl, SkipOptions{})
+
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	expectedMessage := "foo_test.go:3:1: TestFoo has no named testing parameter"
+	if err.Error() != expectedMessage {
+		t.Fatalf("Expected error message '%s', got '%s'\n", expectedMessage, err.Error())
+	}
+}
+
+func TestApplySkipIdempotent(t *testing.T) {
+	for _, opts := range []SkipOptions{{}, {Reason: "flaky"}, {ShortMode: true}, {EnvVar: "SKIP_FLAKY"}, {AfterCleanup: true}} {
+		src := "package main\n\nfunc TestFoo(t *testing.T) {\n\tt.Cleanup(func() {})\n\tt.Log(\"foo\")\n}\n"
+		fileSet, file, funcDecl := parseFuncDecl(t, src)
+
+		for i := 0; i < 2; i++ {
+			if err := ApplySkip(fileSet, funcDecl, opts); err != nil {
+				t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+			}
+		}
+
+		var buffer bytes.Buffer
+		printer.Fprint(&buffer, fileSet, file)
+
+		if count := strings.Count(buffer.String(), "t.Skip("); count != 1 {
+			t.Fatalf("Expected exactly one skip with options %+v, got %d in \n`%s`\n", opts, count, buffer.String())
+		}
+	}
+}
+
+func TestApplySkipEnvVar(t *testing.T) {
+	src := `package main
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+`
+	expected := `package main
+
+func TestFoo(t *testing.T) {
+	if os.Getenv("SKIP_FLAKY") != "" {
+		t.Skip("flaky")
+	}
+
+	t.Log("foo")
+}
+`
+	fileSet, file, funcDecl := parseFuncDecl(t, src)
+
+	err := ApplySkip(fileSet, funcDecl, SkipOptions{EnvVar: "SKIP_FLAKY", Reason: "flaky"})
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	if replacer.Replace(expected) != replacer.Replace(withoutSkipMarkers(buffer.String())) {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, buffer.String())
+	}
+	assertOnlySkipChanged(t, src, buffer.String())
+
+	// the guard is only removed by its own unskip
+	for _, unskip := range []func() error{
+		func() error { return ApplyUnskip(fileSet, funcDecl) },
+		func() error { return ApplyUnskipEnvGuard(fileSet, funcDecl, "OTHER") },
+	} {
+		if err := unskip(); err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		if len(funcDecl.Body.List) != 2 {
+			t.Fatalf("Expected the guard to be kept, got %d statements\n", len(funcDecl.Body.List))
+		}
+	}
+	if err := ApplyUnskipEnvGuard(fileSet, funcDecl, "SKIP_FLAKY"); err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	buffer.Reset()
+	printer.Fprint(&buffer, fileSet, file)
+	if replacer.Replace(src) != replacer.Replace(buffer.String()) {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", src, buffer.String())
+	}
+}
+
+func TestApplySkipAfterCleanup(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Cleanup(func() { t.Log("cleanup") })
+	t.Cleanup(cleanup)
+	// foo
+	t.Log("foo")
+	t.Cleanup(cleanup)
+}
+`
+	fileSet, file, funcDecl := parseFuncDecl(t, src)
+
+	err := ApplySkip(fileSet, funcDecl, SkipOptions{AfterCleanup: true})
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+	assertOnlySkipChanged(t, src, buffer.String())
+
+	expected := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Cleanup(func() { t.Log("cleanup") })
+	t.Cleanup(cleanup)
+	t.Skip()
+
+	// foo
+	t.Log("foo")
+	t.Cleanup(cleanup)
+}
+`
+	if expected != buffer.String() {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, buffer.String())
+	}
+
+	// Without leading cleanups the skip is placed at the top
+	fileSet, file, funcDecl = parseFuncDecl(t, "package main\n\nfunc TestFoo(t *testing.T) {\n\tt.Log(\"foo\")\n}\n")
+
+	err = ApplySkip(fileSet, funcDecl, SkipOptions{AfterCleanup: true})
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	buffer.Reset()
+	printer.Fprint(&buffer, fileSet, file)
+	expected = "package main\n\nfunc TestFoo(t *testing.T) {\n\tt.Skip()\n\n\tt.Log(\"foo\")\n}\n"
+	if expected != withoutSkipMarkers(buffer.String()) {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, buffer.String())
+	}
+}
+
+func TestApplySkipAfterCleanupTrailingComment(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Cleanup(func() {}) // c1
+	// log
+	t.Log("foo")
+}
+`
+	tests := []struct {
+		tight    bool
+		expected string
+	}{
+		{
+			false,
+			`package main
+
+import "testing"
+
+// gotestskipper:skip
+func TestFoo(t *testing.T) {
+	t.Cleanup(func() {}) // c1
+	t.Skip()
+
+	// log
+	t.Log("foo")
+}
+`,
+		},
+		{
+			true,
+			`package main
+
+import "testing"
+
+// gotestskipper:skip
+func TestFoo(t *testing.T) {
+	t.Cleanup(func() {}) // c1
+	t.Skip()
+	// log
+	t.Log("foo")
+}
+`,
+		},
+	}
+
+	for _, test := range tests {
+		for _, surgical := range []bool{false, true} {
+			visitor := NewTestFuncVisitor(SkipTestVisitorActionWithOptions(SkipOptions{AfterCleanup: true, Tight: test.tight}))
+			visitor.SetSurgical(surgical)
+			var buffer bytes.Buffer
+
+			err := walkSource("foo_test.go", []byte(src), &buffer, visitor)
+
+			if err != nil {
+				t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+			}
+			if buffer.String() != test.expected {
+				t.Fatalf("Expected with tight %t and surgical %t \n`%s`\n\n, got \n`%s`\n", test.tight, surgical, test.expected, buffer.String())
+			}
+		}
+	}
+}
+
+func TestApplySkipAfterSeeds(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+func FuzzFoo(f *testing.F) {
+	f.Add("foo")
+	f.Add("bar")
+	f.Add("")
+	f.Fuzz(func(t *testing.T, s string) {
+		t.Log(s)
+	})
+}
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "foo_test.go", src, parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+	visitor := NewTestFuncVisitor(SkipTestVisitorActionWithOptions(SkipOptions{AfterSeeds: true}))
+	visitor.SetFuzz(true)
+	ast.Walk(visitor, file)
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+	assertOnlySkipChanged(t, src, buffer.String())
+
+	expected := `package main
+
+import "testing"
+
+// gotestskipper:skip
+func FuzzFoo(f *testing.F) {
+	f.Add("foo")
+	f.Add("bar")
+	f.Add("")
+	f.Skip()
+
+	f.Fuzz(func(t *testing.T, s string) {
+		t.Log(s)
+	})
+}
+
+// gotestskipper:skip
+func TestFoo(t *testing.T) {
+	t.Skip()
+
+	t.Log("foo")
+}
+`
+	if expected != buffer.String() {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, buffer.String())
+	}
+
+	// Fuzz targets are only matched on request
+	file, err = parser.ParseFile(fileSet, "foo_test.go", src, parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+	ast.Walk(NewTestFuncVisitor(SkipTestVisitorAction), file)
+
+	buffer.Reset()
+	printer.Fprint(&buffer, fileSet, file)
+
+	if strings.Contains(buffer.String(), "f.Skip()") {
+		t.Fatalf("Expected fuzz target to be untouched, got \n`%s`\n", buffer.String())
+	}
+}
+
+func TestApplySkipAfterSeedsTrailingComment(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+func FuzzFoo(f *testing.F) {
+	f.Add(1) // seed one
+	f.Add(2) // seed two
+	f.Fuzz(func(t *testing.T, i int) {
+		t.Log(i)
+	})
+}
+`
+	tests := []struct {
+		tight    bool
+		expected string
+	}{
+		{
+			false,
+			`package main
+
+import "testing"
+
+// gotestskipper:skip
+func FuzzFoo(f *testing.F) {
+	f.Add(1) // seed one
+	f.Add(2) // seed two
+	f.Skip()
+
+	f.Fuzz(func(t *testing.T, i int) {
+		t.Log(i)
+	})
+}
+`,
+		},
+		{
+			true,
+			`package main
+
+import "testing"
+
+// gotestskipper:skip
+func FuzzFoo(f *testing.F) {
+	f.Add(1) // seed one
+	f.Add(2) // seed two
+	f.Skip()
+	f.Fuzz(func(t *testing.T, i int) {
+		t.Log(i)
+	})
+}
+`,
+		},
+	}
+
+	for _, test := range tests {
+		for _, surgical := range []bool{false, true} {
+			visitor := NewTestFuncVisitor(SkipTestVisitorActionWithOptions(SkipOptions{AfterSeeds: true, Tight: test.tight}))
+			visitor.SetFuzz(true)
+			visitor.SetSurgical(surgical)
+			var buffer bytes.Buffer
+
+			err := walkSource("foo_test.go", []byte(src), &buffer, visitor)
+
+			if err != nil {
+				t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+			}
+			if buffer.String() != test.expected {
+				t.Fatalf("Expected with tight %t and surgical %t \n`%s`\n\n, got \n`%s`\n", test.tight, surgical, test.expected, buffer.String())
+			}
+		}
+	}
+}
+
+func TestApplySkipRemoveParallel(t *testing.T) {
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{
+			"\tt.Parallel()\n\tt.Log(\"foo\")\n",
+			"\tt.Skip()\n\n\tt.Log(\"foo\")\n",
+		},
+		{
+			"\tt.Log(\"foo\")\n\tt.Parallel()\n\tt.Run(\"bar\", func(st *testing.T) {\n\t\tst.Parallel()\n\t})\n",
+			"\tt.Skip()\n\n\tt.Log(\"foo\")\n\tt.Run(\"bar\", func(st *testing.T) {\n\t\tst.Parallel()\n\t})\n",
+		},
+		// an already skipped test only loses its t.Parallel() calls
+		{
+			"\tt.Skip()\n\tt.Parallel()\n\tt.Log(\"foo\")\n",
+			"\tt.Skip()\n\tt.Log(\"foo\")\n",
+		},
+	}
+	header := "package main\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {\n"
+	for _, test := range tests {
+		fileSet, file, funcDecl := parseFuncDecl(t, header+test.src+"}\n")
+
+		err := ApplySkip(fileSet, funcDecl, SkipOptions{RemoveParallel: true})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		var buffer bytes.Buffer
+		printer.Fprint(&buffer, fileSet, file)
+		expected := replacer.Replace(header + test.expected + "}\n")
+		actual := replacer.Replace(withoutSkipMarkers(buffer.String()))
+		if expected != actual {
+			t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", header+test.expected+"}\n", buffer.String())
+		}
+
+		// unskipping does not restore the t.Parallel() calls
+		if err := ApplyUnskip(fileSet, funcDecl); err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		buffer.Reset()
+		printer.Fprint(&buffer, fileSet, file)
+		if strings.Contains(buffer.String(), "\tt.Parallel()") {
+			t.Fatalf("Expected unskipping not to restore t.Parallel(), got \n`%s`\n", buffer.String())
+		}
+	}
+}
+
+func TestApplySkipTight(t *testing.T) {
+	tests := []struct {
+		opts     SkipOptions
+		src      string
+		expected string
+	}{
+		{
+			SkipOptions{},
+			"\t// foo\n\tt.Log(\"foo\")\n",
+			"\tt.Skip()\n\n\t// foo\n\tt.Log(\"foo\")\n",
+		},
+		{
+			SkipOptions{Tight: true},
+			"\t// foo\n\tt.Log(\"foo\")\n",
+			"\tt.Skip()\n\t// foo\n\tt.Log(\"foo\")\n",
+		},
+		{
+			SkipOptions{ShortMode: true},
+			"\tt.Log(\"foo\")\n",
+			"\tif testing.Short() {\n\t\tt.Skip()\n\t}\n\n\tt.Log(\"foo\")\n",
+		},
+		{
+			SkipOptions{ShortMode: true, Tight: true},
+			"\tt.Log(\"foo\")\n",
+			"\tif testing.Short() {\n\t\tt.Skip()\n\t}\n\tt.Log(\"foo\")\n",
+		},
+		{
+			SkipOptions{AfterCleanup: true},
+			"\tt.Cleanup(cleanup)\n\tt.Log(\"foo\")\n",
+			"\tt.Cleanup(cleanup)\n\tt.Skip()\n\n\tt.Log(\"foo\")\n",
+		},
+		{
+			SkipOptions{AfterCleanup: true, Tight: true},
+			"\tt.Cleanup(cleanup)\n\tt.Log(\"foo\")\n",
+			"\tt.Cleanup(cleanup)\n\tt.Skip()\n\tt.Log(\"foo\")\n",
+		},
+		// a skip without following statement is never followed by a blank
+		// line
+		{
+			SkipOptions{},
+			"\t// foo\n",
+			"\tt.Skip()\n\t// foo\n",
+		},
+		{
+			SkipOptions{AfterCleanup: true},
+			"\tt.Cleanup(cleanup)\n",
+			"\tt.Cleanup(cleanup)\n\tt.Skip()\n",
+		},
+	}
+	header := "package main\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {\n"
+	for _, test := range tests {
+		fileSet, file, funcDecl := parseFuncDecl(t, header+test.src+"}\n")
+
+		err := ApplySkip(fileSet, funcDecl, test.opts)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		var buffer bytes.Buffer
+		printer.Fprint(&buffer, fileSet, file)
+		assertOnlySkipChanged(t, header+test.src+"}\n", buffer.String())
+		expected := header + test.expected + "}\n"
+		if expected != withoutSkipMarkers(buffer.String()) {
+			t.Fatalf("Expected for %+v \n`%s`\n\n, got \n`%s`\n", test.opts, expected, buffer.String())
+		}
+	}
+}
+
+func TestApplySkipUnnamedParam(t *testing.T) {
+	tests := []struct {
+		name      string
+		paramName string
+		src       string
+		expected  string
+	}{
+		{
+			name:     "unnamed",
+			src:      "func TestFoo(*testing.T) {\n\tfmt.Println()\n}",
+			expected: "func TestFoo(t *testing.T) {\n\tt.Skip()\n\n\tfmt.Println()\n}",
+		},
+		{
+			name:     "blank",
+			src:      "func TestFoo(_ *testing.T) {\n\tfmt.Println()\n}",
+			expected: "func TestFoo(t *testing.T) {\n\tt.Skip()\n\n\tfmt.Println()\n}",
+		},
+		{
+			name:     "blank with name in use",
+			src:      "func TestFoo(_ *testing.T) {\n\tt := 1\n\tfmt.Println(t)\n}",
+			expected: "func TestFoo(t2 *testing.T) {\n\tt2.Skip()\n\n\tt := 1\n\tfmt.Println(t)\n}",
+		},
+		{
+			name:      "custom name",
+			paramName: "tt",
+			src:       "func TestFoo(*testing.T) {\n\tfmt.Println()\n}",
+			expected:  "func TestFoo(tt *testing.T) {\n\ttt.Skip()\n\n\tfmt.Println()\n}",
+		},
+		{
+			name:     "trailing parameters",
+			src:      "func TestFoo(*testing.T, context.Context) {\n\tfmt.Println()\n}",
+			expected: "func TestFoo(t *testing.T, _ context.Context) {\n\tt.Skip()\n\n\tfmt.Println()\n}",
+		},
+		{
+			name:     "named",
+			src:      "func TestFoo(t *testing.T) {\n\tfmt.Println()\n}",
+			expected: "func TestFoo(t *testing.T) {\n\tt.Skip()\n\n\tfmt.Println()\n}",
+		},
+	}
+	header := "package main\n\nimport (\n\t\"context\"\n\t\"fmt\"\n\t\"testing\"\n)\n\nvar _ context.Context\n\n"
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fileSet, file, decl := parseFuncDecl(t, header+test.src+"\n")
+
+			err := ApplySkip(fileSet, decl, SkipOptions{ParamName: test.paramName})
+
+			if err != nil {
+				t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+			}
+			var buffer bytes.Buffer
+			printer.Fprint(&buffer, fileSet, file)
+			expected := header + test.expected + "\n"
+			if expected != withoutSkipMarkers(buffer.String()) {
+				t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, buffer.String())
+			}
+			// the output has to compile
+			outputFileSet := token.NewFileSet()
+			output, err := parser.ParseFile(outputFileSet, "foo_test.go", buffer.String(), 0)
+			if err != nil {
+				t.Fatalf("Expected valid output, got '%T' with message: '%s'\n", err, err.Error())
+			}
+			config := types.Config{Importer: importer.Default()}
+			if _, err := config.Check("main", outputFileSet, []*ast.File{output}, nil); err != nil {
+				t.Fatalf("Expected compilable output, got '%T' with message: '%s'\n", err, err.Error())
+			}
+		})
+	}
+}
+
+func TestApplyUnskip(t *testing.T) {
+	src := `
+	package main
+
+	import "testing"
+
+	func TestFoo(t *testing.T) {
+		t.Skip()
+
+		t.Log("foo")
+	}`
+	fileSet, file, funcDecl := parseFuncDecl(t, src)
+
+	err := ApplyUnskip(fileSet, funcDecl)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+	assertOnlySkipChanged(t, src, buffer.String())
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+
+	expected := replacer.Replace(`
+	package main
+
+	import "testing"
+
+	func TestFoo(t *testing.T) {
+		t.Log("foo")
+	}`)
+	actual := replacer.Replace(buffer.String())
+
+	if expected != actual {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+	}
+
+	// Skips with a reason are removed as well
+	for _, test := range []struct {
+		skip    string
+		removed bool
+	}{
+		{`t.Skip("flaky on \"CI\" #1234")`, true},
+		{"t.Skip(`reason`)", true},
+		{"t.Skip(reason)", true},
+		{`t.Skipf("reason %d", 42)`, true},
+		{"t.SkipNow()", true},
+		{"t.Fail()", false},
+	} {
+		src := "package main\n\nfunc TestFoo(t *testing.T) {\n\t" + test.skip + "\n\tt.Log(\"foo\")\n}\n"
+		fileSet, file, funcDecl := parseFuncDecl(t, src)
+
+		err := ApplyUnskip(fileSet, funcDecl)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		var buffer bytes.Buffer
+		printer.Fprint(&buffer, fileSet, file)
+		if removed := !strings.Contains(buffer.String(), test.skip); removed != test.removed {
+			t.Fatalf("Expected %s to be removed %t, got \n`%s`\n", test.skip, test.removed, buffer.String())
+		}
+	}
+
+	// Unnamed testing parameter
+	fileSet, _, funcDecl = parseFuncDecl(t, "package main\n\nfunc TestFoo(*testing.T) {}\n")
+
+	err = ApplyUnskip(fileSet, funcDecl)
+
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+}
+
+func TestApplyUnskipWithOptions(t *testing.T) {
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	tests := []struct {
+		name     string
+		body     string
+		opts     UnskipOptions
+		expected string
+	}{
+		{
+			name:     "after helper",
+			body:     "t.Helper()\n\tt.Skip()\n\tt.Log(\"foo\")",
+			expected: "t.Helper()\n\tt.Log(\"foo\")",
+		},
+		{
+			name:     "deeper in the body",
+			body:     "t.Log(\"foo\")\n\tt.Skipf(\"flaky %d\", 42)\n\tt.SkipNow()",
+			expected: "t.Log(\"foo\")\n\tt.SkipNow()",
+		},
+		{
+			name:     "nested",
+			body:     "if os.Getenv(\"CI\") != \"\" {\n\t\tt.Skip()\n\t}\n\tt.Log(\"foo\")",
+			expected: "if os.Getenv(\"CI\") != \"\" {\n\t\tt.Skip()\n\t}\n\tt.Log(\"foo\")",
+		},
+		{
+			name:     "nested opted in",
+			body:     "if os.Getenv(\"CI\") != \"\" {\n\t\tt.Log(\"ci\")\n\t} else {\n\t\tt.Skip()\n\t}\n\tt.SkipNow()",
+			opts:     UnskipOptions{Nested: true},
+			expected: "if os.Getenv(\"CI\") != \"\" {\n\t\tt.Log(\"ci\")\n\t} else {\n\t}\n\tt.SkipNow()",
+		},
+		{
+			name:     "nested in switch opted in",
+			body:     "switch {\n\tcase testing.Short():\n\t\tt.Skip()\n\t}",
+			opts:     UnskipOptions{Nested: true},
+			expected: "switch {\n\tcase testing.Short():\n\t}",
+		},
+		{
+			name:     "function literal opted in",
+			body:     "t.Run(\"bar\", func(t *testing.T) {\n\t\tt.Skip()\n\t})",
+			opts:     UnskipOptions{Nested: true},
+			expected: "t.Run(\"bar\", func(t *testing.T) {\n\t\tt.Skip()\n\t})",
+		},
+		{
+			name:     "empty body",
+			body:     "",
+			opts:     UnskipOptions{Nested: true},
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		src := "package main\n\nfunc TestFoo(t *testing.T) {\n\t" + test.body + "\n}\n"
+		fileSet, file, funcDecl := parseFuncDecl(t, src)
+
+		err := ApplyUnskipWithOptions(fileSet, funcDecl, test.opts)
+
+		if err != nil {
+			t.Fatalf("%s: Expected no error, got '%T' with message: '%s'\n", test.name, err, err.Error())
+		}
+		var buffer bytes.Buffer
+		printer.Fprint(&buffer, fileSet, file)
+		assertOnlySkipChanged(t, src, buffer.String())
+
+		expected := replacer.Replace("package main\n\nfunc TestFoo(t *testing.T) {\n\t" + test.expected + "\n}\n")
+		actual := replacer.Replace(buffer.String())
+
+		if expected != actual {
+			t.Fatalf("%s: Expected \n`%s`\n\n, got \n`%s`\n", test.name, expected, actual)
+		}
+	}
+}
+
+func TestApplyUnskipAll(t *testing.T) {
+	src := `
+	package main
+
+	import "testing"
+
+	func TestFoo(t *testing.T) {
+		t.Skip()
+		t.Skip("reason")
+		t.Skipf("reason %d", 42)
+		t.SkipNow()
+		if testing.Short() {
+			t.Skip()
+		}
+		if testing.Short() {
+			t.Skipf("too %s", "slow")
+		}
+
+		t.Log("foo")
+		t.Skip()
+	}`
+	fileSet, file, funcDecl := parseFuncDecl(t, src)
+
+	err := ApplyUnskipAll(fileSet, funcDecl)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+	assertOnlySkipChanged(t, src, buffer.String())
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+
+	expected := replacer.Replace(`
+	package main
+
+	import "testing"
+
+	func TestFoo(t *testing.T) {
+		t.Log("foo")
+		t.Skip()
+	}`)
+	actual := replacer.Replace(buffer.String())
+
+	if expected != actual {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+	}
+
+	// Guards with other conditions are kept
+	src = `
+	package main
+
+	import "testing"
+
+	func TestFoo(t *testing.T) {
+		if testing.Verbose() {
+			t.Skip()
+		}
+	}`
+	_, _, funcDecl = parseFuncDecl(t, src)
+
+	err = ApplyUnskipAll(nil, funcDecl)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if len(funcDecl.Body.List) != 1 {
+		t.Fatalf("Expected guard to be kept, got %d statements\n", len(funcDecl.Body.List))
+	}
+}
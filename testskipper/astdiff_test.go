@@ -0,0 +1,183 @@
+package testskipper
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var (
+	posType    = reflect.TypeOf(token.NoPos)
+	objectType = reflect.TypeOf(&ast.Object{})
+	scopeType  = reflect.TypeOf(&ast.Scope{})
+	stmtsType  = reflect.TypeOf([]ast.Stmt{})
+	fileType   = reflect.TypeOf(ast.File{})
+)
+
+// skipMarkerLine matches a line holding only the SkipMarker line comment
+var skipMarkerLine = regexp.MustCompile(`(?m)^[ \t]*// ` + SkipMarker + `\n`)
+
+// withoutSkipMarkers removes the lines holding only the SkipMarker from
+// src, for tests about the layout of the skip statements
+func withoutSkipMarkers(src string) string {
+	return skipMarkerLine.ReplaceAllString(src, "")
+}
+
+// assertOnlySkipChanged parses the sources before and after and walks both
+// ASTs in parallel, failing the test if they differ by anything but skip
+// statements inserted into or removed from statement lists and the lines of
+// their SkipMarker. Positions are ignored, so that a changed layout does not
+// count as a difference.
+func assertOnlySkipChanged(t *testing.T, before, after string) {
+	t.Helper()
+	beforeFile, err := parser.ParseFile(token.NewFileSet(), "before.go", withoutSkipMarkers(before), parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Error parsing source code: `%s`", before)
+	}
+	afterFile, err := parser.ParseFile(token.NewFileSet(), "after.go", withoutSkipMarkers(after), parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Error parsing source code: `%s`", after)
+	}
+	if path, ok := equalIgnoringSkips(reflect.ValueOf(beforeFile), reflect.ValueOf(afterFile), "File"); !ok {
+		t.Fatalf("Expected only skip statements to change, got a difference at %s between \n`%s`\n\nand \n`%s`\n", path, before, after)
+	}
+}
+
+// equalIgnoringSkips compares x and y structurally, ignoring positions,
+// identifier resolution and skip statements only present in one of them. If
+// they differ, the path to the first difference is returned.
+func equalIgnoringSkips(x, y reflect.Value, path string) (string, bool) {
+	if x.Type() != y.Type() {
+		return path, false
+	}
+	switch x.Type() {
+	case posType, objectType, scopeType:
+		return "", true
+	case stmtsType:
+		return equalStmtsIgnoringSkips(x.Interface().([]ast.Stmt), y.Interface().([]ast.Stmt), path)
+	}
+	switch x.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if x.IsNil() || y.IsNil() {
+			return path, x.IsNil() == y.IsNil()
+		}
+		return equalIgnoringSkips(x.Elem(), y.Elem(), path)
+	case reflect.Struct:
+		for i := 0; i < x.NumField(); i++ {
+			// the unresolved identifiers depend on the skip statements
+			if x.Type() == fileType && x.Type().Field(i).Name == "Unresolved" {
+				continue
+			}
+			if diff, ok := equalIgnoringSkips(x.Field(i), y.Field(i), path+"."+x.Type().Field(i).Name); !ok {
+				return diff, false
+			}
+		}
+		return "", true
+	case reflect.Slice:
+		if x.Len() != y.Len() {
+			return path, false
+		}
+		for i := 0; i < x.Len(); i++ {
+			if diff, ok := equalIgnoringSkips(x.Index(i), y.Index(i), path); !ok {
+				return diff, false
+			}
+		}
+		return "", true
+	default:
+		return path, x.Interface() == y.Interface()
+	}
+}
+
+// equalStmtsIgnoringSkips compares the statement lists x and y, skipping
+// skip statements which are only present in one of them
+func equalStmtsIgnoringSkips(x, y []ast.Stmt, path string) (string, bool) {
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		if i < len(x) && j < len(y) {
+			if _, ok := equalIgnoringSkips(reflect.ValueOf(x[i]), reflect.ValueOf(y[j]), path); ok {
+				i, j = i+1, j+1
+				continue
+			}
+		}
+		switch {
+		case i < len(x) && isAnySkipStmt(x[i]):
+			i++
+		case j < len(y) && isAnySkipStmt(y[j]):
+			j++
+		case i < len(x) && j < len(y):
+			// report the difference within the statements
+			return equalIgnoringSkips(reflect.ValueOf(x[i]), reflect.ValueOf(y[j]), path)
+		default:
+			return path, false
+		}
+	}
+	return "", true
+}
+
+// isAnySkipStmt reports whether stmt is a call of a skip method or helper
+// on any receiver, or such calls guarded by an if statement
+func isAnySkipStmt(stmt ast.Stmt) bool {
+	if ifStmt, ok := stmt.(*ast.IfStmt); ok && ifStmt.Else == nil && len(ifStmt.Body.List) > 0 {
+		for _, stmt := range ifStmt.Body.List {
+			if !isAnySkipStmt(stmt) {
+				return false
+			}
+		}
+		return true
+	}
+	exprStmt, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := exprStmt.X.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	return ok && strings.HasPrefix(selector.Sel.Name, "Skip")
+}
+
+func TestAssertOnlySkipChanged(t *testing.T) {
+	before := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+`
+	for _, after := range []string{
+		before,
+		strings.Replace(before, "{\n", "{\n\tt.Skip()\n\n", 1),
+		strings.Replace(before, "{\n", "{\n\tif testing.Short() {\n\t\tt.Skip()\n\t}\n", 1),
+		strings.Replace(before, "{\n", "{\n\ttestutil.Skip(t, \"flaky\")\n\tt.SkipNow()\n", 1),
+	} {
+		if path, ok := equalIgnoringSkips(reflect.ValueOf(parseFile(t, before)), reflect.ValueOf(parseFile(t, after)), "File"); !ok {
+			t.Fatalf("Expected no difference, got one at %s for \n`%s`\n", path, after)
+		}
+	}
+
+	for _, after := range []string{
+		strings.Replace(before, `"foo"`, `"bar"`, 1),
+		strings.Replace(before, "{\n", "{\n\tt.Parallel()\n", 1),
+		strings.Replace(before, "t.Log(\"foo\")\n", "", 1),
+		strings.Replace(before, "TestFoo", "TestBar", 1),
+		strings.Replace(before, "package main", "// Package main\npackage main", 1),
+	} {
+		if _, ok := equalIgnoringSkips(reflect.ValueOf(parseFile(t, before)), reflect.ValueOf(parseFile(t, after)), "File"); ok {
+			t.Fatalf("Expected a difference for \n`%s`\n", after)
+		}
+	}
+}
+
+func parseFile(t *testing.T, src string) *ast.File {
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Error parsing source code: `%s`", src)
+	}
+	return file
+}
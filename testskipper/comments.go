@@ -0,0 +1,293 @@
+package testskipper
+
+import (
+	"go/ast"
+	"go/token"
+	"reflect"
+	"sort"
+)
+
+// removeStmtComments removes the comments of the statements the visitAction
+// removed from body, which held the statements before before, from file.
+// These are the comments associated with a statement by ast.CommentMap,
+// like
+//
+//	// TODO: re-enable after #42
+//	t.Skip()
+//
+// as well as the comments within it. Statements replaced in place, i.e. by
+// a statement at the same position, keep their comments.
+func removeStmtComments(fileSet *token.FileSet, file *ast.File, body *ast.BlockStmt, before []ast.Stmt) {
+	removed := removedStmts(before, body.List)
+	if len(removed) == 0 {
+		return
+	}
+	replaced := make(map[token.Pos]bool)
+	for _, stmt := range body.List {
+		replaced[stmt.Pos()] = true
+	}
+	bodyComments := blockComments(file, body)
+	commentMap := ast.NewCommentMap(fileSet, &ast.BlockStmt{Lbrace: body.Lbrace, List: before, Rbrace: body.Rbrace}, bodyComments)
+	var groups []*ast.CommentGroup
+	for _, stmt := range removed {
+		if replaced[stmt.Pos()] {
+			continue
+		}
+		groups = append(groups, commentMap[stmt]...)
+		for _, group := range bodyComments {
+			if group.Pos() >= stmt.Pos() && group.End() <= stmt.End() {
+				groups = append(groups, group)
+			}
+		}
+	}
+	removeComments(file, groups...)
+}
+
+// blockComments returns the comments of file within block
+func blockComments(file *ast.File, block *ast.BlockStmt) []*ast.CommentGroup {
+	var groups []*ast.CommentGroup
+	for _, group := range file.Comments {
+		if group.Pos() > block.Lbrace && group.End() <= block.Rbrace {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// moveStmtComments moves the comments of the statements of body, which held
+// the statements before before, along with the statements the visitAction
+// reordered, see reorderStmts. The printer places comments by their
+// position in the source, so that they would stay where they were. A
+// statement inserted after one with a trailing comment is moved below the
+// comment, see placeInsertedStmts.
+func moveStmtComments(fileSet *token.FileSet, file *ast.File, body *ast.BlockStmt, before []ast.Stmt) {
+	reorderStmts(fileSet, file, body, before)
+	placeInsertedStmts(fileSet, file, body, before)
+	sort.SliceStable(file.Comments, func(i, j int) bool {
+		return fileSet.Position(file.Comments[i].Pos()).Offset < fileSet.Position(file.Comments[j].Pos()).Offset
+	})
+}
+
+// stmtExtent is the range of offsets in the source of a statement together
+// with its comments
+type stmtExtent struct {
+	start, end int
+	groups     []*ast.CommentGroup
+	// delta is the distance the extent is moved by
+	delta int
+}
+
+// reorderStmts lays out the statements the visitAction kept in body but
+// reordered like the printer would print the source they were cut and
+// pasted in. Each statement is moved together with its comments as
+// associated by ast.CommentMap, while the text between them is kept in
+// place. As the source cannot be changed, the reordered range is mirrored
+// by a file of the same name with the lines of the reordered source, which
+// the moved statements and comments are placed into.
+func reorderStmts(fileSet *token.FileSet, file *ast.File, body *ast.BlockStmt, before []ast.Stmt) {
+	index := make(map[ast.Stmt]int, len(before))
+	for i, stmt := range before {
+		index[stmt] = i
+	}
+	var order []ast.Stmt
+	for _, stmt := range body.List {
+		if _, ok := index[stmt]; ok {
+			order = append(order, stmt)
+		}
+	}
+	stmts := append([]ast.Stmt(nil), order...)
+	sort.Slice(stmts, func(i, j int) bool {
+		return index[stmts[i]] < index[stmts[j]]
+	})
+	first, last := 0, len(stmts)-1
+	for first <= last && order[first] == stmts[first] {
+		first++
+	}
+	for last > first && order[last] == stmts[last] {
+		last--
+	}
+	if first > last {
+		return
+	}
+	order, stmts = order[first:last+1], stmts[first:last+1]
+	tokenFile := fileSet.File(body.Lbrace)
+	if tokenFile == nil {
+		return
+	}
+	inFile := func(pos token.Pos) bool {
+		return pos.IsValid() && int(pos) >= tokenFile.Base() && int(pos) <= tokenFile.Base()+tokenFile.Size()
+	}
+
+	// the extents of the statements in source order, each taking the
+	// comments up to it which are associated with no statement
+	bodyComments := blockComments(file, body)
+	commentMap := ast.NewCommentMap(fileSet, &ast.BlockStmt{Lbrace: body.Lbrace, List: before, Rbrace: body.Rbrace}, bodyComments)
+	extents := make([]stmtExtent, len(stmts))
+	extentOf := make(map[ast.Stmt]*stmtExtent, len(stmts))
+	for i, stmt := range stmts {
+		if !inFile(stmt.Pos()) || !inFile(stmt.End()) {
+			return
+		}
+		extents[i] = stmtExtent{start: tokenFile.Offset(stmt.Pos()), end: tokenFile.Offset(stmt.End())}
+		extentOf[stmt] = &extents[i]
+	}
+	owner := make(map[*ast.CommentGroup]*stmtExtent)
+	for node, groups := range commentMap {
+		for i, stmt := range stmts {
+			if node.Pos() >= stmt.Pos() && node.End() <= stmt.End() {
+				for _, group := range groups {
+					owner[group] = &extents[i]
+				}
+			}
+		}
+	}
+	for _, group := range bodyComments {
+		if !inFile(group.Pos()) {
+			continue
+		}
+		start, end := tokenFile.Offset(group.Pos()), tokenFile.Offset(group.End())
+		extent, ok := owner[group]
+		if !ok {
+			for i := range extents {
+				if start < extents[i].end {
+					if i > 0 || start >= extents[0].start {
+						extent = &extents[i]
+					}
+					break
+				}
+			}
+		}
+		if extent == nil {
+			continue
+		}
+		extent.groups = append(extent.groups, group)
+		if start < extent.start {
+			extent.start = start
+		}
+		if end > extent.end {
+			extent.end = end
+		}
+	}
+	for i := 1; i < len(extents); i++ {
+		if extents[i-1].end > extents[i].start {
+			return
+		}
+	}
+
+	// the statements are laid out in their new order, each followed by the
+	// text which followed the statement at that place before
+	regionStart, regionEnd := extents[0].start, extents[len(extents)-1].end
+	gapDeltas := make([]int, len(extents)-1)
+	offset := regionStart
+	for i, stmt := range order {
+		extent := extentOf[stmt]
+		extent.delta = offset - extent.start
+		offset += extent.end - extent.start
+		if i < len(gapDeltas) {
+			gapDeltas[i] = offset - extents[i].end
+			offset += extents[i+1].start - extents[i].end
+		}
+	}
+	lines := make([]int, 0, tokenFile.LineCount())
+	for line := 1; line <= tokenFile.LineCount(); line++ {
+		start := tokenFile.Offset(tokenFile.LineStart(line))
+		if newline := start - 1; line > 1 && newline >= regionStart && newline < regionEnd {
+			for i := range extents {
+				if newline < extents[i].end {
+					if newline >= extents[i].start {
+						start += extents[i].delta
+					} else {
+						start += gapDeltas[i-1]
+					}
+					break
+				}
+			}
+		}
+		lines = append(lines, start)
+	}
+	sort.Ints(lines)
+	reordered := fileSet.AddFile(tokenFile.Name(), -1, tokenFile.Size())
+	if !reordered.SetLines(lines) {
+		return
+	}
+	for i, stmt := range stmts {
+		delta := extents[i].delta
+		move := func(pos token.Pos) token.Pos {
+			if !inFile(pos) {
+				return pos
+			}
+			return reordered.Pos(tokenFile.Offset(pos) + delta)
+		}
+		mapPositions(stmt, move)
+		for _, group := range extents[i].groups {
+			for _, comment := range group.List {
+				comment.Slash = move(comment.Slash)
+			}
+		}
+	}
+}
+
+// placeInsertedStmts moves the statements the visitAction inserted into
+// body, which held the statements before before, below the
+// trailing comment of the preceding statement, like in
+//
+//	t.Cleanup(cleanup) // cleans up
+//	t.Skip()
+//
+// A statement at detachedPos is moved into a file of the same name with
+// all offsets on the first line, so that the printer still separates it
+// from the following statement by a blank line.
+func placeInsertedStmts(fileSet *token.FileSet, file *ast.File, body *ast.BlockStmt, before []ast.Stmt) {
+	kept := make(map[ast.Stmt]bool, len(before))
+	for _, stmt := range before {
+		kept[stmt] = true
+	}
+	for i := 1; i < len(body.List); i++ {
+		stmt, preceding := body.List[i], body.List[i-1]
+		if kept[stmt] {
+			continue
+		}
+		end := fileSet.Position(preceding.End())
+		var trailing *ast.CommentGroup
+		for _, group := range file.Comments {
+			position := fileSet.Position(group.Pos())
+			if position.Filename == end.Filename && position.Line == end.Line && position.Offset >= end.Offset {
+				trailing = group
+			}
+		}
+		if trailing == nil {
+			continue
+		}
+		pos := trailing.End()
+		if fileSet.File(stmt.Pos()) == nil {
+			tokenFile := fileSet.File(pos)
+			detached := fileSet.AddFile(tokenFile.Name(), -1, tokenFile.Size())
+			pos = detached.Pos(tokenFile.Offset(pos))
+		}
+		mapPositions(stmt, func(token.Pos) token.Pos {
+			return pos
+		})
+	}
+}
+
+// mapPositions replaces each valid position in the syntax tree of node by
+// the result of move. Comments are left untouched.
+func mapPositions(node ast.Node, move func(token.Pos) token.Pos) {
+	posType := reflect.TypeOf(token.NoPos)
+	ast.Inspect(node, func(node ast.Node) bool {
+		if _, ok := node.(*ast.CommentGroup); ok {
+			return false
+		}
+		value := reflect.ValueOf(node)
+		if node == nil || value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+			return true
+		}
+		value = value.Elem()
+		for i := 0; i < value.NumField(); i++ {
+			if field := value.Field(i); field.Type() == posType && field.Interface() != token.NoPos {
+				field.Set(reflect.ValueOf(move(field.Interface().(token.Pos))))
+			}
+		}
+		return true
+	})
+}
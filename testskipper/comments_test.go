@@ -0,0 +1,56 @@
+package testskipper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUnskipRemovesSkipComment(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	// TODO: re-enable after #42
+	t.Skip() // flaky
+
+	// the actual test
+	t.Log("foo")
+}
+
+func TestBar(t *testing.T) {
+	// not skipped
+	t.Log("bar")
+}
+`
+	expected := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	// the actual test
+	t.Log("foo")
+}
+
+func TestBar(t *testing.T) {
+	// not skipped
+	t.Log("bar")
+}
+`
+	for _, surgical := range []bool{false, true} {
+		visitor := NewTestFuncVisitor(UnskipTestVisitorAction)
+		visitor.SetSurgical(surgical)
+		var buffer bytes.Buffer
+
+		err := walkSource("foo_test.go", []byte(src), &buffer, visitor)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+		if replacer.Replace(buffer.String()) != replacer.Replace(expected) {
+			t.Fatalf("Expected with surgical %t \n`%s`\n, got \n`%s`\n", surgical, expected, buffer.String())
+		}
+	}
+}
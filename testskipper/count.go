@@ -0,0 +1,70 @@
+package testskipper
+
+import (
+	"go/ast"
+	"path/filepath"
+)
+
+// TestCount tallies the test functions matched by a visitor
+type TestCount struct {
+	// Total is the number of matched test functions
+	Total int
+	// Skipped is the number of matched test functions which are skipped
+	Skipped int
+	// Files maps the name of each file to the number of its matched test
+	// functions. The names are only known if the visitor was provided
+	// with a token.FileSet, otherwise all functions count for "".
+	Files map[string]int
+	// Packages maps the directory of each file, i.e. its package, to the
+	// number of its matched test functions
+	Packages map[string]int
+}
+
+// Add returns the sum of c and other
+func (c TestCount) Add(other TestCount) TestCount {
+	sum := TestCount{
+		Total:    c.Total + other.Total,
+		Skipped:  c.Skipped + other.Skipped,
+		Files:    make(map[string]int),
+		Packages: make(map[string]int),
+	}
+	for _, count := range []TestCount{c, other} {
+		for file, n := range count.Files {
+			sum.Files[file] += n
+		}
+		for pkg, n := range count.Packages {
+			sum.Packages[pkg] += n
+		}
+	}
+	return sum
+}
+
+// Count tallies the functions of r
+func (r *Report) Count() TestCount {
+	count := TestCount{Files: make(map[string]int), Packages: make(map[string]int)}
+	for _, funcReport := range r.Funcs {
+		count.Total++
+		if funcReport.Skipped {
+			count.Skipped++
+		}
+		file := funcReport.Position.Filename
+		count.Files[file]++
+		count.Packages[filepath.Dir(file)]++
+	}
+	return count
+}
+
+// CountVisitorAction defines a visitAction which leaves the test function
+// untouched, so that the Report of the visitor only tallies the functions
+// which would be acted on, see NewCountTestFuncVisitor
+func CountVisitorAction(*ast.FuncDecl) {}
+
+// NewCountTestFuncVisitor returns a visitor which adds the test functions
+// it matches to report without modifying them. The matching is configured
+// like for any other visitor, so that report.Count() tells how many tests
+// the same configuration would skip.
+func NewCountTestFuncVisitor(report *Report) TestFuncVisitor {
+	visitor := NewTestFuncVisitor(CountVisitorAction)
+	visitor.SetReport(report)
+	return visitor
+}
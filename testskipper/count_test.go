@@ -0,0 +1,96 @@
+package testskipper
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestNewCountTestFuncVisitor(t *testing.T) {
+	sources := map[string]string{
+		"foo/foo_test.go": `package foo
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+
+func TestBar(t *testing.T) {
+	t.Skip()
+}
+
+func helper(t *testing.T) {}
+`,
+		"foo/bar_test.go": `package foo
+
+import "testing"
+
+func TestBaz(t *testing.T) {}
+`,
+		"qux/qux_test.go": `package qux
+
+import "testing"
+
+func TestQux(t *testing.T) {}
+`,
+	}
+	fileSet := token.NewFileSet()
+	report := &Report{}
+	visitor := NewCountTestFuncVisitor(report)
+	visitor.SetFileSet(fileSet)
+
+	for _, name := range []string{"foo/bar_test.go", "foo/foo_test.go", "qux/qux_test.go"} {
+		file, err := parser.ParseFile(fileSet, name, sources[name], parser.ParseComments)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err)
+		}
+		ast.Walk(visitor, file)
+
+		// counting leaves the file untouched
+		var buffer bytes.Buffer
+		if err := format.Node(&buffer, fileSet, file); err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err)
+		}
+		if buffer.String() != sources[name] {
+			t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", sources[name], buffer.String())
+		}
+	}
+
+	expected := TestCount{
+		Total:    4,
+		Skipped:  1,
+		Files:    map[string]int{"foo/foo_test.go": 2, "foo/bar_test.go": 1, "qux/qux_test.go": 1},
+		Packages: map[string]int{"foo": 3, "qux": 1},
+	}
+
+	actual := report.Count()
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("Expected count to equal\n%+v\n\tgot\n%+v\n", expected, actual)
+	}
+}
+
+func TestTestCountAdd(t *testing.T) {
+	count := TestCount{Total: 2, Skipped: 1, Files: map[string]int{"foo/foo_test.go": 2}, Packages: map[string]int{"foo": 2}}
+	other := TestCount{Total: 2, Files: map[string]int{"foo/bar_test.go": 1, "bar/bar_test.go": 1}, Packages: map[string]int{"foo": 1, "bar": 1}}
+	expected := TestCount{
+		Total:    4,
+		Skipped:  1,
+		Files:    map[string]int{"foo/foo_test.go": 2, "foo/bar_test.go": 1, "bar/bar_test.go": 1},
+		Packages: map[string]int{"foo": 3, "bar": 1},
+	}
+
+	actual := count.Add(other)
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("Expected sum to equal\n%+v\n\tgot\n%+v\n", expected, actual)
+	}
+	if count.Total != 2 || len(count.Files) != 1 {
+		t.Fatalf("Expected the summands to stay unchanged, got %+v\n", count)
+	}
+}
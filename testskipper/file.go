@@ -0,0 +1,35 @@
+package testskipper
+
+import "go/ast"
+
+// SkipTests adds a
+//
+//	t.Skip()
+//
+// statement to every test function of file which is not skipped yet and
+// returns the number of functions changed. Unlike WalkFile, file is neither
+// parsed nor printed, so that the transform can be composed with other
+// rewrites of an already parsed file.
+func SkipTests(file *ast.File) int {
+	return applyToFile(file, SkipTestVisitorAction)
+}
+
+// UnskipTests removes a leading
+//
+//	t.Skip()
+//
+// statement from every test function of file and returns the number of
+// functions changed. Like SkipTests, it does not touch the file system.
+func UnskipTests(file *ast.File) int {
+	return applyToFile(file, UnskipTestVisitorAction)
+}
+
+// applyToFile calls visitAction on the test functions of file and returns
+// the number of functions it changed
+func applyToFile(file *ast.File, visitAction FuncVisitAction) int {
+	visitor := NewTestFuncVisitor(visitAction)
+	report := &Report{}
+	visitor.SetReport(report)
+	ast.Walk(visitor, file)
+	return len(report.Changed())
+}
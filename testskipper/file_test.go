@@ -0,0 +1,53 @@
+package testskipper
+
+import (
+	"bytes"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestSkipTestsAndUnskipTests(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Skip()
+}
+
+func TestBar(t *testing.T) {
+	t.Log("bar")
+}
+
+func TestBaz(t *testing.T) {}
+
+func helper(t *testing.T) {}
+`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Error parsing source code: `%s`", src)
+	}
+
+	if changed := SkipTests(file); changed != 2 {
+		t.Fatalf("Expected 2 tests to be skipped, got %d\n", changed)
+	}
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+	if count := strings.Count(buffer.String(), "t.Skip()"); count != 3 {
+		t.Fatalf("Expected 3 skipped tests, got %d in \n`%s`\n", count, buffer.String())
+	}
+	assertOnlySkipChanged(t, src, buffer.String())
+
+	if changed := UnskipTests(file); changed != 3 {
+		t.Fatalf("Expected 3 tests to be unskipped, got %d\n", changed)
+	}
+	buffer.Reset()
+	printer.Fprint(&buffer, fileSet, file)
+	if strings.Contains(buffer.String(), "t.Skip()") {
+		t.Fatalf("Expected no skipped tests, got \n`%s`\n", buffer.String())
+	}
+}
@@ -0,0 +1,128 @@
+package testskipper
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+// SkipForm is the canonical form hand-written skips are fixed to
+type SkipForm int
+
+const (
+	// SkipFormSkip is the form
+	//
+	//	t.Skip()
+	//
+	// which is also the form added by SkipTestVisitorAction and recognized
+	// by UnskipTestVisitorAction
+	SkipFormSkip SkipForm = iota
+	// SkipFormSkipNow is the form
+	//
+	//	t.SkipNow()
+	SkipFormSkipNow
+)
+
+var skipFormMethods = map[SkipForm]string{
+	SkipFormSkip:    "Skip",
+	SkipFormSkipNow: "SkipNow",
+}
+
+// FixExistingSkipsVisitorAction returns a visitAction which rewrites the
+// leading skip statements of the test function to the given form, see
+// ApplyFixExisting
+func FixExistingSkipsVisitorAction(form SkipForm) FuncVisitAction {
+	return func(f *ast.FuncDecl) {
+		if err := ApplyFixExisting(nil, f, form); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// ApplyFixExisting rewrites all leading skip statements of decl which carry
+// no reason, i.e.
+//
+//	t.Skip()
+//	t.Skip("")
+//	t.Skipf("")
+//	t.SkipNow()
+//
+// to the given form. Skips with a reason are left untouched, so that the
+// reason is not lost. If any skip is rewritten, decl is marked by the
+// SkipMarker like a skip added by ApplySkip.
+//
+// fileSet is only used to add position information to errors and may be
+// nil.
+func ApplyFixExisting(fileSet *token.FileSet, decl *ast.FuncDecl, form SkipForm) error {
+	target, err := testingTargetOf(fileSet, decl)
+	if err != nil {
+		return err
+	}
+	for i, stmt := range decl.Body.List {
+		if !isSkipCallStmt(stmt, target) {
+			break
+		}
+		call := stmt.(*ast.ExprStmt).X.(*ast.CallExpr)
+		if !hasNoReason(call) {
+			continue
+		}
+		pos := stmt.Pos()
+		decl.Body.List[i] = &ast.ExprStmt{X: &ast.CallExpr{
+			Fun: &ast.SelectorExpr{
+				X:   target.expr(pos),
+				Sel: &ast.Ident{NamePos: pos, Name: skipFormMethods[form]},
+			},
+			Lparen: pos,
+			Rparen: pos,
+		}}
+		addSkipMarker(decl)
+	}
+	return nil
+}
+
+// hasNoReason reports whether call has no arguments or only empty string
+// literals as arguments
+func hasNoReason(call *ast.CallExpr) bool {
+	for _, arg := range call.Args {
+		lit, ok := arg.(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return false
+		}
+		if value, err := strconv.Unquote(lit.Value); err != nil || value != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// PruneSkipsVisitorAction returns a visitAction which collapses the leading
+// skip statements of the test function to the first one, see ApplyPrune
+func PruneSkipsVisitorAction(calls ...SkipCall) FuncVisitAction {
+	return func(f *ast.FuncDecl) {
+		if err := ApplyPrune(nil, f, calls...); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// ApplyPrune removes all but the first of the consecutive skip statements
+// at the beginning of decl, as e.g. left by repeated runs of ApplySkip. As
+// the first skip ends the test, the others are never reached. Calls of the
+// given skip helpers count as skip statements.
+//
+// fileSet is only used to add position information to errors and may be
+// nil.
+func ApplyPrune(fileSet *token.FileSet, decl *ast.FuncDecl, calls ...SkipCall) error {
+	target, err := testingTargetOf(fileSet, decl)
+	if err != nil {
+		return err
+	}
+	count := 0
+	for count < len(decl.Body.List) && isSkipStmt(decl.Body.List[count], target, calls) {
+		count++
+	}
+	if count > 1 {
+		decl.Body.List = append(decl.Body.List[:1], decl.Body.List[count:]...)
+	}
+	return nil
+}
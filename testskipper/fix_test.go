@@ -0,0 +1,142 @@
+package testskipper
+
+import (
+	"bytes"
+	"go/printer"
+	"strings"
+	"testing"
+)
+
+func TestApplyFixExisting(t *testing.T) {
+	tests := []struct {
+		skip     string
+		form     SkipForm
+		expected string
+		// marked tells whether the fixed skip is marked
+		marked bool
+	}{
+		{`t.Skip()`, SkipFormSkip, `t.Skip()`, true},
+		{`t.Skip("")`, SkipFormSkip, `t.Skip()`, true},
+		{"t.Skipf(``)", SkipFormSkip, `t.Skip()`, true},
+		{`t.SkipNow()`, SkipFormSkip, `t.Skip()`, true},
+		{`t.Skip()`, SkipFormSkipNow, `t.SkipNow()`, true},
+		{`t.Skipf("")`, SkipFormSkipNow, `t.SkipNow()`, true},
+		{`t.Skip("flaky")`, SkipFormSkip, `t.Skip("flaky")`, false},
+		{`t.Skipf("issue %d", 42)`, SkipFormSkip, `t.Skipf("issue %d", 42)`, false},
+		{`t.Log("foo")`, SkipFormSkip, `t.Log("foo")`, false},
+	}
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+
+	for _, test := range tests {
+		src := `
+		package main
+
+		import "testing"
+
+		func TestFoo(t *testing.T) {
+			` + test.skip + `
+			t.Log("foo")
+		}`
+		fileSet, file, funcDecl := parseFuncDecl(t, src)
+
+		err := ApplyFixExisting(fileSet, funcDecl, test.form)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+
+		var buffer bytes.Buffer
+		printer.Fprint(&buffer, fileSet, file)
+		assertOnlySkipChanged(t, src, buffer.String())
+
+		expected := replacer.Replace(strings.Replace(src, test.skip, test.expected, 1))
+		actual := replacer.Replace(withoutSkipMarkers(buffer.String()))
+
+		if expected != actual {
+			t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+		}
+		if marked := hasSkipMarker(funcDecl); marked != test.marked {
+			t.Fatalf("Expected %s to be marked %t, got %t\n", test.skip, test.marked, marked)
+		}
+		// the fixed skips are consistent with their marker
+		if inconsistencies := Validate(fileSet, file); len(inconsistencies) != 0 {
+			t.Fatalf("Expected no inconsistencies for %s, got %v\n", test.skip, inconsistencies)
+		}
+	}
+
+	// Fixed skips are recognized by ApplyUnskip, which removes the marker
+	fileSet, file, funcDecl := parseFuncDecl(t, "package main\n\nfunc TestFoo(t *testing.T) {\n\tt.SkipNow()\n\tt.Log(\"foo\")\n}\n")
+
+	if err := ApplyFixExisting(fileSet, funcDecl, SkipFormSkip); err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if err := ApplyUnskip(fileSet, funcDecl); err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+
+	expected := "package main\n\nfunc TestFoo(t *testing.T) {\n\tt.Log(\"foo\")\n}\n"
+	if replacer.Replace(expected) != replacer.Replace(buffer.String()) {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, buffer.String())
+	}
+}
+
+func TestApplyPrune(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected string
+	}{
+		// two stacked skips
+		{
+			"t.Skip()\n\tt.Skip()\n\tt.Log(\"foo\")",
+			"t.Skip()\n\tt.Log(\"foo\")",
+		},
+		// three stacked skips, the first one is kept
+		{
+			"t.Skip(\"flaky\")\n\tt.Skip()\n\tt.SkipNow()\n\tt.Log(\"foo\")",
+			"t.Skip(\"flaky\")\n\tt.Log(\"foo\")",
+		},
+		// a lone skip
+		{
+			"t.Skip()\n\tt.Skip()",
+			"t.Skip()",
+		},
+		// helper calls count as skips
+		{
+			"testutil.Skip(t)\n\tt.Skip()\n\tt.Log(\"foo\")",
+			"testutil.Skip(t)\n\tt.Log(\"foo\")",
+		},
+		// skips after other statements are kept
+		{
+			"t.Skip()\n\tt.Log(\"foo\")\n\tt.Skip()",
+			"t.Skip()\n\tt.Log(\"foo\")\n\tt.Skip()",
+		},
+		{
+			"t.Log(\"foo\")",
+			"t.Log(\"foo\")",
+		},
+	}
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+
+	for _, test := range tests {
+		fileSet, file, funcDecl := parseFuncDecl(t, "package main\n\nfunc TestFoo(t *testing.T) {\n\t"+test.body+"\n}\n")
+
+		err := ApplyPrune(fileSet, funcDecl, SkipCall{Qualifier: "testutil", Name: "Skip"})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		var buffer bytes.Buffer
+		printer.Fprint(&buffer, fileSet, file)
+		assertOnlySkipChanged(t, "package main\n\nfunc TestFoo(t *testing.T) {\n\t"+test.body+"\n}\n", buffer.String())
+
+		expected := "package main\n\nfunc TestFoo(t *testing.T) {\n\t" + test.expected + "\n}\n"
+		if replacer.Replace(expected) != replacer.Replace(buffer.String()) {
+			t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, buffer.String())
+		}
+	}
+}
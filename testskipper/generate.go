@@ -0,0 +1,28 @@
+package testskipper
+
+import (
+	"go/ast"
+	"strings"
+)
+
+const generateDirective = "//go:generate"
+
+// HasGenerateDirective reports whether file contains a
+//
+//	//go:generate
+//
+// directive before its package clause. Such files are usually regenerated,
+// so that any edits made to them may be overwritten.
+func HasGenerateDirective(file *ast.File) bool {
+	for _, group := range file.Comments {
+		if group.Pos() > file.Package {
+			break
+		}
+		for _, comment := range group.List {
+			if strings.HasPrefix(comment.Text, generateDirective+" ") {
+				return true
+			}
+		}
+	}
+	return false
+}
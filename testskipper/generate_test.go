@@ -0,0 +1,33 @@
+package testskipper
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestHasGenerateDirective(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected bool
+	}{
+		{"//go:generate stringer -type=Foo\n\npackage main\n", true},
+		{"// Package main does things\n//go:generate mockgen -source=foo.go\npackage main\n", true},
+		{"// go:generate is not a directive\npackage main\n", false},
+		{"package main\n\n//go:generate stringer -type=Foo\n", false},
+		{"package main\n", false},
+	}
+
+	for _, test := range tests {
+		file, err := parser.ParseFile(token.NewFileSet(), "", test.src, parser.ParseComments)
+		if err != nil {
+			panic(err)
+		}
+
+		actual := HasGenerateDirective(file)
+
+		if actual != test.expected {
+			t.Fatalf("Expected %t for `%s`, got %t\n", test.expected, test.src, actual)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package testskipper
+
+import (
+	"go/ast"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fileFinisher is implemented by visitors which post-process a file after
+// all of its functions were visited
+type fileFinisher interface {
+	finishFile(file *ast.File)
+}
+
+// finishFile drops the lines emptied by the actions on the functions of
+// file, see dropLines, adds the imports needed and removes the imports
+// which became unused
+func (f *testFuncVisitor) finishFile(file *ast.File) {
+	if offsets, ok := f.emptied[file]; ok {
+		delete(f.emptied, file)
+		if f.fileSet != nil {
+			dropLines(f.fileSet, file, offsets)
+		}
+	}
+	refs, ok := f.refs[file]
+	if !ok {
+		return
+	}
+	delete(f.refs, file)
+	added, addedPaths := addMissingImports(file, refs)
+	if len(added) > 0 && f.fileSet != nil {
+		ast.SortImports(f.fileSet, file)
+	}
+	removed, removedPaths := removeUnusedImports(file, refs)
+	for _, decl := range append(added, removed...) {
+		if _, ok := f.changed[decl]; f.changed != nil && !ok {
+			f.changed[decl] = declStart(decl)
+		}
+	}
+	if f.report == nil {
+		return
+	}
+	var filename string
+	if f.fileSet != nil {
+		filename = f.fileSet.Position(file.Package).Filename
+	}
+	for _, importPath := range addedPaths {
+		f.report.Imports = append(f.report.Imports, ImportChange{Filename: filename, Path: importPath})
+	}
+	for _, importPath := range removedPaths {
+		f.report.Imports = append(f.report.Imports, ImportChange{Filename: filename, Path: importPath, Removed: true})
+	}
+}
+
+// packageRefs counts the qualified identifiers like pkg.Name in file by the
+// name of their unresolved qualifier, which usually is an imported package
+func packageRefs(file *ast.File) map[string]int {
+	refs := make(map[string]int)
+	ast.Inspect(file, func(node ast.Node) bool {
+		if selector, ok := node.(*ast.SelectorExpr); ok {
+			if ident, ok := selector.X.(*ast.Ident); ok && ident.Obj == nil {
+				refs[ident.Name]++
+			}
+		}
+		return true
+	})
+	return refs
+}
+
+// insertedImports are the import paths of the packages an action may
+// insert references to, by their name
+var insertedImports = map[string]string{"os": "os"}
+
+// addMissingImports adds the imports of the insertedImports which are
+// referenced by file but were not referenced according to before and are
+// not imported. The import is added to the group of the first import of
+// file. It returns the modified import declarations and the added import
+// paths.
+func addMissingImports(file *ast.File, before map[string]int) ([]*ast.GenDecl, []string) {
+	after := packageRefs(file)
+	imported := make(map[string]bool)
+	for _, spec := range file.Imports {
+		imported[importName(spec)] = true
+	}
+	var modified []*ast.GenDecl
+	var paths []string
+	for name, importPath := range insertedImports {
+		if after[name] == 0 || before[name] > 0 || imported[name] {
+			continue
+		}
+		if decl := addImport(file, importPath); decl != nil {
+			modified = append(modified, decl)
+		}
+		paths = append(paths, importPath)
+	}
+	sort.Strings(paths)
+	return modified, paths
+}
+
+// addImport adds an import of importPath following the first import of
+// file, or a new import declaration if file has no imports. Only a modified
+// existing declaration is returned, as it keeps its extent in the source.
+func addImport(file *ast.File, importPath string) *ast.GenDecl {
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT || len(genDecl.Specs) == 0 {
+			continue
+		}
+		first := genDecl.Specs[0]
+		// the spec ends with the line of the first import, so that
+		// ast.SortImports does not take the comments of the following
+		// lines for comments of the imports
+		spec := &ast.ImportSpec{
+			Path:   &ast.BasicLit{ValuePos: first.End(), Kind: token.STRING, Value: strconv.Quote(importPath)},
+			EndPos: first.End(),
+		}
+		if !genDecl.Lparen.IsValid() {
+			// group the imports without changing the end of the
+			// declaration
+			genDecl.Lparen = first.Pos()
+			genDecl.Rparen = first.End() - 1
+		}
+		genDecl.Specs = append(genDecl.Specs[:1], append([]ast.Spec{spec}, genDecl.Specs[1:]...)...)
+		file.Imports = append(file.Imports, spec)
+		return genDecl
+	}
+	pos := file.Name.End()
+	spec := &ast.ImportSpec{Path: &ast.BasicLit{ValuePos: pos, Kind: token.STRING, Value: strconv.Quote(importPath)}}
+	file.Decls = append([]ast.Decl{&ast.GenDecl{TokPos: pos, Tok: token.IMPORT, Specs: []ast.Spec{spec}}}, file.Decls...)
+	file.Imports = append(file.Imports, spec)
+	return nil
+}
+
+// removeUnusedImports removes the imports of file whose name was referenced
+// according to before, but is not referenced anymore. Imports which were
+// unused already, blank and dot imports are kept. It returns the modified
+// import declarations, including those removed from file as a whole, and
+// the removed import paths.
+func removeUnusedImports(file *ast.File, before map[string]int) ([]*ast.GenDecl, []string) {
+	after := packageRefs(file)
+	unused := func(spec *ast.ImportSpec) bool {
+		name := importName(spec)
+		return name != "_" && name != "." && before[name] > 0 && after[name] == 0
+	}
+	var modified []*ast.GenDecl
+	var paths []string
+	decls := file.Decls[:0]
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			decls = append(decls, decl)
+			continue
+		}
+		specs := genDecl.Specs[:0]
+		for _, spec := range genDecl.Specs {
+			if importSpec := spec.(*ast.ImportSpec); unused(importSpec) {
+				removeComments(file, importSpec.Doc, importSpec.Comment)
+				if importPath, err := strconv.Unquote(importSpec.Path.Value); err == nil {
+					paths = append(paths, importPath)
+				}
+				continue
+			}
+			specs = append(specs, spec)
+		}
+		if len(specs) != len(genDecl.Specs) {
+			modified = append(modified, genDecl)
+		}
+		if len(specs) == 0 {
+			// keep the specs of the removed declaration, so that its
+			// position can still be determined
+			removeComments(file, genDecl.Doc)
+			continue
+		}
+		if len(specs) == 1 && len(specs) != len(genDecl.Specs) && !hasComments(file, genDecl) {
+			// drop the parentheses around the remaining import, like
+			// addImport added them. The printer only checks Lparen, the
+			// declaration keeps its extent in the source.
+			genDecl.Lparen = token.NoPos
+		}
+		genDecl.Specs = specs
+		decls = append(decls, decl)
+	}
+	file.Decls = decls
+	imports := file.Imports[:0]
+	for _, spec := range file.Imports {
+		if !unused(spec) {
+			imports = append(imports, spec)
+		}
+	}
+	file.Imports = imports
+	return modified, paths
+}
+
+// importName returns the name an import is referred to by, which is the
+// last element of its path without a major version suffix if not named
+// explicitly
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	importPath, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(importPath)
+	if strings.HasPrefix(name, "v") && strings.Trim(name[1:], "0123456789") == "" && path.Dir(importPath) != "." {
+		name = path.Base(path.Dir(importPath))
+	}
+	return name
+}
+
+// hasComments reports whether any comment of file lies within node
+func hasComments(file *ast.File, node ast.Node) bool {
+	for _, group := range file.Comments {
+		if group.Pos() >= node.Pos() && group.End() <= node.End() {
+			return true
+		}
+	}
+	return false
+}
+
+// removeComments removes the given comment groups from file
+func removeComments(file *ast.File, groups ...*ast.CommentGroup) {
+	remove := make(map[*ast.CommentGroup]bool)
+	for _, group := range groups {
+		if group != nil {
+			remove[group] = true
+		}
+	}
+	if len(remove) == 0 {
+		return
+	}
+	comments := file.Comments[:0]
+	for _, group := range file.Comments {
+		if !remove[group] {
+			comments = append(comments, group)
+		}
+	}
+	file.Comments = comments
+}
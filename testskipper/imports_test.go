@@ -0,0 +1,216 @@
+package testskipper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRemoveUnusedImports(t *testing.T) {
+	src := `package main
+
+import (
+	"testing"
+
+	"example.com/testutil"
+)
+
+// other helpers
+import "example.com/other/v2"
+
+func TestFoo(t *testing.T) {
+	testutil.Skip(t, "flaky")
+	other.Skip(t)
+	t.Log("foo")
+}
+
+func TestBar(t *testing.T) {
+	t.Log("bar")
+}
+`
+	unskipped := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+
+func TestBar(t *testing.T) {
+	t.Log("bar")
+}
+`
+	skipped := `package main
+
+import "testing"
+
+// gotestskipper:skip
+func TestFoo(t *testing.T) {
+	t.Skip()
+	t.Log("foo")
+}
+
+// gotestskipper:skip
+func TestBar(t *testing.T) {
+	t.Skip()
+	t.Log("bar")
+}
+`
+	calls := []SkipCall{{Qualifier: "testutil", Name: "Skip"}, {Qualifier: "other", Name: "Skip"}}
+	unskip := UnskipAllTestVisitorActionWithSkipCalls(calls...)
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+
+	for _, surgical := range []bool{false, true} {
+		// unskip, skip and unskip again
+		output := src
+		for _, step := range []struct {
+			visitAction FuncVisitAction
+			expected    string
+		}{
+			{unskip, unskipped},
+			{SkipTestVisitorAction, skipped},
+			{unskip, unskipped},
+		} {
+			visitor := NewTestFuncVisitor(step.visitAction)
+			visitor.SetSurgical(surgical)
+			var buffer bytes.Buffer
+
+			err := walkSource("foo_test.go", []byte(output), &buffer, visitor)
+
+			if err != nil {
+				t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+			}
+			output = buffer.String()
+			if replacer.Replace(step.expected) != replacer.Replace(output) {
+				t.Fatalf("Expected with surgical %t \n`%s`\n\n, got \n`%s`\n", surgical, step.expected, output)
+			}
+		}
+	}
+
+	// Imports still in use, or which cannot be matched to their name, are
+	// kept
+	src = `package main
+
+import (
+	"testing"
+
+	"example.com/go-helpers"
+	"example.com/testutil"
+)
+
+func TestFoo(t *testing.T) {
+	testutil.Skip(t, "flaky")
+	helpers.Skip(t)
+	t.Log("foo")
+}
+
+func TestBar(t *testing.T) {
+	testutil.Setup(t)
+}
+`
+	expected := `package main
+
+import (
+	"testing"
+
+	"example.com/go-helpers"
+	"example.com/testutil"
+)
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+
+func TestBar(t *testing.T) {
+	testutil.Setup(t)
+}
+`
+	visitor := NewTestFuncVisitor(UnskipAllTestVisitorActionWithSkipCalls(SkipCall{Qualifier: "testutil", Name: "Skip"}, SkipCall{Qualifier: "helpers", Name: "Skip"}))
+	var buffer bytes.Buffer
+
+	err := walkSource("foo_test.go", []byte(src), &buffer, visitor)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if replacer.Replace(expected) != replacer.Replace(buffer.String()) {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, buffer.String())
+	}
+}
+
+func TestAddMissingImports(t *testing.T) {
+	tests := []struct {
+		src       string
+		expected  string
+		unskipped string
+	}{
+		{
+			"package main\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {\n\tt.Log(\"foo\")\n}\n",
+			"package main\n\nimport (\n\t\"os\"\n\t\"testing\"\n)\n\n// gotestskipper:skip\nfunc TestFoo(t *testing.T) {\n\tif os.Getenv(\"SKIP_FLAKY\") != \"\" {\n\t\tt.Skip()\n\t}\n\n\tt.Log(\"foo\")\n}\n",
+			"package main\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {\n\tt.Log(\"foo\")\n}\n",
+		},
+		{
+			"package main\n\nimport (\n\t\"fmt\"\n\t\"testing\"\n\n\t\"example.com/foo\"\n)\n\nfunc TestFoo(t *testing.T) {\n\tfmt.Println(foo.Bar)\n}\n",
+			"package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\t\"testing\"\n\n\t\"example.com/foo\"\n)\n\n// gotestskipper:skip\nfunc TestFoo(t *testing.T) {\n\tif os.Getenv(\"SKIP_FLAKY\") != \"\" {\n\t\tt.Skip()\n\t}\n\n\tfmt.Println(foo.Bar)\n}\n",
+			"package main\n\nimport (\n\t\"fmt\"\n\t\"testing\"\n\n\t\"example.com/foo\"\n)\n\nfunc TestFoo(t *testing.T) {\n\tfmt.Println(foo.Bar)\n}\n",
+		},
+		{
+			"package main\n\nimport (\n\t\"os\"\n\t\"testing\"\n)\n\nfunc TestFoo(t *testing.T) {\n\tos.Exit(0)\n}\n",
+			"package main\n\nimport (\n\t\"os\"\n\t\"testing\"\n)\n\n// gotestskipper:skip\nfunc TestFoo(t *testing.T) {\n\tif os.Getenv(\"SKIP_FLAKY\") != \"\" {\n\t\tt.Skip()\n\t}\n\n\tos.Exit(0)\n}\n",
+			"package main\n\nimport (\n\t\"os\"\n\t\"testing\"\n)\n\nfunc TestFoo(t *testing.T) {\n\tos.Exit(0)\n}\n",
+		},
+	}
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+
+	for _, test := range tests {
+		for _, surgical := range []bool{false, true} {
+			// skip and unskip again
+			output := test.src
+			for _, step := range []struct {
+				visitAction FuncVisitAction
+				expected    string
+			}{
+				{SkipIfEnvVisitorAction("SKIP_FLAKY"), test.expected},
+				// a single remaining import is ungrouped again
+				{UnskipIfEnvVisitorAction("SKIP_FLAKY"), test.unskipped},
+			} {
+				visitor := NewTestFuncVisitor(step.visitAction)
+				visitor.SetSurgical(surgical)
+				var buffer bytes.Buffer
+
+				err := walkSource("foo_test.go", []byte(output), &buffer, visitor)
+
+				if err != nil {
+					t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+				}
+				output = buffer.String()
+				if replacer.Replace(step.expected) != replacer.Replace(output) {
+					t.Fatalf("Expected with surgical %t \n`%s`\n\n, got \n`%s`\n", surgical, step.expected, output)
+				}
+			}
+		}
+	}
+}
+
+func TestImportName(t *testing.T) {
+	tests := map[string]string{
+		`"testing"`:                 "testing",
+		`"example.com/testutil"`:    "testutil",
+		`"example.com/testutil/v2"`: "testutil",
+		`tu "example.com/testutil"`: "tu",
+		`. "example.com/testutil"`:  ".",
+		`_ "example.com/testutil"`:  "_",
+		`"example.com/go-testutil"`: "go-testutil",
+	}
+	for spec, expected := range tests {
+		_, file, _ := parseFuncDecl(t, "package main\n\nimport "+spec+"\n\nfunc f() {}\n")
+
+		actual := importName(file.Imports[0])
+
+		if actual != expected {
+			t.Fatalf("Expected name '%s' for %s, got '%s'\n", expected, spec, actual)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package testskipper
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+)
+
+// stmtList is a statement list of a function body, enclosed by the
+// positions open and close, together with the statements it held before
+// the visitAction
+type stmtList struct {
+	open, close token.Pos
+	list        *[]ast.Stmt
+	before      []ast.Stmt
+}
+
+// stmtLists returns the statement lists of body and of the blocks and
+// clauses nested in it
+func stmtLists(body *ast.BlockStmt) []stmtList {
+	var lists []stmtList
+	add := func(open, close token.Pos, list *[]ast.Stmt) {
+		lists = append(lists, stmtList{open: open, close: close, list: list, before: append([]ast.Stmt(nil), *list...)})
+	}
+	ast.Inspect(body, func(node ast.Node) bool {
+		block, ok := node.(*ast.BlockStmt)
+		if !ok {
+			return true
+		}
+		add(block.Lbrace+1, block.Rbrace, &block.List)
+		for i, stmt := range block.List {
+			close := block.Rbrace
+			if i+1 < len(block.List) {
+				close = block.List[i+1].Pos()
+			}
+			switch clause := stmt.(type) {
+			case *ast.CaseClause:
+				add(clause.Colon+1, close, &clause.Body)
+			case *ast.CommClause:
+				add(clause.Colon+1, close, &clause.Body)
+			}
+		}
+		return true
+	})
+	return lists
+}
+
+// emptiedStmtLines returns the line starts to drop so that the statements
+// following the statements the visitAction removed from the statement
+// lists of a function body take the line of the first removed one, like
+// in
+//
+//	t.Parallel()
+//	t.Skip()
+//
+//	t.Log("foo")
+//
+// becoming
+//
+//	t.Parallel()
+//	t.Log("foo")
+//
+// The printer would keep the lines of the removed statements otherwise.
+// comments are the comments of the body before the comments of the
+// removed statements were removed from file, see removeStmtComments. Lines
+// are only dropped if the removed statements and the statement following
+// them start on their own lines and no statement was inserted.
+func emptiedStmtLines(fileSet *token.FileSet, file *ast.File, lists []stmtList, comments []*ast.CommentGroup) []int {
+	remaining := make(map[*ast.CommentGroup]bool)
+	for _, group := range file.Comments {
+		remaining[group] = true
+	}
+	var offsets []int
+	for _, list := range lists {
+		offsets = append(offsets, emptiedListLines(fileSet, list, comments, remaining)...)
+	}
+	return offsets
+}
+
+// emptiedListLines returns the line starts to drop for list, see
+// emptiedStmtLines. remaining are the comments left in the file.
+func emptiedListLines(fileSet *token.FileSet, list stmtList, comments []*ast.CommentGroup, remaining map[*ast.CommentGroup]bool) []int {
+	tokenFile := fileSet.File(list.open)
+	if tokenFile == nil {
+		return nil
+	}
+	before := list.before
+	kept := make(map[token.Pos]bool, len(*list.list))
+	for _, stmt := range *list.list {
+		kept[stmt.Pos()] = true
+	}
+	var keptBefore int
+	for _, stmt := range before {
+		if kept[stmt.Pos()] {
+			keptBefore++
+		}
+	}
+	if keptBefore == len(before) || keptBefore != len(*list.list) {
+		return nil
+	}
+	// inRange reports whether pos is a position of the source
+	inRange := func(pos token.Pos) bool {
+		return fileSet.File(pos) == tokenFile
+	}
+	line := func(pos token.Pos) int {
+		return tokenFile.Line(pos)
+	}
+	var offsets []int
+	prevEnd := list.open
+	for i := 0; i < len(before); {
+		if kept[before[i].Pos()] {
+			prevEnd = before[i].End()
+			i++
+			continue
+		}
+		j := i
+		for j < len(before) && !kept[before[j].Pos()] {
+			j++
+		}
+		next := list.close
+		if j < len(before) {
+			next = before[j].Pos()
+		}
+		// the removed content spans from start to end, the content
+		// remaining around it ends at last and starts at next
+		start, end, last := before[i].Pos(), before[j-1].End(), prevEnd
+		valid := inRange(start) && inRange(end) && inRange(prevEnd) && inRange(next)
+		for _, group := range comments {
+			if group.Pos() < prevEnd || group.End() > next {
+				continue
+			}
+			if !inRange(group.Pos()) || !inRange(group.End()) {
+				valid = false
+				break
+			}
+			switch {
+			case !remaining[group] && group.Pos() < start:
+				start = group.Pos()
+			case !remaining[group] && group.End() > end:
+				end = group.End()
+			case remaining[group] && group.End() <= before[i].Pos() && group.End() > last:
+				last = group.End()
+			case remaining[group] && group.Pos() >= before[j-1].End() && group.Pos() < next:
+				next = group.Pos()
+			}
+		}
+		if valid && line(last) < line(start) && line(end) < line(next) {
+			for l := line(start) + 1; l <= line(next); l++ {
+				offsets = append(offsets, tokenFile.Offset(tokenFile.LineStart(l)))
+			}
+		}
+		prevEnd = next
+		i = j
+	}
+	return offsets
+}
+
+// emptiedDocLines returns the line starts to drop so that decl directly
+// follows its doc comment again after the visitAction removed comments
+// from its end, like the SkipMarker, see removeSkipMarker. before is the
+// list of the doc comment before. Comments the visitAction appended, like
+// the note of SetUnskipNote, continue the doc comment.
+func emptiedDocLines(fileSet *token.FileSet, decl *ast.FuncDecl, before []*ast.Comment) []int {
+	if decl.Doc == nil {
+		return nil
+	}
+	kept := make(map[*ast.Comment]bool, len(decl.Doc.List))
+	for _, comment := range decl.Doc.List {
+		kept[comment] = true
+	}
+	var removed bool
+	for _, comment := range before {
+		removed = removed || !kept[comment]
+	}
+	if !removed {
+		return nil
+	}
+	tokenFile := fileSet.File(decl.Pos())
+	if tokenFile == nil || fileSet.File(decl.Doc.End()) != tokenFile {
+		return nil
+	}
+	var offsets []int
+	for l := tokenFile.Line(decl.Doc.End()) + 2; l <= tokenFile.Line(decl.Pos()); l++ {
+		offsets = append(offsets, tokenFile.Offset(tokenFile.LineStart(l)))
+	}
+	return offsets
+}
+
+// dropLines drops the lines starting at offsets from the source of file,
+// joining each with its preceding line, so that the printer does not print
+// the lines emptied by the visitAction as blank lines. The line numbers
+// of the source are only changed once all functions of file were visited,
+// as they are reported and matched by the filters. The files of the same
+// name mirroring the offsets of the source, like the ones of
+// placeDocComment and reorderStmts, drop the lines of the same offsets.
+func dropLines(fileSet *token.FileSet, file *ast.File, offsets []int) {
+	tokenFile := fileSet.File(file.Package)
+	if tokenFile == nil || len(offsets) == 0 {
+		return
+	}
+	fileSet.Iterate(func(mirror *token.File) bool {
+		if mirror.Name() != tokenFile.Name() || mirror.Base() < tokenFile.Base() {
+			return true
+		}
+		lines := append([]int(nil), mirror.Lines()...)
+		for _, offset := range offsets {
+			if offset >= mirror.Size() {
+				continue
+			}
+			// the line holding offset, which starts at offset in the
+			// source
+			i := sort.SearchInts(lines, offset+1) - 1
+			if i > 0 {
+				lines = append(lines[:i], lines[i+1:]...)
+			}
+		}
+		mirror.SetLines(lines)
+		return true
+	})
+}
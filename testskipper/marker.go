@@ -0,0 +1,180 @@
+package testskipper
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// addSkipMarker adds the SkipMarker line comment to the doc comment of
+// decl, unless it is marked already
+func addSkipMarker(decl *ast.FuncDecl) {
+	if !hasSkipMarker(decl) {
+		addDocComment(decl, "// "+SkipMarker)
+	}
+}
+
+// removeSkipMarker removes the SkipMarker line comments from the doc
+// comment of decl, together with the empty comment lines separating them
+// from the rest of the doc comment. A doc comment left empty is removed.
+func removeSkipMarker(decl *ast.FuncDecl) {
+	if decl.Doc == nil {
+		return
+	}
+	var list []*ast.Comment
+	for _, comment := range decl.Doc.List {
+		if !isSkipMarker(comment) {
+			list = append(list, comment)
+		}
+	}
+	if len(list) == len(decl.Doc.List) {
+		return
+	}
+	for len(list) > 0 && strings.TrimSpace(list[len(list)-1].Text) == "//" {
+		list = list[:len(list)-1]
+	}
+	if len(list) == 0 {
+		decl.Doc = nil
+		return
+	}
+	decl.Doc.List = list
+}
+
+// isSkipMarker reports whether comment is the SkipMarker line comment
+func isSkipMarker(comment *ast.Comment) bool {
+	return strings.TrimSpace(strings.TrimPrefix(comment.Text, "//")) == SkipMarker
+}
+
+// unmarkUnskipped removes the SkipMarker of decl if none of its leading
+// statements is a skip statement or a skip matched by isSkip anymore
+func unmarkUnskipped(decl *ast.FuncDecl, target testingTarget, isSkip func(ast.Stmt) bool) {
+	if !isLeadingSkipped(decl) && !hasLeadingSkip(decl, target, isSkip) {
+		removeSkipMarker(decl)
+	}
+}
+
+// isLeadingSkipped reports whether any of the leading statements of decl,
+// see hasLeadingSkip, is a skip statement, guarded or not
+func isLeadingSkipped(decl *ast.FuncDecl) bool {
+	target, err := testingTargetOf(nil, decl)
+	if err != nil {
+		return false
+	}
+	return hasLeadingSkip(decl, target, func(stmt ast.Stmt) bool {
+		return isMarkedSkip(stmt, target)
+	})
+}
+
+// isMarkedSkip reports whether stmt is a skip statement of target as marked
+// by the SkipMarker, i.e. a skip call guarded by testing.Short() or an
+// environment variable or not
+func isMarkedSkip(stmt ast.Stmt, target testingTarget) bool {
+	return isSkipStmt(stmt, target, nil) || isShortModeGuard(stmt, target, nil) || isEnvGuard(stmt, target, nil, "")
+}
+
+// leadingMethods are the calls ApplySkip and NormalizeSkipVisitorAction may
+// place a skip after
+var leadingMethods = map[string]bool{"Parallel": true, "Cleanup": true, "Add": true}
+
+// hasLeadingSkip reports whether a statement matched by isSkip is among the
+// leading statements of decl, which are only preceded by t.Parallel,
+// t.Cleanup and f.Add calls
+func hasLeadingSkip(decl *ast.FuncDecl, target testingTarget, isSkip func(ast.Stmt) bool) bool {
+	for _, stmt := range decl.Body.List {
+		if isSkip(stmt) {
+			return true
+		}
+		if !isMethodCallStmt(stmt, target, leadingMethods) {
+			return false
+		}
+	}
+	return false
+}
+
+// addDocComment appends the line comment text to the doc comment of decl,
+// or adds a doc comment holding it. The comment is positioned at the end of
+// the doc comment or of the line preceding decl, so that the printer places
+// it directly above the declaration. As the printer only prints the
+// comments of a file, a TestFuncVisitor registers a new doc comment with
+// the file, see syncDocComment.
+func addDocComment(decl *ast.FuncDecl, text string) {
+	if decl.Doc != nil {
+		decl.Doc.List = append(decl.Doc.List, &ast.Comment{Slash: decl.Doc.End(), Text: text})
+		return
+	}
+	decl.Doc = &ast.CommentGroup{List: []*ast.Comment{{Slash: decl.Pos() - 1, Text: text}}}
+}
+
+// syncDocComment updates file.Comments after the visitAction changed the
+// doc comment of decl, which was before. A removed doc comment is removed
+// from the file, a new one is inserted in source order and moved onto its
+// own line, see placeDocComment. fileSet may be nil, the new doc comment is
+// kept in place then.
+func syncDocComment(fileSet *token.FileSet, file *ast.File, decl *ast.FuncDecl, before *ast.CommentGroup) {
+	if decl.Doc != nil && len(decl.Doc.List) == 0 {
+		decl.Doc = nil
+	}
+	if decl.Doc == before {
+		return
+	}
+	removeComments(file, before)
+	if decl.Doc == nil {
+		return
+	}
+	for _, group := range file.Comments {
+		if group == decl.Doc {
+			return
+		}
+	}
+	offset := func(group *ast.CommentGroup) int {
+		return int(group.Pos())
+	}
+	if fileSet != nil {
+		placeDocComment(fileSet, decl)
+		offset = func(group *ast.CommentGroup) int {
+			return fileSet.Position(group.Pos()).Offset
+		}
+	}
+	i := sort.Search(len(file.Comments), func(i int) bool {
+		return offset(file.Comments[i]) > offset(decl.Doc)
+	})
+	file.Comments = append(file.Comments[:i], append([]*ast.CommentGroup{decl.Doc}, file.Comments[i:]...)...)
+}
+
+// placeDocComment moves the new doc comment of decl onto the line of decl
+// if decl directly follows the preceding line, like in
+//
+//	}
+//	func TestFoo(t *testing.T) {
+//
+// The end of the preceding line, where addDocComment places the comment,
+// would make it a trailing comment of that line. There is no position
+// between the line and decl, so the comment is placed into a file of the
+// same name mirroring the offsets of the source, in which the end of the
+// preceding line starts the line of decl. The printer then separates it
+// from the preceding line by a blank line, as gofmt does for doc comments.
+func placeDocComment(fileSet *token.FileSet, decl *ast.FuncDecl) {
+	tokenFile := fileSet.File(decl.Pos())
+	if tokenFile == nil || decl.Doc.Pos() != decl.Pos()-1 {
+		return
+	}
+	line := tokenFile.Line(decl.Pos())
+	if line < 2 || tokenFile.LineStart(line-1) == decl.Pos()-1 {
+		// the preceding line is blank
+		return
+	}
+	offset := tokenFile.Offset(decl.Pos())
+	lines := make([]int, line)
+	for i := range lines {
+		lines[i] = i
+	}
+	lines[line-1] = offset - 1
+	docFile := fileSet.AddFile(tokenFile.Name(), -1, offset)
+	if !docFile.SetLines(lines) {
+		return
+	}
+	for _, comment := range decl.Doc.List {
+		comment.Slash = docFile.Pos(offset - 1)
+	}
+}
@@ -0,0 +1,62 @@
+package testskipper
+
+import "go/ast"
+
+// Names of the counters a visitor increments on its Metrics
+const (
+	// MetricFilesProcessed counts the files walked and printed
+	MetricFilesProcessed = "files_processed"
+	// MetricTestsSkipped counts the tests which were skipped by the action
+	MetricTestsSkipped = "tests_skipped"
+	// MetricTestsUnskipped counts the tests which were unskipped by the
+	// action
+	MetricTestsUnskipped = "tests_unskipped"
+	// MetricErrors counts the files which could not be parsed or printed
+	MetricErrors = "errors"
+)
+
+// Metrics receives the increments of named counters, so that a host like a
+// long-running service can export them, e.g. as Prometheus counters
+type Metrics interface {
+	Inc(name string)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) Inc(string) {}
+
+// NopMetrics discards all increments. It is the default Metrics of a
+// visitor.
+var NopMetrics Metrics = nopMetrics{}
+
+// SetMetrics sets the Metrics the visitor and the walk functions increment
+// their counters on. A nil metrics restores NopMetrics.
+func (f *testFuncVisitor) SetMetrics(metrics Metrics) {
+	if metrics == nil {
+		metrics = NopMetrics
+	}
+	f.metrics = metrics
+}
+
+type metricsHolder interface {
+	currentMetrics() Metrics
+}
+
+func (f testFuncVisitor) currentMetrics() Metrics {
+	return f.metrics
+}
+
+// countFile increments the counter of processed files on the Metrics of
+// visitor, or the error counter if err is not nil. It returns err.
+func countFile(visitor ast.Visitor, err error) error {
+	holder, ok := visitor.(metricsHolder)
+	if !ok || holder.currentMetrics() == nil {
+		return err
+	}
+	if err != nil {
+		holder.currentMetrics().Inc(MetricErrors)
+	} else {
+		holder.currentMetrics().Inc(MetricFilesProcessed)
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package testskipper
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+// fakeMetrics counts the increments per name
+type fakeMetrics map[string]int
+
+func (m fakeMetrics) Inc(name string) {
+	m[name]++
+}
+
+func TestMetrics(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+
+func TestBar(t *testing.T) {
+	t.Skip()
+	t.Log("bar")
+}
+`
+	dir, err := ioutil.TempDir("", "testskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"foo_test.go", "bar_test.go"} {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(src), 0644); err != nil {
+			panic(err)
+		}
+	}
+
+	metrics := make(fakeMetrics)
+	visitor := NewTestFuncVisitor(SkipTestVisitorAction)
+	visitor.SetMetrics(metrics)
+
+	err = WalkDir(dir, make(PathWriter), visitor)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	expected := fakeMetrics{MetricFilesProcessed: 2, MetricTestsSkipped: 2}
+	if !reflect.DeepEqual(expected, metrics) {
+		t.Fatalf("Expected metrics %v, got %v\n", expected, metrics)
+	}
+
+	metrics = make(fakeMetrics)
+	visitor = NewTestFuncVisitor(UnskipTestVisitorAction)
+	visitor.SetMetrics(metrics)
+
+	err = walkSource("foo_test.go", []byte(src), &bytes.Buffer{}, visitor)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	err = walkSource("bar_test.go", []byte("package main\n\nfunc TestFoo(\n"), &bytes.Buffer{}, visitor)
+
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	expected = fakeMetrics{MetricFilesProcessed: 1, MetricTestsUnskipped: 1, MetricErrors: 1}
+	if !reflect.DeepEqual(expected, metrics) {
+		t.Fatalf("Expected metrics %v, got %v\n", expected, metrics)
+	}
+
+	// Without metrics nothing is counted
+	visitor = NewTestFuncVisitor(SkipTestVisitorAction)
+	visitor.SetMetrics(nil)
+
+	err = walkSource("foo_test.go", []byte(src), &bytes.Buffer{}, visitor)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+}
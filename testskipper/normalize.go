@@ -0,0 +1,118 @@
+package testskipper
+
+import "go/ast"
+
+// SkipPosition describes where a skip statement is placed within a test
+// function body
+type SkipPosition int
+
+const (
+	// SkipPositionTop places the skip as the first statement
+	SkipPositionTop SkipPosition = iota
+	// SkipPositionAfterParallel places the skip directly after a leading
+	//
+	//	t.Parallel()
+	//
+	// statement, or as the first statement if there is none
+	SkipPositionAfterParallel
+	// SkipPositionAfterCleanup places the skip directly after any leading
+	//
+	//	t.Cleanup(...)
+	//
+	// calls, or as the first statement if there are none
+	SkipPositionAfterCleanup
+)
+
+// NormalizeSkipVisitorAction returns a visitAction which moves the skip
+// statement of a test function marked by the SkipMarker to the given
+// position, without adding or removing any statements.
+//
+// The skip statement is a skip call, with or without reason, or a skip
+// guarded by testing.Short() or an environment variable, as added by
+// ApplySkip. It is only moved if it is among the leading statements, which
+// are only preceded by t.Parallel, t.Cleanup and f.Add calls. A
+// TestFuncVisitor moves the comments of the statements along with them.
+func NormalizeSkipVisitorAction(position SkipPosition) FuncVisitAction {
+	return func(f *ast.FuncDecl) {
+		if f.Body == nil || !hasSkipMarker(f) {
+			return
+		}
+		target, err := testingTargetOf(nil, f)
+		if err != nil {
+			return
+		}
+		skip := -1
+		for i, stmt := range f.Body.List {
+			if isMarkedSkip(stmt, target) {
+				skip = i
+				break
+			}
+			if !isMethodCallStmt(stmt, target, leadingMethods) {
+				return
+			}
+		}
+		if skip < 0 {
+			return
+		}
+		stmt := f.Body.List[skip]
+		rest := append(append([]ast.Stmt(nil), f.Body.List[:skip]...), f.Body.List[skip+1:]...)
+		index := 0
+		switch position {
+		case SkipPositionAfterParallel:
+			if len(rest) > 0 && isMethodCallStmt(rest[0], target, map[string]bool{"Parallel": true}) {
+				index = 1
+			}
+		case SkipPositionAfterCleanup:
+			for index < len(rest) && isCleanupCallStmt(rest[index], target) {
+				index++
+			}
+		}
+		f.Body.List = append(rest[:index], append([]ast.Stmt{stmt}, rest[index:]...)...)
+	}
+}
+
+// isCallStmt reports whether stmt is a call of the form
+//
+//	receiver.method()
+//
+// without any arguments
+func isCallStmt(stmt ast.Stmt, receiver, method string) bool {
+	exprStmt, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := exprStmt.X.(*ast.CallExpr)
+	if !ok || len(call.Args) != 0 {
+		return false
+	}
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || selector.Sel.Name != method {
+		return false
+	}
+	ident, ok := selector.X.(*ast.Ident)
+	return ok && ident.Name == receiver
+}
+
+// isCleanupCallStmt reports whether stmt is a call of the form
+//
+//	t.Cleanup(...)
+//
+// on target
+func isCleanupCallStmt(stmt ast.Stmt, target testingTarget) bool {
+	return isMethodCallStmt(stmt, target, map[string]bool{"Cleanup": true})
+}
+
+// isMethodCallStmt reports whether stmt is a call of any of the methods
+// with a true value in methods on target, with any arguments
+func isMethodCallStmt(stmt ast.Stmt, target testingTarget, methods map[string]bool) bool {
+	exprStmt, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := exprStmt.X.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	return ok && methods[selector.Sel.Name] && target.matches(selector.X)
+}
@@ -0,0 +1,285 @@
+package testskipper
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeSkipVisitorAction(t *testing.T) {
+	src := `
+	package main
+
+	import "testing"
+
+	// gotestskipper:skip
+	func TestFoo(t *testing.T) {
+		t.Skip()
+		t.Parallel()
+		t.Log("foo")
+	}
+
+	// gotestskipper:skip
+	func TestBar(t *testing.T) {
+		t.Parallel()
+		t.Skip("flaky")
+		t.Log("bar")
+	}
+
+	// gotestskipper:skip
+	func TestBaz(t *testing.T) {
+		t.Skip()
+		t.Log("baz")
+	}
+
+	// gotestskipper:skip
+	func TestQux(t *testing.T) {
+		if testing.Short() {
+			t.Skip()
+		}
+		t.Parallel()
+		t.Log("qux")
+	}
+
+	func TestQuux(t *testing.T) {
+		t.Parallel()
+		t.Skip()
+		t.Log("quux")
+	}`
+
+	tests := []struct {
+		position SkipPosition
+		expected string
+	}{
+		{
+			SkipPositionTop,
+			`
+			package main
+
+			import "testing"
+
+			// gotestskipper:skip
+			func TestFoo(t *testing.T) {
+				t.Skip()
+				t.Parallel()
+				t.Log("foo")
+			}
+
+			// gotestskipper:skip
+			func TestBar(t *testing.T) {
+				t.Skip("flaky")
+				t.Parallel()
+				t.Log("bar")
+			}
+
+			// gotestskipper:skip
+			func TestBaz(t *testing.T) {
+				t.Skip()
+				t.Log("baz")
+			}
+
+			// gotestskipper:skip
+			func TestQux(t *testing.T) {
+				if testing.Short() {
+					t.Skip()
+				}
+				t.Parallel()
+				t.Log("qux")
+			}
+
+			func TestQuux(t *testing.T) {
+				t.Parallel()
+				t.Skip()
+				t.Log("quux")
+			}`,
+		},
+		{
+			SkipPositionAfterParallel,
+			`
+			package main
+
+			import "testing"
+
+			// gotestskipper:skip
+			func TestFoo(t *testing.T) {
+				t.Parallel()
+				t.Skip()
+				t.Log("foo")
+			}
+
+			// gotestskipper:skip
+			func TestBar(t *testing.T) {
+				t.Parallel()
+				t.Skip("flaky")
+				t.Log("bar")
+			}
+
+			// gotestskipper:skip
+			func TestBaz(t *testing.T) {
+				t.Skip()
+				t.Log("baz")
+			}
+
+			// gotestskipper:skip
+			func TestQux(t *testing.T) {
+				t.Parallel()
+				if testing.Short() {
+					t.Skip()
+				}
+				t.Log("qux")
+			}
+
+			func TestQuux(t *testing.T) {
+				t.Parallel()
+				t.Skip()
+				t.Log("quux")
+			}`,
+		},
+	}
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+
+	for _, test := range tests {
+		fileSet := token.NewFileSet()
+		file, err := parser.ParseFile(fileSet, "", src, parser.ParseComments)
+		if err != nil {
+			panic(err)
+		}
+
+		// only the marked skips are moved
+		visitor := NewTestFuncVisitor(NormalizeSkipVisitorAction(test.position))
+		visitor.SetFileSet(fileSet)
+		ast.Walk(visitor, file)
+
+		var buffer bytes.Buffer
+		printer.Fprint(&buffer, fileSet, file)
+		assertOnlySkipChanged(t, src, buffer.String())
+
+		expected := replacer.Replace(test.expected)
+		actual := replacer.Replace(buffer.String())
+
+		if expected != actual {
+			t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+		}
+	}
+}
+
+func TestNormalizeSkipAfterCleanup(t *testing.T) {
+	src := `
+	package main
+
+	import "testing"
+
+	// gotestskipper:skip
+	func TestFoo(t *testing.T) {
+		t.Skip()
+		t.Cleanup(func() { t.Log("cleanup") })
+		t.Cleanup(cleanup)
+		t.Log("foo")
+	}
+
+	// gotestskipper:skip
+	func TestBar(t *testing.T) {
+		t.Cleanup(cleanup)
+		t.Skip()
+		t.Log("bar")
+	}
+
+	// gotestskipper:skip
+	func TestBaz(t *testing.T) {
+		t.Skip()
+		t.Log("baz")
+	}`
+
+	expected := `
+	package main
+
+	import "testing"
+
+	// gotestskipper:skip
+	func TestFoo(t *testing.T) {
+		t.Cleanup(func() { t.Log("cleanup") })
+		t.Cleanup(cleanup)
+		t.Skip()
+		t.Log("foo")
+	}
+
+	// gotestskipper:skip
+	func TestBar(t *testing.T) {
+		t.Cleanup(cleanup)
+		t.Skip()
+		t.Log("bar")
+	}
+
+	// gotestskipper:skip
+	func TestBaz(t *testing.T) {
+		t.Skip()
+		t.Log("baz")
+	}`
+
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+
+	visitor := NewTestFuncVisitor(NormalizeSkipVisitorAction(SkipPositionAfterCleanup))
+	visitor.SetFileSet(fileSet)
+	ast.Walk(visitor, file)
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+	assertOnlySkipChanged(t, src, buffer.String())
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	if replacer.Replace(expected) != replacer.Replace(buffer.String()) {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, buffer.String())
+	}
+}
+
+func TestNormalizeSkipVisitorActionComments(t *testing.T) {
+	tests := []struct {
+		position SkipPosition
+		body     string
+		expected string
+	}{
+		{
+			SkipPositionTop,
+			"\tt.Parallel() // run in parallel\n\t// skip it\n\tt.Skip()\n\n\t// log\n\tt.Log(\"foo\")\n",
+			"\t// skip it\n\tt.Skip()\n\tt.Parallel() // run in parallel\n\n\t// log\n\tt.Log(\"foo\")\n",
+		},
+		{
+			SkipPositionAfterParallel,
+			"\t// skip it\n\tt.Skip() // for now\n\t// run in parallel\n\tt.Parallel()\n\tt.Log(\"foo\")\n",
+			"\t// run in parallel\n\tt.Parallel()\n\t// skip it\n\tt.Skip() // for now\n\tt.Log(\"foo\")\n",
+		},
+		{
+			SkipPositionAfterCleanup,
+			"\tt.Skip(\"flaky\") // skip it\n\tt.Cleanup(func() {\n\t\t// clean up\n\t\tcleanup()\n\t}) // cleanup first\n\n\tt.Log(\"foo\")\n",
+			"\tt.Cleanup(func() {\n\t\t// clean up\n\t\tcleanup()\n\t}) // cleanup first\n\tt.Skip(\"flaky\") // skip it\n\n\tt.Log(\"foo\")\n",
+		},
+	}
+
+	for _, test := range tests {
+		src := "package main\n\nimport \"testing\"\n\n// gotestskipper:skip\nfunc TestFoo(t *testing.T) {\n" + test.body + "}\n"
+		expected := "package main\n\nimport \"testing\"\n\n// gotestskipper:skip\nfunc TestFoo(t *testing.T) {\n" + test.expected + "}\n"
+		for _, surgical := range []bool{false, true} {
+			visitor := NewTestFuncVisitor(NormalizeSkipVisitorAction(test.position))
+			visitor.SetSurgical(surgical)
+			var buffer bytes.Buffer
+
+			err := walkSource("foo_test.go", []byte(src), &buffer, visitor)
+
+			if err != nil {
+				t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+			}
+			if buffer.String() != expected {
+				t.Fatalf("Expected with surgical %t \n`%s`\n\n, got \n`%s`\n", surgical, expected, buffer.String())
+			}
+		}
+	}
+}
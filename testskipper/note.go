@@ -0,0 +1,36 @@
+package testskipper
+
+import (
+	"go/ast"
+	"strings"
+	"time"
+)
+
+const unskipNotePrefix = "// re-enabled "
+
+// SetUnskipNote makes the visitor add a
+//
+//	// re-enabled YYYY-MM-DD
+//
+// line comment with the given date above every test function it unskipped.
+// An existing note is updated instead of adding another one. A zero date
+// disables the note.
+func (f *testFuncVisitor) SetUnskipNote(date time.Time) {
+	f.unskipNote = date
+}
+
+// addUnskipNote adds a note with date to the doc comment of funcDecl, or
+// updates an existing one. A TestFuncVisitor registers a new doc comment
+// with the file, see syncDocComment.
+func addUnskipNote(funcDecl *ast.FuncDecl, date time.Time) {
+	text := unskipNotePrefix + date.Format("2006-01-02")
+	if funcDecl.Doc != nil {
+		for _, comment := range funcDecl.Doc.List {
+			if strings.HasPrefix(comment.Text, unskipNotePrefix) {
+				comment.Text = text
+				return
+			}
+		}
+	}
+	addDocComment(funcDecl, text)
+}
@@ -0,0 +1,140 @@
+package testskipper
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnskipNote(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+// TestFoo does foo
+func TestFoo(t *testing.T) {
+	t.Skip()
+	t.Log("foo")
+}
+
+func TestBar(t *testing.T) {
+	t.Skip()
+	t.Log("bar")
+}
+
+func TestBaz(t *testing.T) {
+	t.Log("baz")
+}
+`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "foo_test.go", src, parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+
+	unskip := func(date time.Time) string {
+		visitor := NewTestFuncVisitor(UnskipTestVisitorAction)
+		visitor.SetUnskipNote(date)
+		ast.Walk(visitor, file)
+		var buffer bytes.Buffer
+		printer.Fprint(&buffer, fileSet, file)
+		return buffer.String()
+	}
+
+	output := unskip(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	expected := `package main
+
+import "testing"
+
+// TestFoo does foo
+// re-enabled 2024-03-01
+func TestFoo(t *testing.T) {
+
+	t.Log("foo")
+}
+
+// re-enabled 2024-03-01
+func TestBar(t *testing.T) {
+
+	t.Log("bar")
+}
+
+func TestBaz(t *testing.T) {
+	t.Log("baz")
+}
+`
+	if output != expected {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, output)
+	}
+
+	// Skipping and unskipping again updates the existing notes, TestBaz gets
+	// its first one
+	ast.Walk(NewTestFuncVisitor(SkipTestVisitorAction), file)
+	output = unskip(time.Date(2024, 4, 2, 12, 0, 0, 0, time.UTC))
+
+	if count := strings.Count(output, "// re-enabled"); count != 3 {
+		t.Fatalf("Expected 3 notes, got %d in \n`%s`\n", count, output)
+	}
+	if count := strings.Count(output, "// re-enabled 2024-04-02"); count != 3 {
+		t.Fatalf("Expected all notes to be updated, got \n`%s`\n", output)
+	}
+}
+
+func TestUnskipNoteBackToBack(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+// gotestskipper:skip
+func TestBar(t *testing.T) {
+	t.Skip()
+	t.Log("bar")
+}
+func TestBaz(t *testing.T) {
+	t.Skip()
+	t.Log("baz")
+}
+`
+	expected := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+
+// re-enabled 2024-03-01
+func TestBar(t *testing.T) {
+	t.Log("bar")
+}
+
+// re-enabled 2024-03-01
+func TestBaz(t *testing.T) {
+	t.Log("baz")
+}
+`
+	for _, surgical := range []bool{false, true} {
+		visitor := NewTestFuncVisitor(UnskipTestVisitorAction)
+		visitor.SetUnskipNote(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+		visitor.SetSurgical(surgical)
+		var buffer bytes.Buffer
+
+		err := walkSource("foo_test.go", []byte(src), &buffer, visitor)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		if buffer.String() != expected {
+			t.Fatalf("Expected with surgical %t \n`%s`\n\n, got \n`%s`\n", surgical, expected, buffer.String())
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package testskipper
+
+import (
+	"go/ast"
+	"runtime"
+	"sync"
+)
+
+// concurrencyLimiter is implemented by visitors which limit the number of
+// workers WalkDir uses
+type concurrencyLimiter interface {
+	concurrency() int
+}
+
+// SetConcurrency sets the number of workers WalkDir parses and prints the
+// files of a directory with. A value of 0 or less restores the default of
+// runtime.GOMAXPROCS(0), 1 processes the files sequentially.
+func (f *testFuncVisitor) SetConcurrency(workers int) {
+	f.workers = workers
+}
+
+func (f testFuncVisitor) concurrency() int {
+	return f.workers
+}
+
+// concurrencyOf returns the number of workers to process the files of
+// visitor with
+func concurrencyOf(visitor ast.Visitor) int {
+	if limiter, ok := visitor.(concurrencyLimiter); ok && limiter.concurrency() > 0 {
+		return limiter.concurrency()
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// forEachParallel calls work for every index below n, on at most workers
+// goroutines at a time, and returns after all calls returned
+func forEachParallel(n, workers int, work func(i int)) {
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			work(i)
+		}
+		return
+	}
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				work(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+}
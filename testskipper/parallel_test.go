@@ -0,0 +1,91 @@
+package testskipper
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+// writeTestFiles writes n test files with a test function each into a new
+// temporary directory and returns its path
+func writeTestFiles(n int) string {
+	dir, err := ioutil.TempDir("", "testskipper")
+	if err != nil {
+		panic(err)
+	}
+	for i := 0; i < n; i++ {
+		src := fmt.Sprintf("package main\n\nimport \"testing\"\n\nfunc TestFoo%d(t *testing.T) {\n\tt.Log(%d)\n}\n", i, i)
+		if err := ioutil.WriteFile(path.Join(dir, fmt.Sprintf("foo%03d_test.go", i)), []byte(src), 0644); err != nil {
+			panic(err)
+		}
+	}
+	return dir
+}
+
+func TestWalkDirConcurrency(t *testing.T) {
+	dir := writeTestFiles(50)
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(path.Join(dir, "foo025_test.go"), []byte("package main\n\nfunc TestBar(\n"), 0644); err != nil {
+		panic(err)
+	}
+
+	walk := func(workers int) (map[string]string, []FuncReport, string) {
+		visitor := NewTestFuncVisitor(SkipTestVisitorAction)
+		visitor.SetConcurrency(workers)
+		report := &Report{}
+		visitor.SetReport(report)
+		pathWriter := make(PathWriter)
+
+		err := WalkDir(dir, pathWriter, visitor)
+
+		if err == nil {
+			t.Fatalf("Expected an error with %d workers, got nil\n", workers)
+		}
+		files := make(map[string]string)
+		for filePath, buffer := range pathWriter {
+			content, _ := ioutil.ReadAll(buffer)
+			files[filePath] = string(content)
+		}
+		return files, report.Funcs, err.Error()
+	}
+
+	expectedFiles, expectedFuncs, expectedErr := walk(1)
+	if len(expectedFiles) != 49 {
+		t.Fatalf("Expected 49 files to be written, got %d\n", len(expectedFiles))
+	}
+	for _, workers := range []int{0, 4, 100} {
+		for i := 0; i < 5; i++ {
+			files, funcs, err := walk(workers)
+
+			if !reflect.DeepEqual(expectedFiles, files) {
+				t.Fatalf("Expected the same files with %d workers as with one\n", workers)
+			}
+			if !reflect.DeepEqual(expectedFuncs, funcs) {
+				t.Fatalf("Expected the report \n%v\n with %d workers, got \n%v\n", expectedFuncs, workers, funcs)
+			}
+			if err != expectedErr {
+				t.Fatalf("Expected error '%s' with %d workers, got '%s'\n", expectedErr, workers, err)
+			}
+		}
+	}
+}
+
+func BenchmarkWalkDir(b *testing.B) {
+	dir := writeTestFiles(500)
+	defer os.RemoveAll(dir)
+
+	for _, workers := range []int{1, 0} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				visitor := NewTestFuncVisitor(SkipTestVisitorAction)
+				visitor.SetConcurrency(workers)
+				if err := WalkDir(dir, make(PathWriter), visitor); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
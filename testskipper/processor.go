@@ -0,0 +1,89 @@
+package testskipper
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+)
+
+// Processor applies a visitAction to files and directories. It is
+// configured once and can be reused for any number of paths, so that
+// expensive setup like compiling regular expressions for filters happens
+// only once, e.g. in a long-running service.
+type Processor struct {
+	visitAction FuncVisitAction
+	configure   func(TestFuncVisitor)
+}
+
+// Result is the outcome of processing a single path
+type Result struct {
+	// Files maps the path of every processed file to its rewritten source
+	Files map[string][]byte
+	// Report describes the test functions the visitAction was called on
+	Report *Report
+}
+
+// NewProcessor returns a Processor calling visitAction on the test
+// functions of the processed files. If configure is not nil, it is called
+// with the visitor created for every path, e.g. to add filters. It must not
+// call SetReport, as the Processor provides its own report.
+func NewProcessor(visitAction FuncVisitAction, configure func(TestFuncVisitor)) *Processor {
+	return &Processor{visitAction: visitAction, configure: configure}
+}
+
+// ProcessFile applies the visitAction to src, the source of the file at
+// path, without accessing the file system. The rewritten source is returned
+// in the Result under path. If a path filter of the visitor rejects path,
+// src is returned unchanged without being parsed.
+func (p *Processor) ProcessFile(path string, src []byte) (Result, error) {
+	visitor, report := p.newVisitor()
+	if acceptor, ok := visitor.(pathAcceptor); ok && !acceptor.acceptPath(path) {
+		return Result{Files: map[string][]byte{path: src}, Report: report}, nil
+	}
+	var buffer bytes.Buffer
+	if err := walkSource(path, src, &buffer, visitor); err != nil {
+		return Result{}, err
+	}
+	return Result{Files: map[string][]byte{path: buffer.Bytes()}, Report: report}, nil
+}
+
+// Process applies the visitAction to the file or directory at path. The
+// files on disk are left untouched, the rewritten sources are returned in
+// the Result.
+func (p *Processor) Process(path string) (Result, error) {
+	visitor, report := p.newVisitor()
+	pathWriter := make(PathWriter)
+	dir, err := os.Stat(path)
+	switch {
+	case err != nil:
+		return Result{}, err
+	case dir.IsDir():
+		err = WalkDir(path, pathWriter, visitor)
+	default:
+		err = WalkFile(path, pathWriter.ReadWriterForPath(path), visitor)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Files: make(map[string][]byte, len(pathWriter)), Report: report}
+	for filePath, buffer := range pathWriter {
+		content, err := ioutil.ReadAll(buffer)
+		if err != nil {
+			return Result{}, err
+		}
+		result.Files[filePath] = content
+	}
+	return result, nil
+}
+
+// newVisitor returns a configured visitor and the report it adds to
+func (p *Processor) newVisitor() (TestFuncVisitor, *Report) {
+	visitor := NewTestFuncVisitor(p.visitAction)
+	if p.configure != nil {
+		p.configure(visitor)
+	}
+	report := &Report{}
+	visitor.SetReport(report)
+	return visitor, report
+}
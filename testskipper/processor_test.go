@@ -0,0 +1,133 @@
+package testskipper
+
+import (
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestProcessor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := `package main
+
+import "testing"
+
+func TestIntegrationFoo(t *testing.T) {
+	t.Log("foo")
+}
+
+func TestBar(t *testing.T) {
+	t.Log("bar")
+}
+`
+	var paths []string
+	for _, name := range []string{"foo_test.go", "bar_test.go", "baz_test.go"} {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			panic(err)
+		}
+		paths = append(paths, path)
+	}
+
+	configured := 0
+	integration := regexp.MustCompile("^TestIntegration")
+	processor := NewProcessor(SkipTestVisitorAction, func(visitor TestFuncVisitor) {
+		configured++
+		visitor.AddFilter(func(fileSet *token.FileSet, funcDecl *ast.FuncDecl) bool {
+			return integration.MatchString(funcDecl.Name.Name)
+		})
+	})
+
+	for _, path := range paths {
+		result, err := processor.Process(path)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		output := string(result.Files[path])
+		if strings.Count(output, "t.Skip()") != 1 || !strings.Contains(output, "TestIntegrationFoo(t *testing.T) {\n\tt.Skip()") {
+			t.Fatalf("Expected only TestIntegrationFoo to be skipped, got \n`%s`\n", output)
+		}
+		if changed := result.Report.Changed(); len(changed) != 1 || changed[0].Name != "TestIntegrationFoo" {
+			t.Fatalf("Expected TestIntegrationFoo to be reported, got %+v\n", changed)
+		}
+		content, _ := ioutil.ReadFile(path)
+		if string(content) != src {
+			t.Fatalf("Expected file to be untouched, got \n`%s`\n", content)
+		}
+	}
+	if configured != len(paths) {
+		t.Fatalf("Expected a visitor to be configured per path, got %d\n", configured)
+	}
+
+	// Directories are processed as a whole
+	result, err := processor.Process(dir)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if len(result.Files) != len(paths) || len(result.Report.Changed()) != len(paths) {
+		t.Fatalf("Expected %d files to be processed, got %d\n", len(paths), len(result.Files))
+	}
+
+	_, err = processor.Process(filepath.Join(dir, "missing_test.go"))
+
+	if err == nil {
+		t.Fatalf("Expected an error for a missing file\n")
+	}
+}
+
+func TestProcessorProcessFile(t *testing.T) {
+	src := "package main\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {\n\tt.Log(\"foo\")\n}\n"
+	processor := NewProcessor(SkipTestVisitorAction, func(visitor TestFuncVisitor) {
+		visitor.SetSurgical(true)
+	})
+
+	result, err := processor.ProcessFile("missing/foo_test.go", []byte(src))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	expected := "package main\n\nimport \"testing\"\n\n// gotestskipper:skip\nfunc TestFoo(t *testing.T) {\n\tt.Skip()\n\n\tt.Log(\"foo\")\n}\n"
+	if actual := string(result.Files["missing/foo_test.go"]); actual != expected {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+	}
+	if changed := result.Report.Changed(); len(changed) != 1 || changed[0].StartPos.Line != 7 {
+		t.Fatalf("Expected the skip to be reported on line 7, got %+v\n", changed)
+	}
+}
+
+func TestProcessorProcessFileExcluded(t *testing.T) {
+	src := "package main\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {\n\tt.Log(\"foo\")\n}\n"
+	processor := NewProcessor(SkipTestVisitorAction, func(visitor TestFuncVisitor) {
+		visitor.AddPathFilter(ExcludePaths("*_generated_test.go"))
+	})
+
+	result, err := processor.ProcessFile("foo_generated_test.go", []byte("not go"))
+
+	if err != nil {
+		t.Fatalf("Expected an excluded file not to be parsed, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if actual := string(result.Files["foo_generated_test.go"]); actual != "not go" {
+		t.Fatalf("Expected the excluded source to be returned unchanged, got \n`%s`\n", actual)
+	}
+
+	result, err = processor.ProcessFile("foo_test.go", []byte(src))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if changed := result.Report.Changed(); len(changed) != 1 {
+		t.Fatalf("Expected files not matching the pattern to be processed, got %+v\n", changed)
+	}
+}
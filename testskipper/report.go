@@ -0,0 +1,299 @@
+package testskipper
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// FuncReport describes a test function the visitAction was called on
+type FuncReport struct {
+	// Name is the name of the test function
+	Name string
+	// Position is the position of the function declaration. It is only
+	// valid if the visitor was provided with a token.FileSet.
+	Position token.Position
+	// Package is the name of the package declaring the function, if the
+	// function was visited as part of a file
+	Package string
+	// Skipped tells whether the function was skipped before the
+	// visitAction was applied
+	Skipped bool
+	// SkippedAfter tells whether the function is skipped after the
+	// visitAction was applied
+	SkippedAfter bool
+	// Changed tells whether the visitAction modified the function
+	Changed bool
+	// StartPos and EndPos span the leading statements the visitAction
+	// inserted into or removed from the function body. For inserted
+	// statements they refer to the rewritten source, for removed
+	// statements to the original source. They are only valid if such a
+	// change happened and the visitor was provided with a token.FileSet.
+	StartPos token.Position
+	EndPos   token.Position
+
+	// inserted is the number of statements inserted at the beginning of
+	// the body whose positions still need to be resolved
+	inserted int
+}
+
+// ImportChange describes an import the visitor added to or removed from a
+// file after acting on its functions
+type ImportChange struct {
+	// Filename is the name of the file. It is only known if the visitor was
+	// provided with a token.FileSet.
+	Filename string
+	// Path is the import path
+	Path string
+	// Removed tells whether the import was removed rather than added
+	Removed bool
+}
+
+// Report collects information about the test functions a visitor acted on
+type Report struct {
+	Funcs []FuncReport
+	// Imports are the imports added to or removed from the visited files
+	Imports []ImportChange
+}
+
+// Changed returns the reports of all functions which were modified
+func (r *Report) Changed() []FuncReport {
+	var changed []FuncReport
+	for _, funcReport := range r.Funcs {
+		if funcReport.Changed {
+			changed = append(changed, funcReport)
+		}
+	}
+	return changed
+}
+
+// SkipDelta counts how the skips of test functions changed
+type SkipDelta struct {
+	// Added is the number of functions which were skipped
+	Added int
+	// Removed is the number of functions which were unskipped
+	Removed int
+	// Kept is the number of functions which were already skipped and stay
+	// skipped
+	Kept int
+}
+
+// Add returns the sum of d and other
+func (d SkipDelta) Add(other SkipDelta) SkipDelta {
+	return SkipDelta{
+		Added:   d.Added + other.Added,
+		Removed: d.Removed + other.Removed,
+		Kept:    d.Kept + other.Kept,
+	}
+}
+
+// String returns a summary like
+//
+//	net +5 skips, -2 skips (3 already skipped)
+func (d SkipDelta) String() string {
+	return fmt.Sprintf("net +%d skips, -%d skips (%d already skipped)", d.Added, d.Removed, d.Kept)
+}
+
+// Delta returns the number of skips added, removed and kept by the
+// visitAction across all functions in r
+func (r *Report) Delta() SkipDelta {
+	var delta SkipDelta
+	for _, funcReport := range r.Funcs {
+		switch {
+		case !funcReport.Skipped && funcReport.SkippedAfter:
+			delta.Added++
+		case funcReport.Skipped && !funcReport.SkippedAfter:
+			delta.Removed++
+		case funcReport.Skipped:
+			delta.Kept++
+		}
+	}
+	return delta
+}
+
+// ImportDelta counts the files imports were added to or removed from, by
+// import path
+type ImportDelta struct {
+	Added   map[string]int
+	Removed map[string]int
+}
+
+// Add returns the sum of d and other
+func (d ImportDelta) Add(other ImportDelta) ImportDelta {
+	sum := ImportDelta{Added: make(map[string]int), Removed: make(map[string]int)}
+	for _, delta := range []ImportDelta{d, other} {
+		for importPath, n := range delta.Added {
+			sum.Added[importPath] += n
+		}
+		for importPath, n := range delta.Removed {
+			sum.Removed[importPath] += n
+		}
+	}
+	return sum
+}
+
+// String returns a summary like
+//
+//	added import os to 3 files, removed import os from 1 file
+//
+// sorted by import path, or an empty string if no import changed
+func (d ImportDelta) String() string {
+	var changes []string
+	summarize := func(counts map[string]int, format string) {
+		var paths []string
+		for importPath := range counts {
+			paths = append(paths, importPath)
+		}
+		sort.Strings(paths)
+		for _, importPath := range paths {
+			files := "files"
+			if counts[importPath] == 1 {
+				files = "file"
+			}
+			changes = append(changes, fmt.Sprintf(format, importPath, counts[importPath], files))
+		}
+	}
+	summarize(d.Added, "added import %s to %d %s")
+	summarize(d.Removed, "removed import %s from %d %s")
+	return strings.Join(changes, ", ")
+}
+
+// ImportDelta returns the number of files each import was added to or
+// removed from across all files in r
+func (r *Report) ImportDelta() ImportDelta {
+	delta := ImportDelta{Added: make(map[string]int), Removed: make(map[string]int)}
+	for _, change := range r.Imports {
+		if change.Removed {
+			delta.Removed[change.Path]++
+		} else {
+			delta.Added[change.Path]++
+		}
+	}
+	return delta
+}
+
+// reportingVisitAction calls visitAction on funcDecl of package pkg and adds
+// the outcome to report. Calls of the given skip helpers count as skips.
+func reportingVisitAction(report *Report, fileSet *token.FileSet, visitAction FuncVisitAction, pkg string, funcDecl *ast.FuncDecl, calls []SkipCall) {
+	funcReport := FuncReport{
+		Name:    funcDecl.Name.Name,
+		Package: pkg,
+		Skipped: isSkipped(funcDecl, calls...),
+	}
+	if fileSet != nil {
+		funcReport.Position = fileSet.Position(funcDecl.Pos())
+	}
+	before := nodeString(funcDecl)
+	var stmtsBefore []ast.Stmt
+	if funcDecl.Body != nil {
+		stmtsBefore = append(stmtsBefore, funcDecl.Body.List...)
+	}
+	visitAction(funcDecl)
+	funcReport.Changed = nodeString(funcDecl) != before
+	funcReport.SkippedAfter = isSkipped(funcDecl, calls...)
+	if funcReport.Changed && funcDecl.Body != nil {
+		funcReport.inserted = insertedStmts(stmtsBefore, funcDecl.Body.List)
+		if removed := removedStmts(stmtsBefore, funcDecl.Body.List); len(removed) > 0 && fileSet != nil {
+			funcReport.StartPos = fileSet.Position(removed[0].Pos())
+			funcReport.EndPos = fileSet.Position(removed[len(removed)-1].End())
+		}
+	}
+	report.Funcs = append(report.Funcs, funcReport)
+}
+
+// reportHolder is implemented by visitors holding a Report
+type reportHolder interface {
+	currentReport() *Report
+}
+
+// resolveInserted sets the positions of the statements inserted into the
+// functions reported for the file at path, using the printed source of the
+// rewritten file
+func (r *Report) resolveInserted(path string, src []byte) {
+	var pending bool
+	for _, funcReport := range r.Funcs {
+		if funcReport.inserted > 0 && funcReport.Position.Filename == path {
+			pending = true
+		}
+	}
+	if !pending {
+		return
+	}
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, path, src, 0)
+	if err != nil {
+		return
+	}
+	funcDecls := make(map[string]*ast.FuncDecl)
+	for _, decl := range file.Decls {
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok && funcDecl.Recv == nil {
+			funcDecls[funcDecl.Name.Name] = funcDecl
+		}
+	}
+	for i, funcReport := range r.Funcs {
+		if funcReport.inserted == 0 || funcReport.Position.Filename != path {
+			continue
+		}
+		funcDecl, ok := funcDecls[funcReport.Name]
+		if !ok || funcDecl.Body == nil || len(funcDecl.Body.List) < funcReport.inserted {
+			continue
+		}
+		r.Funcs[i].StartPos = fileSet.Position(funcDecl.Body.List[0].Pos())
+		r.Funcs[i].EndPos = fileSet.Position(funcDecl.Body.List[funcReport.inserted-1].End())
+		r.Funcs[i].inserted = 0
+	}
+}
+
+// insertedStmts returns the number of leading statements of after which are
+// not contained in before, i.e. statements which were inserted by an action
+func insertedStmts(before, after []ast.Stmt) int {
+	existing := make(map[ast.Stmt]bool, len(before))
+	for _, stmt := range before {
+		existing[stmt] = true
+	}
+	for i, stmt := range after {
+		if existing[stmt] {
+			return i
+		}
+	}
+	return len(after)
+}
+
+// removedStmts returns the statements of before which are not contained in
+// after
+func removedStmts(before, after []ast.Stmt) []ast.Stmt {
+	remaining := make(map[ast.Stmt]bool)
+	for _, stmt := range after {
+		remaining[stmt] = true
+	}
+	var removed []ast.Stmt
+	for _, stmt := range before {
+		if !remaining[stmt] {
+			removed = append(removed, stmt)
+		}
+	}
+	return removed
+}
+
+// isSkipped reports whether the first statement of funcDecl is a skip
+// statement, a call of any of the skip helpers or such a statement guarded
+// by a condition, see ApplyUnskipAll
+func isSkipped(funcDecl *ast.FuncDecl, calls ...SkipCall) bool {
+	target, err := testingTargetOf(nil, funcDecl)
+	if err != nil || len(funcDecl.Body.List) == 0 {
+		return false
+	}
+	stmt := funcDecl.Body.List[0]
+	return isSkipStmt(stmt, target, calls) || isShortModeGuard(stmt, target, calls) || isEnvGuard(stmt, target, calls, "")
+}
+
+func nodeString(node ast.Node) string {
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, token.NewFileSet(), node)
+	return buffer.String()
+}
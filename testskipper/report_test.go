@@ -0,0 +1,243 @@
+package testskipper
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+
+func TestBar(t *testing.T) {
+	t.Skip()
+}
+
+func TestBaz(t *testing.T) {
+	t.Skip()
+	t.Log("baz")
+}
+`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "foo_test.go", src, parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+	report := &Report{}
+
+	visitor := NewTestFuncVisitor(UnskipTestVisitorAction)
+	visitor.SetFileSet(fileSet)
+	visitor.SetReport(report)
+	ast.Walk(visitor, file)
+
+	expected := []FuncReport{
+		{Name: "TestFoo", Position: token.Position{Filename: "foo_test.go", Line: 5, Column: 1}, Package: "main"},
+		{Name: "TestBar", Position: token.Position{Filename: "foo_test.go", Line: 9, Column: 1}, Package: "main", Skipped: true, Changed: true},
+		{Name: "TestBaz", Position: token.Position{Filename: "foo_test.go", Line: 13, Column: 1}, Package: "main", Skipped: true, Changed: true},
+	}
+
+	if len(report.Funcs) != len(expected) {
+		t.Fatalf("Expected %d reported funcs, got %d\n", len(expected), len(report.Funcs))
+	}
+	for i, funcReport := range report.Funcs {
+		funcReport.Position.Offset = 0
+		funcReport.StartPos, funcReport.EndPos = token.Position{}, token.Position{}
+		if funcReport != expected[i] {
+			t.Fatalf("Expected %+v, got %+v\n", expected[i], funcReport)
+		}
+	}
+
+	changed := report.Changed()
+	if len(changed) != 2 || changed[0].Name != "TestBar" || changed[1].Name != "TestBaz" {
+		t.Fatalf("Expected TestBar and TestBaz to be changed, got %+v\n", changed)
+	}
+}
+
+func TestReportDelta(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+
+func TestBar(t *testing.T) {
+	t.Skip()
+	t.Log("bar")
+}
+
+func TestBaz(t *testing.T) {
+	t.Skip()
+	t.Log("baz")
+}
+
+func TestQux(t *testing.T) {
+	t.Log("qux")
+}
+`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "foo_test.go", src, parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+	report := &Report{}
+
+	visitor := NewTestFuncVisitor(RulesVisitAction([]Rule{
+		{Matcher: NameMatcher("TestFoo"), Action: SkipTestVisitorAction},
+		{Matcher: NameMatcher("TestQux"), Action: SkipTestVisitorAction},
+		{Matcher: NameMatcher("TestBar"), Action: UnskipTestVisitorAction},
+	}))
+	visitor.SetReport(report)
+	ast.Walk(visitor, file)
+
+	expected := SkipDelta{Added: 2, Removed: 1, Kept: 1}
+	if delta := report.Delta(); delta != expected {
+		t.Fatalf("Expected %+v, got %+v\n", expected, delta)
+	}
+	if summary := expected.Add(expected).String(); summary != "net +4 skips, -2 skips (2 already skipped)" {
+		t.Fatalf("Expected summary 'net +4 skips, -2 skips (2 already skipped)', got '%s'\n", summary)
+	}
+}
+
+func TestReportImports(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+`
+	run := func(visitAction FuncVisitAction, sources map[string]string) *Report {
+		report := &Report{}
+		visitor := NewTestFuncVisitor(visitAction)
+		visitor.SetReport(report)
+		for _, name := range []string{"bar_test.go", "baz_test.go", "foo_test.go"} {
+			if _, ok := sources[name]; !ok {
+				continue
+			}
+			var buffer bytes.Buffer
+			if err := walkSource(name, []byte(sources[name]), &buffer, visitor); err != nil {
+				t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+			}
+			sources[name] = buffer.String()
+		}
+		return report
+	}
+	sources := map[string]string{"foo_test.go": src, "bar_test.go": src, "baz_test.go": src}
+
+	// testing is imported already
+	report := run(SkipInShortModeVisitorAction(""), sources)
+
+	if len(report.Imports) != 0 {
+		t.Fatalf("Expected no import changes, got %+v\n", report.Imports)
+	}
+	if summary := report.ImportDelta().String(); summary != "" {
+		t.Fatalf("Expected no summary, got '%s'\n", summary)
+	}
+
+	sources = map[string]string{"foo_test.go": src, "bar_test.go": src, "baz_test.go": src}
+	report = run(SkipTestVisitorActionWithOptions(SkipOptions{EnvVar: "SKIP_FLAKY"}), sources)
+
+	expected := []ImportChange{
+		{Filename: "bar_test.go", Path: "os"},
+		{Filename: "baz_test.go", Path: "os"},
+		{Filename: "foo_test.go", Path: "os"},
+	}
+	if !reflect.DeepEqual(expected, report.Imports) {
+		t.Fatalf("Expected import changes %+v, got %+v\n", expected, report.Imports)
+	}
+	if summary := report.ImportDelta().String(); summary != "added import os to 3 files" {
+		t.Fatalf("Expected summary 'added import os to 3 files', got '%s'\n", summary)
+	}
+
+	// unskipping removes the import again
+	delete(sources, "baz_test.go")
+	delta := run(UnskipIfEnvVisitorAction("SKIP_FLAKY"), sources).ImportDelta().Add(report.ImportDelta())
+
+	if summary := delta.String(); summary != "added import os to 3 files, removed import os from 2 files" {
+		t.Fatalf("Expected summary 'added import os to 3 files, removed import os from 2 files', got '%s'\n", summary)
+	}
+}
+
+func TestReportPositions(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+
+func TestBar(t *testing.T) {
+	t.Log("bar")
+}
+`
+	tmpFilePath := "tempFile_test.go"
+	err := ioutil.WriteFile(tmpFilePath, []byte(src), 0644)
+	if err != nil {
+		panic(err)
+	}
+	defer os.Remove(tmpFilePath)
+
+	// Inserted statements refer to the rewritten source
+	report := &Report{}
+	visitor := NewTestFuncVisitor(SkipTestVisitorAction)
+	visitor.SetReport(report)
+	var buffer bytes.Buffer
+
+	err = WalkFile(tmpFilePath, &buffer, visitor)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	lines := strings.Split(buffer.String(), "\n")
+	for _, funcReport := range report.Funcs {
+		if funcReport.StartPos.Line != funcReport.EndPos.Line {
+			t.Fatalf("Expected span on a single line, got %s - %s\n", funcReport.StartPos, funcReport.EndPos)
+		}
+		line := lines[funcReport.StartPos.Line-1]
+		if span := line[funcReport.StartPos.Column-1 : funcReport.EndPos.Column-1]; span != "t.Skip()" {
+			t.Fatalf("Expected span to cover 't.Skip()', got '%s'\n", span)
+		}
+	}
+	// both functions are preceded by the skip marker
+	if report.Funcs[1].StartPos.Line != 14 {
+		t.Fatalf("Expected skip of TestBar on line 14, got %d\n", report.Funcs[1].StartPos.Line)
+	}
+
+	// Removed statements refer to the original source
+	err = ioutil.WriteFile(tmpFilePath, buffer.Bytes(), 0644)
+	if err != nil {
+		panic(err)
+	}
+	report = &Report{}
+	visitor = NewTestFuncVisitor(UnskipTestVisitorAction)
+	visitor.SetReport(report)
+	buffer.Reset()
+
+	err = WalkFile(tmpFilePath, &buffer, visitor)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	expectedLines := []int{7, 14}
+	for i, funcReport := range report.Funcs {
+		if funcReport.StartPos.Line != expectedLines[i] || funcReport.StartPos.Column != 2 || funcReport.EndPos.Column != 10 {
+			t.Fatalf("Expected span %d:2-%d:10, got %s - %s\n", expectedLines[i], expectedLines[i], funcReport.StartPos, funcReport.EndPos)
+		}
+	}
+}
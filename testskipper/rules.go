@@ -0,0 +1,53 @@
+package testskipper
+
+import (
+	"go/ast"
+	"regexp"
+	"strings"
+)
+
+// FuncMatcher decides whether a rule applies to a test function
+type FuncMatcher func(*ast.FuncDecl) bool
+
+// Rule applies its Action to the test functions accepted by its Matcher
+type Rule struct {
+	Matcher FuncMatcher
+	Action  FuncVisitAction
+}
+
+// RulesVisitAction returns a visitAction which applies the action of the
+// first rule matching the test function. Test functions matched by no rule
+// are left untouched.
+func RulesVisitAction(rules []Rule) FuncVisitAction {
+	return func(f *ast.FuncDecl) {
+		for _, rule := range rules {
+			if rule.Matcher(f) {
+				rule.Action(f)
+				return
+			}
+		}
+	}
+}
+
+// NameMatcher returns a FuncMatcher matching test functions named name
+func NameMatcher(name string) FuncMatcher {
+	return func(f *ast.FuncDecl) bool {
+		return f.Name.Name == name
+	}
+}
+
+// RegexpMatcher returns a FuncMatcher matching test functions whose name
+// matches re
+func RegexpMatcher(re *regexp.Regexp) FuncMatcher {
+	return func(f *ast.FuncDecl) bool {
+		return re.MatchString(f.Name.Name)
+	}
+}
+
+// CommentMatcher returns a FuncMatcher matching test functions whose doc
+// comment contains text
+func CommentMatcher(text string) FuncMatcher {
+	return func(f *ast.FuncDecl) bool {
+		return f.Doc != nil && strings.Contains(f.Doc.Text(), text)
+	}
+}
@@ -0,0 +1,93 @@
+package testskipper
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRulesVisitAction(t *testing.T) {
+	src := `
+	package main
+
+	import "testing"
+
+	func TestIntegrationFoo(t *testing.T) {
+		t.Log("foo")
+	}
+
+	// TestBar is slow
+	func TestBar(t *testing.T) {
+		t.Log("bar")
+	}
+
+	func TestSlowIntegration(t *testing.T) {
+		t.Log("baz")
+	}
+
+	func TestQux(t *testing.T) {
+		t.Log("qux")
+	}`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+
+	rules := []Rule{
+		{RegexpMatcher(regexp.MustCompile("Integration")), SkipTestVisitorActionWithReason("integration")},
+		{CommentMatcher("slow"), SkipInShortModeVisitorAction("")},
+		{NameMatcher("TestSlowIntegration"), SkipTestVisitorAction},
+	}
+
+	ast.Walk(NewTestFuncVisitor(RulesVisitAction(rules)), file)
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+
+	expected := `
+	package main
+
+	import "testing"
+
+	// gotestskipper:skip
+	func TestIntegrationFoo(t *testing.T) {
+		t.Skip("integration")
+
+		t.Log("foo")
+	}
+
+	// TestBar is slow
+	// gotestskipper:skip
+	func TestBar(t *testing.T) {
+		if testing.Short() {
+			t.Skip()
+		}
+
+		t.Log("bar")
+	}
+
+	// gotestskipper:skip
+	func TestSlowIntegration(t *testing.T) {
+		t.Skip("integration")
+
+		t.Log("baz")
+	}
+
+	func TestQux(t *testing.T) {
+		t.Log("qux")
+	}`
+	expected = replacer.Replace(expected)
+	actual := replacer.Replace(buffer.String())
+
+	if expected != actual {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+	}
+}
@@ -0,0 +1,91 @@
+package testskipper
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+)
+
+// SkipCall describes a helper function which skips a test when called with
+// the testing parameter as first argument, like
+//
+//	testutil.Skip(t, "reason")
+//
+// Such calls are recognized as skips in addition to the skip methods of
+// testing.T.
+type SkipCall struct {
+	// Qualifier is the package name of the helper, or "" for a helper of
+	// the same package
+	Qualifier string
+	// Name is the name of the helper function
+	Name string
+}
+
+func (s SkipCall) String() string {
+	if s.Qualifier == "" {
+		return s.Name
+	}
+	return s.Qualifier + "." + s.Name
+}
+
+// ParseSkipCall parses a SkipCall from a pattern like testutil.Skip or
+// skipTest
+func ParseSkipCall(pattern string) (SkipCall, error) {
+	expr, err := parser.ParseExpr(pattern)
+	if err != nil {
+		return SkipCall{}, fmt.Errorf("invalid skip call %q", pattern)
+	}
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return SkipCall{Name: expr.Name}, nil
+	case *ast.SelectorExpr:
+		if qualifier, ok := expr.X.(*ast.Ident); ok {
+			return SkipCall{Qualifier: qualifier.Name, Name: expr.Sel.Name}, nil
+		}
+	}
+	return SkipCall{}, fmt.Errorf("invalid skip call %q", pattern)
+}
+
+// matches reports whether stmt is a call of s with target as first
+// argument
+func (s SkipCall) matches(stmt ast.Stmt, target testingTarget) bool {
+	exprStmt, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := exprStmt.X.(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 || !target.matches(call.Args[0]) {
+		return false
+	}
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return s.Qualifier == "" && fun.Name == s.Name
+	case *ast.SelectorExpr:
+		qualifier, ok := fun.X.(*ast.Ident)
+		return ok && qualifier.Name == s.Qualifier && fun.Sel.Name == s.Name
+	}
+	return false
+}
+
+// isSkipStmt reports whether stmt is a call of any of the skip methods on
+// target or of any of the skip helpers in calls
+func isSkipStmt(stmt ast.Stmt, target testingTarget, calls []SkipCall) bool {
+	return isSkipCallStmt(stmt, target) || isSkipHelperCall(stmt, target, calls)
+}
+
+// isSkipHelperCall reports whether stmt is a call of any of the skip
+// helpers in calls with target as first argument
+func isSkipHelperCall(stmt ast.Stmt, target testingTarget, calls []SkipCall) bool {
+	for _, call := range calls {
+		if call.matches(stmt, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddSkipCall makes the visitor recognize calls of the given helper as
+// skips when reporting whether a test function is skipped
+func (f *testFuncVisitor) AddSkipCall(call SkipCall) {
+	f.skipCalls = append(f.skipCalls, call)
+}
@@ -0,0 +1,105 @@
+package testskipper
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestParseSkipCall(t *testing.T) {
+	tests := map[string]SkipCall{
+		"testutil.Skip": {Qualifier: "testutil", Name: "Skip"},
+		"skipTest":      {Name: "skipTest"},
+	}
+	for pattern, expected := range tests {
+		actual, err := ParseSkipCall(pattern)
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		if actual != expected || actual.String() != pattern {
+			t.Fatalf("Expected %+v for %q, got %+v\n", expected, pattern, actual)
+		}
+	}
+
+	for _, pattern := range []string{"", "a.b.Skip", "Skip()", "testutil."} {
+		if _, err := ParseSkipCall(pattern); err == nil {
+			t.Fatalf("Expected an error for %q\n", pattern)
+		}
+	}
+}
+
+func TestSkipCalls(t *testing.T) {
+	src := `package main
+
+import (
+	"testing"
+
+	"example.com/testutil"
+)
+
+func TestFoo(t *testing.T) {
+	testutil.Skip(t, "flaky")
+	t.Log("foo")
+}
+
+func TestBar(t *testing.T) {
+	if testing.Short() {
+		testutil.Skip(t, "slow")
+	}
+	t.Log("bar")
+}
+
+func TestBaz(t *testing.T) {
+	testutil.Skip(nil, "other")
+	other.Skip(t, "other")
+	t.Log("baz")
+}
+`
+	skipCall := SkipCall{Qualifier: "testutil", Name: "Skip"}
+	tests := []struct {
+		visitAction FuncVisitAction
+		expected    []string
+	}{
+		{UnskipTestVisitorActionWithSkipCalls(skipCall), []string{"TestFoo"}},
+		{UnskipAllTestVisitorActionWithSkipCalls(skipCall), []string{"TestFoo", "TestBar"}},
+	}
+
+	for _, test := range tests {
+		fileSet := token.NewFileSet()
+		file, err := parser.ParseFile(fileSet, "foo_test.go", src, parser.ParseComments)
+		if err != nil {
+			panic(err)
+		}
+		report := &Report{}
+		visitor := NewTestFuncVisitor(test.visitAction)
+		visitor.AddSkipCall(skipCall)
+		visitor.SetReport(report)
+		ast.Walk(visitor, file)
+
+		var skipped, changed []string
+		for _, funcReport := range report.Funcs {
+			if funcReport.Skipped {
+				skipped = append(skipped, funcReport.Name)
+			}
+			if funcReport.Changed {
+				changed = append(changed, funcReport.Name)
+			}
+		}
+		if strings.Join(skipped, ",") != "TestFoo,TestBar" {
+			t.Fatalf("Expected TestFoo and TestBar to be reported as skipped, got %v\n", skipped)
+		}
+		if strings.Join(changed, ",") != strings.Join(test.expected, ",") {
+			t.Fatalf("Expected %v to be unskipped, got %v\n", test.expected, changed)
+		}
+
+		var buffer bytes.Buffer
+		printer.Fprint(&buffer, fileSet, file)
+		if count := strings.Count(buffer.String(), "testutil.Skip(t"); count != 2-len(test.expected) {
+			t.Fatalf("Expected %d remaining skips, got \n`%s`\n", 2-len(test.expected), buffer.String())
+		}
+	}
+}
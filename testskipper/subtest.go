@@ -0,0 +1,99 @@
+package testskipper
+
+import (
+	"go/ast"
+	"go/token"
+	"regexp"
+	"strconv"
+)
+
+// ApplySkipSubtests inserts the skip statement described by opts into the
+// function literal of every subtest of decl whose name matches re, i.e.
+// calls like
+//
+//	t.Run("name", func(t *testing.T) {
+//		t.Skip()
+//		...
+//	})
+//
+// Subtests are matched by their name only if it is a string literal, so
+// that subtests of table-driven tests named by a variable are left
+// untouched. The skip is called on the parameter of the function literal,
+// which may be named differently than the one of decl. Subtests nested in
+// other subtests are matched as well.
+//
+// fileSet is only used to add position information to errors and may be
+// nil.
+func ApplySkipSubtests(fileSet *token.FileSet, decl *ast.FuncDecl, re *regexp.Regexp, opts SkipOptions) error {
+	if decl.Body == nil {
+		return funcError(fileSet, decl, "has no body")
+	}
+	var err error
+	ast.Inspect(decl.Body, func(node ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		name, lit, ok := subtestOf(call)
+		if !ok || !re.MatchString(name) {
+			return true
+		}
+		// ApplySkip only modifies the signature and the body, which are
+		// shared with the function literal
+		err = ApplySkip(fileSet, &ast.FuncDecl{Name: decl.Name, Type: lit.Type, Body: lit.Body}, opts)
+		return true
+	})
+	return err
+}
+
+// subtestOf returns the name and the function literal of call if it is a
+// call like
+//
+//	t.Run("name", func(t *testing.T) {})
+func subtestOf(call *ast.CallExpr) (string, *ast.FuncLit, bool) {
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || selector.Sel.Name != "Run" || len(call.Args) != 2 {
+		return "", nil, false
+	}
+	nameLit, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || nameLit.Kind != token.STRING {
+		return "", nil, false
+	}
+	name, err := strconv.Unquote(nameLit.Value)
+	if err != nil {
+		return "", nil, false
+	}
+	lit, ok := call.Args[1].(*ast.FuncLit)
+	if !ok || len(lit.Type.Params.List) != 1 {
+		return "", nil, false
+	}
+	star, ok := lit.Type.Params.List[0].Type.(*ast.StarExpr)
+	if !ok {
+		return "", nil, false
+	}
+	var typeName string
+	switch paramType := star.X.(type) {
+	case *ast.Ident:
+		typeName = paramType.Name
+	case *ast.SelectorExpr:
+		typeName = paramType.Sel.Name
+	}
+	return name, lit, typeName == "T"
+}
+
+// SkipSubtestsVisitorAction returns a visitAction which adds a
+//
+//	t.Skip()
+//
+// statement to the subtests of the test function whose name matches re,
+// see ApplySkipSubtests
+func SkipSubtestsVisitorAction(re *regexp.Regexp, opts SkipOptions) FuncVisitAction {
+	return func(f *ast.FuncDecl) {
+		if err := ApplySkipSubtests(nil, f, re, opts); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package testskipper
+
+import (
+	"bytes"
+	"go/printer"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestApplySkipSubtests(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+	t.Run("network/dial", func(st *testing.T) {
+		st.Log("dial")
+		st.Run("network/listen", func(*testing.T) {})
+	})
+	t.Run("disk", func(t *testing.T) {
+		t.Log("disk")
+	})
+	for _, name := range []string{"network/udp"} {
+		t.Run(name, func(t *testing.T) {})
+	}
+}
+`
+	fileSet, file, funcDecl := parseFuncDecl(t, src)
+
+	err := ApplySkipSubtests(fileSet, funcDecl, regexp.MustCompile("^network/"), SkipOptions{})
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+	assertOnlySkipChanged(t, strings.Replace(src, "func(*testing.T)", "func(t *testing.T)", 1), buffer.String())
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	expected := replacer.Replace(`package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+	t.Run("network/dial", func(st *testing.T) {
+		st.Skip()
+
+		st.Log("dial")
+		st.Run("network/listen", func(t *testing.T) {
+			t.Skip()
+		})
+	})
+	t.Run("disk", func(t *testing.T) {
+		t.Log("disk")
+	})
+	for _, name := range []string{"network/udp"} {
+		t.Run(name, func(t *testing.T) {})
+	}
+}
+`)
+	actual := replacer.Replace(buffer.String())
+
+	if expected != actual {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+	}
+}
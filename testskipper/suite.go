@@ -0,0 +1,180 @@
+package testskipper
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+// testingTarget is the value the skip methods are called on. For test
+// functions this is the testing parameter t, for methods of a test suite it
+// is s.T() with s being the receiver.
+type testingTarget struct {
+	// name is the name of the testing parameter or of the suite receiver
+	name string
+	// suite tells whether the target belongs to a suite method
+	suite bool
+	// qualifier is the name of the testing package, or "" for a dot import
+	qualifier string
+}
+
+// String returns the source form of t, i.e. t or s.T()
+func (t testingTarget) String() string {
+	if t.suite {
+		return t.name + ".T()"
+	}
+	return t.name
+}
+
+// expr builds the expression of t with all positions set to pos
+func (t testingTarget) expr(pos token.Pos) ast.Expr {
+	ident := &ast.Ident{NamePos: pos, Name: t.name}
+	if !t.suite {
+		return ident
+	}
+	return &ast.CallExpr{
+		Fun:    &ast.SelectorExpr{X: ident, Sel: &ast.Ident{NamePos: pos, Name: "T"}},
+		Lparen: pos,
+		Rparen: pos,
+	}
+}
+
+// matches reports whether expr refers to t
+func (t testingTarget) matches(expr ast.Expr) bool {
+	if t.suite {
+		call, ok := expr.(*ast.CallExpr)
+		if !ok || len(call.Args) != 0 {
+			return false
+		}
+		selector, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || selector.Sel.Name != "T" {
+			return false
+		}
+		expr = selector.X
+	}
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == t.name
+}
+
+// DetectSuite is the suite type which makes a visitor match the test
+// methods of all suite types of the visited file which embed the Suite of
+// github.com/stretchr/testify/suite, directly or through another suite type
+// of the file
+const DetectSuite = "auto"
+
+// testifySuiteImport is the import path of the testify suite package
+const testifySuiteImport = "github.com/stretchr/testify/suite"
+
+// suiteReceiverName is the name given to an unnamed or blank receiver of a
+// suite test method
+const suiteReceiverName = "s"
+
+// SetSuite makes the visitor match the test methods of the given suite
+// type, like
+//
+//	func (s *MySuite) TestFoo()
+//
+// instead of test functions. Skips are added as s.T().Skip(), an unnamed
+// receiver is named s. With DetectSuite the suite types are detected, see
+// there. An empty suiteType restores matching test functions.
+func (f *testFuncVisitor) SetSuite(suiteType string) {
+	f.suite = suiteType
+}
+
+// isSuiteType reports whether the test methods of the type named typeName
+// are matched
+func (f testFuncVisitor) isSuiteType(typeName string) bool {
+	if f.suite == DetectSuite {
+		return f.suiteTypes[typeName]
+	}
+	return typeName == f.suite
+}
+
+// suiteTestType returns the name of the receiver type of funcDecl if it is
+// a test method without parameters on a type or a pointer to it
+func suiteTestType(funcDecl *ast.FuncDecl) (string, bool) {
+	if funcDecl.Recv == nil || len(funcDecl.Recv.List) != 1 || len(funcDecl.Type.Params.List) != 0 {
+		return "", false
+	}
+	if !isTest(funcDecl.Name.Name, "Test") {
+		return "", false
+	}
+	recvType := funcDecl.Recv.List[0].Type
+	if star, ok := recvType.(*ast.StarExpr); ok {
+		recvType = star.X
+	}
+	ident, ok := recvType.(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	return ident.Name, true
+}
+
+// testifySuites returns the names of the struct types of file which embed
+// the Suite of the testify suite package, or another of these types
+func testifySuites(file *ast.File) map[string]bool {
+	qualifiers := make(map[string]bool)
+	for _, spec := range file.Imports {
+		if importPath, err := strconv.Unquote(spec.Path.Value); err == nil && importPath == testifySuiteImport {
+			name := importName(spec)
+			if name == "." {
+				name = ""
+			}
+			qualifiers[name] = true
+		}
+	}
+	embeds := make(map[string][]ast.Expr)
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			structType, ok := typeSpec.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			for _, field := range structType.Fields.List {
+				if len(field.Names) == 0 {
+					embeds[typeSpec.Name.Name] = append(embeds[typeSpec.Name.Name], field.Type)
+				}
+			}
+		}
+	}
+	suites := make(map[string]bool)
+	// a type is a suite if it embeds testify's Suite or a type already
+	// known to be a suite, until no further suites are found
+	for found := true; found; {
+		found = false
+		for typeName, fields := range embeds {
+			if suites[typeName] {
+				continue
+			}
+			for _, field := range fields {
+				if embedsSuite(field, qualifiers, suites) {
+					suites[typeName] = true
+					found = true
+					break
+				}
+			}
+		}
+	}
+	return suites
+}
+
+// embedsSuite reports whether the embedded field type is the Suite of the
+// testify suite package, imported under one of qualifiers, or one of suites
+func embedsSuite(field ast.Expr, qualifiers, suites map[string]bool) bool {
+	if star, ok := field.(*ast.StarExpr); ok {
+		field = star.X
+	}
+	switch field := field.(type) {
+	case *ast.Ident:
+		return suites[field.Name] || qualifiers[""] && field.Name == "Suite"
+	case *ast.SelectorExpr:
+		pkg, ok := field.X.(*ast.Ident)
+		return ok && qualifiers[pkg.Name] && field.Sel.Name == "Suite"
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+package testskipper
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestSuiteVisitor(t *testing.T) {
+	src := `
+	package main
+
+	import "testing"
+
+	type MySuite struct{}
+
+	type OtherSuite struct{}
+
+	func (s *MySuite) TestFoo() {
+		s.T().Log("foo")
+	}
+
+	func (s MySuite) TestBar() {
+		s.T().Log("bar")
+	}
+
+	func (s *MySuite) SetupTest() {
+		s.T().Log("setup")
+	}
+
+	func (o *OtherSuite) TestFoo() {
+		o.T().Log("foo")
+	}
+
+	func TestMySuite(t *testing.T) {
+		t.Log("run")
+	}`
+
+	expected := `
+	package main
+
+	import "testing"
+
+	type MySuite struct{}
+
+	type OtherSuite struct{}
+
+	// gotestskipper:skip
+	func (s *MySuite) TestFoo() {
+		s.T().Skip()
+		s.T().Log("foo")
+	}
+
+	// gotestskipper:skip
+	func (s MySuite) TestBar() {
+		s.T().Skip()
+		s.T().Log("bar")
+	}
+
+	func (s *MySuite) SetupTest() {
+		s.T().Log("setup")
+	}
+
+	func (o *OtherSuite) TestFoo() {
+		o.T().Log("foo")
+	}
+
+	func TestMySuite(t *testing.T) {
+		t.Log("run")
+	}`
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.AllErrors)
+	if err != nil {
+		panic(err)
+	}
+	visitor := NewTestFuncVisitor(SkipTestVisitorAction)
+	visitor.SetSuite("MySuite")
+	ast.Walk(visitor, file)
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+
+	if replacer.Replace(expected) != replacer.Replace(buffer.String()) {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, buffer.String())
+	}
+
+	// Unskipping removes the suite skips again
+	visitor = NewTestFuncVisitor(UnskipTestVisitorAction)
+	visitor.SetSuite("MySuite")
+	ast.Walk(visitor, file)
+
+	buffer.Reset()
+	printer.Fprint(&buffer, fileSet, file)
+
+	if replacer.Replace(src) != replacer.Replace(buffer.String()) {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", src, buffer.String())
+	}
+}
+
+func TestSuiteVisitorDetectSuite(t *testing.T) {
+	src := `
+	package main
+
+	import (
+		"testing"
+
+		testify "github.com/stretchr/testify/suite"
+	)
+
+	type BaseSuite struct {
+		testify.Suite
+	}
+
+	type MySuite struct {
+		*BaseSuite
+	}
+
+	type PlainSuite struct {
+		testify.Suite
+	}
+
+	type NoSuite struct{}
+
+	func (s *BaseSuite) TestBase() {
+		s.T().Log("base")
+	}
+
+	func (s *MySuite) TestFoo() {
+		s.T().Log("foo")
+	}
+
+	func (p PlainSuite) TestBar() {
+		p.T().Log("bar")
+	}
+
+	func (n *NoSuite) TestFoo() {
+		n.T().Log("foo")
+	}
+
+	func TestMySuite(t *testing.T) {
+		testify.Run(t, new(MySuite))
+	}`
+
+	expected := `
+	package main
+
+	import (
+		"testing"
+
+		testify "github.com/stretchr/testify/suite"
+	)
+
+	type BaseSuite struct {
+		testify.Suite
+	}
+
+	type MySuite struct {
+		*BaseSuite
+	}
+
+	type PlainSuite struct {
+		testify.Suite
+	}
+
+	type NoSuite struct{}
+
+	// gotestskipper:skip
+	func (s *BaseSuite) TestBase() {
+		s.T().Skip()
+		s.T().Log("base")
+	}
+
+	// gotestskipper:skip
+	func (s *MySuite) TestFoo() {
+		s.T().Skip()
+		s.T().Log("foo")
+	}
+
+	// gotestskipper:skip
+	func (p PlainSuite) TestBar() {
+		p.T().Skip()
+		p.T().Log("bar")
+	}
+
+	func (n *NoSuite) TestFoo() {
+		n.T().Log("foo")
+	}
+
+	func TestMySuite(t *testing.T) {
+		testify.Run(t, new(MySuite))
+	}`
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.AllErrors)
+	if err != nil {
+		panic(err)
+	}
+	visitor := NewTestFuncVisitor(SkipTestVisitorAction)
+	visitor.SetSuite(DetectSuite)
+	ast.Walk(visitor, file)
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+
+	if replacer.Replace(expected) != replacer.Replace(buffer.String()) {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, buffer.String())
+	}
+}
+
+func TestTestifySuitesWithoutImport(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "foo_test.go", `
+	package main
+
+	import "example.com/suite"
+
+	type MySuite struct {
+		suite.Suite
+	}`, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err)
+	}
+
+	suites := testifySuites(file)
+
+	if len(suites) != 0 {
+		t.Fatalf("Expected no suites, got %v\n", suites)
+	}
+}
+
+func TestApplySkipSuiteReceiverName(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{
+			name:     "named",
+			src:      `func (m *MySuite) TestFoo() { m.T().Log("foo") }`,
+			expected: `func (m *MySuite) TestFoo() { m.T().Skip(); m.T().Log("foo") }`,
+		},
+		{
+			name:     "unnamed",
+			src:      `func (*MySuite) TestFoo() {}`,
+			expected: `func (s *MySuite) TestFoo() { s.T().Skip() }`,
+		},
+		{
+			name:     "blank",
+			src:      `func (_ MySuite) TestFoo() { s := 1; _ = s }`,
+			expected: `func (s2 MySuite) TestFoo() { s2.T().Skip(); s := 1; _ = s }`,
+		},
+	}
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "", ";", "")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fileSet, _, decl := parseFuncDecl(t, "package main\n\n"+test.src)
+
+			err := ApplySkip(fileSet, decl, SkipOptions{})
+
+			if err != nil {
+				t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err)
+			}
+			var buffer bytes.Buffer
+			printer.Fprint(&buffer, fileSet, decl)
+			if replacer.Replace(test.expected) != replacer.Replace(withoutSkipMarkers(buffer.String())) {
+				t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", test.expected, buffer.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package testskipper
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"sort"
+)
+
+// surgicalEditor is implemented by visitors which track the declarations
+// they modified, so that only these need to be printed. The declarations are
+// mapped to their start in the source, see declStart, as the modification
+// may have changed their doc comment.
+type surgicalEditor interface {
+	changedDecls() map[ast.Decl]token.Pos
+}
+
+// SetSurgical controls whether only the modified functions are re-printed
+// when the visited file is written. All other source is kept byte for byte,
+// including vertical spacing the printer would otherwise normalize.
+func (f *testFuncVisitor) SetSurgical(surgical bool) {
+	if surgical {
+		f.changed = make(map[ast.Decl]token.Pos)
+	} else {
+		f.changed = nil
+	}
+}
+
+func (f testFuncVisitor) changedDecls() map[ast.Decl]token.Pos {
+	return f.changed
+}
+
+// printSurgical writes the original source src of the file at path to
+// buffer, replacing the source of each declaration in changed with its
+// printed form. Changed declarations which are no longer part of file are
+// removed together with their line. A doc comment added to a declaration
+// without one is separated from the preceding source by a blank line, as
+// the printer would do. If src is nil, the source is read from path.
+func printSurgical(buffer *bytes.Buffer, path string, src []byte, fileSet *token.FileSet, file *ast.File, changed map[ast.Decl]token.Pos) error {
+	if src == nil {
+		var err error
+		src, err = ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+	}
+	kept := make(map[ast.Decl]bool)
+	for _, decl := range file.Decls {
+		kept[decl] = true
+	}
+	var decls []ast.Decl
+	for decl := range changed {
+		decls = append(decls, decl)
+	}
+	sort.Slice(decls, func(i, j int) bool { return decls[i].Pos() < decls[j].Pos() })
+
+	var last int
+	for _, decl := range decls {
+		start := changed[decl]
+		if !kept[decl] {
+			buffer.Write(src[last:fileSet.Position(start).Offset])
+			last = fileSet.Position(decl.End()).Offset
+			for last < len(src) && src[last] == '\n' && bytes.HasSuffix(buffer.Bytes(), []byte("\n")) {
+				last++
+			}
+			continue
+		}
+		// the doc comment may be placed outside of the source, see
+		// placeDocComment
+		doc := declDoc(decl)
+		var comments []*ast.CommentGroup
+		if doc != nil {
+			comments = append(comments, doc)
+		}
+		startOffset := fileSet.Position(start).Offset
+		endOffset := fileSet.Position(decl.End()).Offset
+		// moved comments are compared by offset, see moveStmtComments. A
+		// doc comment of the following declaration may start at the end of
+		// decl, see placeDocComment.
+		declOffset := fileSet.Position(decl.Pos()).Offset
+		for _, group := range file.Comments {
+			if offset := fileSet.Position(group.Pos()).Offset; offset >= declOffset && offset < endOffset {
+				comments = append(comments, group)
+			}
+		}
+		buffer.Write(src[last:startOffset])
+		// a new doc comment is separated from the preceding line like the
+		// printer does, see placeDocComment
+		if doc != nil && doc.Pos() != start && !bytes.HasSuffix(buffer.Bytes(), []byte("\n\n")) {
+			buffer.WriteByte('\n')
+		}
+		if err := printDecl(buffer, fileSet, file, decl, comments); err != nil {
+			return err
+		}
+		last = endOffset
+	}
+	buffer.Write(src[last:])
+	return nil
+}
+
+// printDecl writes decl with the given comments to buffer. The printer
+// only includes the comments of a printer.CommentedNode whose positions
+// are within the declaration, which does not hold for comments moved into
+// another file of the same name, see placeDocComment and moveStmtComments.
+// decl is therefore printed as the only declaration of a file, whose
+// package clause is cut off.
+func printDecl(buffer *bytes.Buffer, fileSet *token.FileSet, file *ast.File, decl ast.Decl, comments []*ast.CommentGroup) error {
+	var printed bytes.Buffer
+	declFile := &ast.File{Package: file.Package, Name: file.Name, Decls: []ast.Decl{decl}, Comments: comments}
+	if err := format.Node(&printed, fileSet, declFile); err != nil {
+		return err
+	}
+	source := printed.Bytes()
+	if i := bytes.IndexByte(source, '\n'); i >= 0 {
+		source = source[i+1:]
+	}
+	buffer.Write(bytes.TrimRight(bytes.TrimLeft(source, "\n"), "\n"))
+	return nil
+}
+
+// declStart returns the position of the doc comment of decl, or of decl
+// itself if it has none
+func declStart(decl ast.Decl) token.Pos {
+	if doc := declDoc(decl); doc != nil {
+		return doc.Pos()
+	}
+	return decl.Pos()
+}
+
+// declDoc returns the doc comment of decl
+func declDoc(decl ast.Decl) *ast.CommentGroup {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		return decl.Doc
+	case *ast.GenDecl:
+		return decl.Doc
+	}
+	return nil
+}
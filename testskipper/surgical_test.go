@@ -0,0 +1,92 @@
+package testskipper
+
+import (
+	"bytes"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestWalkFileSurgical(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+
+// TestFoo is kept    as is
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+
+
+	t.Log("foo")
+}
+
+
+func TestBar(t *testing.T) {
+	// bar
+	t.Log("bar")
+}
+`
+	tmpFilePath := "tempFile_test.go"
+	err := ioutil.WriteFile(tmpFilePath, []byte(src), 0644)
+	if err != nil {
+		panic(err)
+	}
+	defer os.Remove(tmpFilePath)
+
+	onlyBar := func(fileSet *token.FileSet, f *ast.FuncDecl) bool {
+		return f.Name.Name == "TestBar"
+	}
+	visitor := NewTestFuncVisitor(SkipTestVisitorAction)
+	visitor.AddFilter(onlyBar)
+	visitor.SetSurgical(true)
+	var buffer bytes.Buffer
+
+	err = WalkFile(tmpFilePath, &buffer, visitor)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+
+	expected := `package main
+
+import "testing"
+
+
+// TestFoo is kept    as is
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+
+
+	t.Log("foo")
+}
+
+
+// gotestskipper:skip
+func TestBar(t *testing.T) {
+	t.Skip()
+
+	// bar
+	t.Log("bar")
+}
+`
+	if buffer.String() != expected {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, buffer.String())
+	}
+
+	// Without surgical edits blank lines are collapsed
+	visitor = NewTestFuncVisitor(SkipTestVisitorAction)
+	visitor.AddFilter(onlyBar)
+	buffer.Reset()
+
+	err = WalkFile(tmpFilePath, &buffer, visitor)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if bytes.Contains(buffer.Bytes(), []byte("\n\n\n")) {
+		t.Fatalf("Expected blank lines to be collapsed, got \n`%s`\n", buffer.String())
+	}
+}
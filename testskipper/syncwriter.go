@@ -0,0 +1,71 @@
+package testskipper
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// ReadWriterProvider provides an io.ReadWriter per path, which the walk
+// functions write the visited files into. PathWriter is the plain
+// implementation, SyncPathWriter the one safe for concurrent use.
+type ReadWriterProvider interface {
+	ReadWriterForPath(path string) io.ReadWriter
+}
+
+// SyncPathWriter is a mapping of paths to buffers like PathWriter, which is
+// safe for concurrent use, e.g. by several walks sharing it. The buffers it
+// returns are guarded as well, so that concurrent writes to the same path
+// do not corrupt it, though their order is unspecified.
+type SyncPathWriter struct {
+	mu      sync.Mutex
+	buffers map[string]*syncBuffer
+}
+
+// NewSyncPathWriter returns an empty SyncPathWriter
+func NewSyncPathWriter() *SyncPathWriter {
+	return &SyncPathWriter{buffers: make(map[string]*syncBuffer)}
+}
+
+// ReadWriterForPath returns the io.ReadWriter associated to path, or a new
+// empty one if there is no entry for path yet
+func (s *SyncPathWriter) ReadWriterForPath(path string) io.ReadWriter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if buffer, ok := s.buffers[path]; ok {
+		return buffer
+	}
+	buffer := &syncBuffer{}
+	s.buffers[path] = buffer
+	return buffer
+}
+
+// PathWriter returns a PathWriter holding the buffers of s, e.g. to write
+// them out with the helpers of PathWriter once all writers are done
+func (s *SyncPathWriter) PathWriter() PathWriter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pathWriter := make(PathWriter, len(s.buffers))
+	for path, buffer := range s.buffers {
+		pathWriter[path] = buffer
+	}
+	return pathWriter
+}
+
+// syncBuffer is a bytes.Buffer guarded by a mutex
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Read(p)
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Write(p)
+}
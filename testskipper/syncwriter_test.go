@@ -0,0 +1,91 @@
+package testskipper
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSyncPathWriterConcurrentUse(t *testing.T) {
+	pathWriter := NewSyncPathWriter()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fmt.Fprintf(pathWriter.ReadWriterForPath(fmt.Sprintf("foo%d_test.go", i)), "foo%d", i)
+			fmt.Fprint(pathWriter.ReadWriterForPath("shared_test.go"), "x")
+		}(i)
+	}
+	wg.Wait()
+
+	paths := pathWriter.PathWriter()
+
+	if len(paths) != 21 {
+		t.Fatalf("Expected 21 paths, got %d: %v\n", len(paths), paths.Paths())
+	}
+	for i := 0; i < 20; i++ {
+		content, err := ioutil.ReadAll(paths[fmt.Sprintf("foo%d_test.go", i)])
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err)
+		}
+		if expected := fmt.Sprintf("foo%d", i); string(content) != expected {
+			t.Fatalf("Expected content %q, got %q\n", expected, content)
+		}
+	}
+	content, err := ioutil.ReadAll(paths["shared_test.go"])
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err)
+	}
+	if expected := strings.Repeat("x", 20); string(content) != expected {
+		t.Fatalf("Expected content %q, got %q\n", expected, content)
+	}
+}
+
+func TestWalkDirSharedSyncPathWriter(t *testing.T) {
+	dirs := make([]string, 4)
+	for i := range dirs {
+		dir, err := ioutil.TempDir("", "testskipper")
+		if err != nil {
+			panic(err)
+		}
+		defer os.RemoveAll(dir)
+		src := "package foo\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {\n\tt.Log(\"foo\")\n}\n"
+		if err := ioutil.WriteFile(filepath.Join(dir, "foo_test.go"), []byte(src), 0644); err != nil {
+			panic(err)
+		}
+		dirs[i] = dir
+	}
+	pathWriter := NewSyncPathWriter()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(dirs))
+	for i, dir := range dirs {
+		wg.Add(1)
+		go func(i int, dir string) {
+			defer wg.Done()
+			errs[i] = WalkDir(dir, pathWriter, NewTestFuncVisitor(SkipTestVisitorAction))
+		}(i, dir)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err)
+		}
+	}
+	paths := pathWriter.PathWriter()
+	for _, dir := range dirs {
+		content, err := ioutil.ReadAll(paths[filepath.Join(dir, "foo_test.go")])
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err)
+		}
+		if !strings.Contains(string(content), "t.Skip()") {
+			t.Fatalf("Expected %s to be skipped, got \n`%s`\n", dir, content)
+		}
+	}
+}
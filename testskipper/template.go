@@ -0,0 +1,93 @@
+package testskipper
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"text/template"
+)
+
+// SkipTemplate is a text/template rendering the skip call inserted instead
+// of t.Skip(), like
+//
+//	testutil.Skip({{.Param}})
+//
+// where {{.Param}} is substituted with the name of the testing parameter.
+// For suite test methods it is the name of the receiver, so that a template
+// for them reads like {{.Param}}.T().SkipNow().
+type SkipTemplate struct {
+	text     string
+	template *template.Template
+}
+
+// skipTemplateData is the data a SkipTemplate is executed with
+type skipTemplateData struct {
+	Param string
+}
+
+// ParseSkipTemplate parses text as a SkipTemplate. The template is rendered
+// once to make sure it results in a call expression, so that applying it
+// later does not fail.
+func ParseSkipTemplate(text string) (*SkipTemplate, error) {
+	tmpl, err := template.New("skip").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid skip template %q: %v", text, err)
+	}
+	skipTemplate := &SkipTemplate{text: text, template: tmpl}
+	if _, err := skipTemplate.call(DefaultParamName); err != nil {
+		return nil, err
+	}
+	return skipTemplate, nil
+}
+
+func (s *SkipTemplate) String() string {
+	return s.text
+}
+
+// call renders the template for the testing parameter param
+func (s *SkipTemplate) call(param string) (*ast.CallExpr, error) {
+	var buffer bytes.Buffer
+	if err := s.template.Execute(&buffer, skipTemplateData{Param: param}); err != nil {
+		return nil, fmt.Errorf("invalid skip template %q: %v", s.text, err)
+	}
+	expr, err := parser.ParseExpr(buffer.String())
+	if err != nil {
+		return nil, fmt.Errorf("skip template %q does not render an expression: %v", s.text, err)
+	}
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return nil, fmt.Errorf("skip template %q does not render a call: %s", s.text, buffer.String())
+	}
+	return call, nil
+}
+
+// expr renders the template for target with all positions set to pos, as
+// the positions of the parsed expression do not belong to the file it is
+// inserted into
+func (s *SkipTemplate) expr(target testingTarget, pos token.Pos) (ast.Expr, error) {
+	call, err := s.call(target.name)
+	if err != nil {
+		return nil, err
+	}
+	mapPositions(call, func(token.Pos) token.Pos {
+		return pos
+	})
+	return call, nil
+}
+
+// matches reports whether stmt is the call rendered by the template for
+// target. A nil template matches nothing.
+func (s *SkipTemplate) matches(stmt ast.Stmt, target testingTarget) bool {
+	if s == nil {
+		return false
+	}
+	exprStmt, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, err := s.call(target.name)
+	return err == nil && types.ExprString(exprStmt.X) == types.ExprString(call)
+}
@@ -0,0 +1,75 @@
+package testskipper
+
+import (
+	"bytes"
+	"go/printer"
+	"strings"
+	"testing"
+)
+
+func TestParseSkipTemplate(t *testing.T) {
+	tests := []struct {
+		text string
+		err  string
+	}{
+		{"{{.Param}}.Skip()", ""},
+		{"testutil.Skip({{.Param}}, \"flaky\")", ""},
+		{"{{.Param}.Skip()", "invalid skip template \"{{.Param}.Skip()\": "},
+		{"{{.Name}}.Skip()", "invalid skip template \"{{.Name}}.Skip()\": "},
+		{"{{.Param}}.Skip(", "does not render an expression"},
+		{"{{.Param}}", "does not render a call: t"},
+	}
+	for _, test := range tests {
+		_, err := ParseSkipTemplate(test.text)
+
+		if test.err == "" && err != nil {
+			t.Fatalf("Expected no error for %q, got '%T' with message: '%s'\n", test.text, err, err)
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Fatalf("Expected error containing %q for %q, got %v\n", test.err, test.text, err)
+		}
+	}
+}
+
+func TestApplySkipTemplate(t *testing.T) {
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	template, err := ParseSkipTemplate("testutil.Skip({{.Param}})")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err)
+	}
+	src := "package main\n\nimport \"testing\"\n\nfunc TestFoo(tt *testing.T) {\n\t// log\n\ttt.Log(\"foo\")\n}\n"
+	expected := "package main\n\nimport \"testing\"\n\nfunc TestFoo(tt *testing.T) {\n\ttestutil.Skip(tt)\n\n\t// log\n\ttt.Log(\"foo\")\n}\n"
+	fileSet, file, funcDecl := parseFuncDecl(t, src)
+
+	// skipping twice adds the call once
+	for i := 0; i < 2; i++ {
+		if err := ApplySkip(fileSet, funcDecl, SkipOptions{Template: template, Reason: "ignored"}); err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err)
+		}
+	}
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+	if replacer.Replace(expected) != replacer.Replace(buffer.String()) {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, buffer.String())
+	}
+
+	// the call is only removed as a skip with the template
+	if err := ApplyUnskip(fileSet, funcDecl); err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err)
+	}
+	buffer.Reset()
+	printer.Fprint(&buffer, fileSet, file)
+	if replacer.Replace(expected) != replacer.Replace(buffer.String()) {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, buffer.String())
+	}
+
+	if err := ApplyUnskipWithOptions(fileSet, funcDecl, UnskipOptions{Template: template}); err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err)
+	}
+	buffer.Reset()
+	printer.Fprint(&buffer, fileSet, file)
+	if replacer.Replace(src) != replacer.Replace(buffer.String()) {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", src, buffer.String())
+	}
+}
@@ -1,51 +1,464 @@
+// Package testskipper skips and unskips the tests of Go source files by
+// rewriting their ASTs. It is the library behind the gotestskipper command
+// and the package to import for using the functionality from Go code.
 package testskipper
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"go/ast"
+	"go/build"
+	"go/build/constraint"
+	"go/format"
 	"go/parser"
 	"go/printer"
+	"go/scanner"
 	"go/token"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 	"unicode"
 	"unicode/utf8"
 )
 
 const defaultTestImport string = "testing"
-const testImportTemplate string = "*%s.T"
+
+// DefaultBenchmarkPrefix is the name prefix of the benchmarks matched by
+// default
+const DefaultBenchmarkPrefix string = "Benchmark"
 
 type testFuncVisitor struct {
 	visitAction FuncVisitAction
 	testImport  string
+	paramType   string
+	relaxed     bool
+	helpers     bool
+	filters     []FuncFilter
+	fileFilters []FileFilter
+	pathFilters []PathFilter
+	fileSet     *token.FileSet
+	report      *Report
+	changed     map[ast.Decl]token.Pos
+	suite       string
+	suiteTypes  map[string]bool
+	prefixes    []string
+	testMain    bool
+	fuzz        bool
+	benchmarks  bool
+	benchPrefix string
+	skipCalls   []SkipCall
+	unskipNote  time.Time
+	file        *ast.File
+	refs        map[*ast.File]map[string]int
+	emptied     map[*ast.File][]int
+	metrics     Metrics
+	failFast    bool
+	qualifiers  map[string]bool
+	modified    map[*ast.File]bool
+	workers     int
 }
 
 func (f testFuncVisitor) Visit(node ast.Node) ast.Visitor {
+	if file, ok := node.(*ast.File); ok {
+		for _, filter := range f.fileFilters {
+			if !filter(file) {
+				return nil
+			}
+		}
+		f.file = file
+		f.qualifiers = testingQualifiers(file, f.testImport)
+		if f.suite == DetectSuite {
+			f.suiteTypes = testifySuites(file)
+		}
+		if f.refs != nil {
+			f.refs[file] = packageRefs(file)
+		}
+		return f
+	}
 	if funcDecl, ok := node.(*ast.FuncDecl); ok {
+		if f.suite != "" {
+			if typeName, ok := suiteTestType(funcDecl); ok && f.isSuiteType(typeName) && f.accepts(funcDecl) {
+				f.visit(funcDecl)
+			}
+			return nil
+		}
 		if funcDecl.Recv != nil {
 			return nil
 		}
-		if isTest(funcDecl.Name.Name, "Test") {
-			if len(funcDecl.Type.Params.List) == 1 {
-				param := funcDecl.Type.Params.List[0]
-				var buffer bytes.Buffer
-				printer.Fprint(&buffer, token.NewFileSet(), param.Type)
-				if fmt.Sprintf(testImportTemplate, f.testImport) == buffer.String() {
-					f.visitAction(funcDecl)
-					return nil
-				}
+		if f.testMain {
+			if f.isTestMain(funcDecl) && f.accepts(funcDecl) {
+				f.visit(funcDecl)
+			}
+			return nil
+		}
+		if kind, ok := f.kindOf(funcDecl.Name.Name); ok && f.hasKindParam(funcDecl, kind) {
+			if f.accepts(funcDecl) {
+				f.visit(funcDecl)
 			}
+			return nil
 		}
 	}
 	return f
 }
 
+// funcKind describes a kind of function run by the testing package
+type funcKind struct {
+	// prefix is the name prefix of the functions
+	prefix string
+	// typeName is the name of the parameter type in the testing package
+	typeName string
+	// paramName is the conventional name of the parameter
+	paramName string
+}
+
+// funcKinds are the kinds of functions matched by a visitor. A function
+// is of the first enabled kind whose prefix its name starts with. The
+// prefixes of tests and benchmarks can be changed, see SetPrefixes and
+// SetBenchmarkPrefix.
+var funcKinds = []funcKind{
+	{prefix: "Test", typeName: "T", paramName: DefaultParamName},
+	{prefix: "Fuzz", typeName: "F", paramName: "f"},
+	{prefix: DefaultBenchmarkPrefix, typeName: "B", paramName: "b"},
+}
+
+// kindOf returns the kind of the functions named name matched by f
+func (f testFuncVisitor) kindOf(name string) (funcKind, bool) {
+	for _, kind := range funcKinds {
+		prefixes := []string{kind.prefix}
+		switch kind.typeName {
+		case "T":
+			if len(f.prefixes) > 0 {
+				prefixes = f.prefixes
+			}
+		case "F":
+			if !f.fuzz {
+				continue
+			}
+		case "B":
+			if !f.benchmarks {
+				continue
+			}
+			prefixes = []string{f.benchmarkPrefix()}
+		}
+		for _, prefix := range prefixes {
+			if isTest(name, prefix) {
+				return kind, true
+			}
+		}
+	}
+	return funcKind{}, false
+}
+
+// hasKindParam reports whether funcDecl has the parameter of a function of
+// kind. Only test functions may have a custom parameter type and, if not
+// strict, further parameters.
+func (f testFuncVisitor) hasKindParam(funcDecl *ast.FuncDecl, kind funcKind) bool {
+	if kind.typeName == "T" {
+		return hasParamType(funcDecl, f.isTestParamType, f.relaxed)
+	}
+	return hasParamType(funcDecl, f.testingType(kind.typeName), false)
+}
+
+// hasParamType reports whether the only parameter of funcDecl, or with
+// relaxed the first of several parameters, has a type accepted by isType
+func hasParamType(funcDecl *ast.FuncDecl, isType func(ast.Expr) bool, relaxed bool) bool {
+	params := funcDecl.Type.Params.List
+	if len(params) != 1 && !(relaxed && len(params) > 1) {
+		return false
+	}
+	return isType(params[0].Type)
+}
+
+// testingType returns a check whether an expression is a pointer to the
+// type name of the testing package, like *testing.T, as imported by the
+// visited file
+func (f testFuncVisitor) testingType(name string) func(ast.Expr) bool {
+	qualifiers := f.qualifiers
+	if qualifiers == nil {
+		qualifiers = map[string]bool{f.testImport: true}
+	}
+	return func(expr ast.Expr) bool {
+		star, ok := expr.(*ast.StarExpr)
+		if !ok {
+			return false
+		}
+		switch typeName := ast.Unparen(star.X).(type) {
+		case *ast.Ident:
+			return qualifiers[""] && typeName.Name == name
+		case *ast.SelectorExpr:
+			pkg, ok := typeName.X.(*ast.Ident)
+			return ok && qualifiers[pkg.Name] && typeName.Sel.Name == name
+		}
+		return false
+	}
+}
+
+// testingQualifiers returns the names under which file refers to the
+// package named testImport, with "" for a dot import. The import is
+// resolved by the default name of its path, so that aliased imports like
+//
+//	import tst "testing"
+//
+// are found as well. If file does not import such a package, testImport is
+// taken to be the name used by file.
+func testingQualifiers(file *ast.File, testImport string) map[string]bool {
+	qualifiers := make(map[string]bool)
+	for _, spec := range file.Imports {
+		if importName(&ast.ImportSpec{Path: spec.Path}) != testImport {
+			continue
+		}
+		switch {
+		case spec.Name == nil:
+			qualifiers[testImport] = true
+		case spec.Name.Name == ".":
+			qualifiers[""] = true
+		case spec.Name.Name != "_":
+			qualifiers[spec.Name.Name] = true
+		}
+	}
+	if len(qualifiers) == 0 {
+		qualifiers[testImport] = true
+	}
+	return qualifiers
+}
+
+// SetPrefixes sets the name prefixes of the test functions to match, e.g.
+// IntegrationTest and AcceptanceTest, replacing the default prefix Test.
+// Like for Test, the character following a prefix must not be a lower case
+// letter. No prefixes restore the default.
+func (f *testFuncVisitor) SetPrefixes(prefixes []string) {
+	f.prefixes = prefixes
+}
+
+// SetFuzz controls whether fuzz targets like
+//
+//	func FuzzFoo(f *testing.F)
+//
+// are matched in addition to test functions
+func (f *testFuncVisitor) SetFuzz(fuzz bool) {
+	f.fuzz = fuzz
+}
+
+// SetBenchmarks controls whether benchmarks like
+//
+//	func BenchmarkFoo(b *testing.B)
+//
+// are matched in addition to test functions. Like for tests, the parameter
+// type is qualified with the name set by SetTestImport.
+func (f *testFuncVisitor) SetBenchmarks(benchmarks bool) {
+	f.benchmarks = benchmarks
+}
+
+// SetBenchmarkPrefix sets the name prefix of the benchmarks to match. An
+// empty prefix restores DefaultBenchmarkPrefix.
+func (f *testFuncVisitor) SetBenchmarkPrefix(prefix string) {
+	f.benchPrefix = prefix
+}
+
+func (f testFuncVisitor) benchmarkPrefix() string {
+	if f.benchPrefix != "" {
+		return f.benchPrefix
+	}
+	return DefaultBenchmarkPrefix
+}
+
+// SetTestImport sets the name of the testing package. The visited files
+// may import it under an alias or with a dot import, which is resolved
+// through their imports.
 func (f *testFuncVisitor) SetTestImport(testImport string) {
 	f.testImport = testImport
 }
 
+// SetParamType sets the exact type of the testing parameter to match,
+// e.g. *qt.T. It overrides the type derived from the test import. An empty
+// paramType restores the default detection.
+func (f *testFuncVisitor) SetParamType(paramType string) {
+	if expr, err := parser.ParseExpr(paramType); err == nil {
+		var buffer bytes.Buffer
+		printer.Fprint(&buffer, token.NewFileSet(), expr)
+		paramType = buffer.String()
+	}
+	f.paramType = paramType
+}
+
+// isTestParamType reports whether expr is the type of the testing
+// parameter of test functions
+func (f testFuncVisitor) isTestParamType(expr ast.Expr) bool {
+	if f.paramType != "" {
+		return nodeString(expr) == f.paramType
+	}
+	return f.testingType("T")(expr)
+}
+
+// SetStrict controls whether only test functions with exactly one
+// parameter are matched. If strict is false, functions whose first
+// parameter is the testing parameter but which take additional trailing
+// parameters, like TestFoo(t *testing.T, ctx context.Context), are matched
+// as well.
+func (f *testFuncVisitor) SetStrict(strict bool) {
+	f.relaxed = !strict
+}
+
+// AddFilter adds a filter which must accept a test function for the
+// visitAction to be called on it
+func (f *testFuncVisitor) AddFilter(filter FuncFilter) {
+	f.filters = append(f.filters, filter)
+}
+
+// AddFileFilter adds a filter which must accept a file for any of its test
+// functions to be visited
+func (f *testFuncVisitor) AddFileFilter(filter FileFilter) {
+	f.fileFilters = append(f.fileFilters, filter)
+}
+
+// AddPathFilter adds a filter which must accept the path of a file for
+// WalkDir to parse it at all
+func (f *testFuncVisitor) AddPathFilter(filter PathFilter) {
+	f.pathFilters = append(f.pathFilters, filter)
+}
+
+// acceptPath reports whether all path filters accept path
+func (f *testFuncVisitor) acceptPath(path string) bool {
+	for _, filter := range f.pathFilters {
+		if !filter(path) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetFileSet sets the token.FileSet the visited nodes belong to. It is
+// passed on to the filters.
+func (f *testFuncVisitor) SetFileSet(fileSet *token.FileSet) {
+	f.fileSet = fileSet
+}
+
+// SetSkipHelpers controls whether functions calling t.Helper() are acted
+// upon. By default they are excluded, as they are usually shared helpers
+// rather than tests.
+func (f *testFuncVisitor) SetSkipHelpers(skipHelpers bool) {
+	f.helpers = skipHelpers
+}
+
+// SetReport sets the report the visitor adds the functions it acted on to
+func (f *testFuncVisitor) SetReport(report *Report) {
+	f.report = report
+}
+
+func (f testFuncVisitor) currentReport() *Report {
+	return f.report
+}
+
+func (f testFuncVisitor) visit(funcDecl *ast.FuncDecl) {
+	if f.changed != nil || f.modified != nil {
+		start := declStart(funcDecl)
+		before := nodeString(funcDecl)
+		defer func() {
+			if nodeString(funcDecl) == before {
+				return
+			}
+			if _, ok := f.changed[funcDecl]; f.changed != nil && !ok {
+				f.changed[funcDecl] = start
+			}
+			if f.modified != nil {
+				f.modified[f.file] = true
+			}
+		}()
+	}
+	if f.file != nil && f.fileSet != nil && funcDecl.Body != nil {
+		before := append([]ast.Stmt(nil), funcDecl.Body.List...)
+		lists := stmtLists(funcDecl.Body)
+		defer func() {
+			if funcDecl.Body != nil {
+				comments := blockComments(f.file, funcDecl.Body)
+				removeStmtComments(f.fileSet, f.file, funcDecl.Body, before)
+				if f.emptied != nil {
+					f.emptied[f.file] = append(f.emptied[f.file], emptiedStmtLines(f.fileSet, f.file, lists, comments)...)
+				}
+				moveStmtComments(f.fileSet, f.file, funcDecl.Body, before)
+			}
+		}()
+	}
+	if f.file != nil {
+		doc := funcDecl.Doc
+		var docList []*ast.Comment
+		if doc != nil {
+			docList = append(docList, doc.List...)
+		}
+		defer func() {
+			if f.fileSet != nil && f.emptied != nil && funcDecl.Doc == doc {
+				f.emptied[f.file] = append(f.emptied[f.file], emptiedDocLines(f.fileSet, funcDecl, docList)...)
+			}
+			syncDocComment(f.fileSet, f.file, funcDecl, doc)
+		}()
+	}
+	if !f.unskipNote.IsZero() && isSkipped(funcDecl, f.skipCalls...) {
+		defer func() {
+			if !isSkipped(funcDecl, f.skipCalls...) {
+				addUnskipNote(funcDecl, f.unskipNote)
+			}
+		}()
+	}
+	if f.metrics != nil && f.metrics != NopMetrics {
+		skipped := isSkipped(funcDecl, f.skipCalls...)
+		defer func() {
+			switch after := isSkipped(funcDecl, f.skipCalls...); {
+			case after && !skipped:
+				f.metrics.Inc(MetricTestsSkipped)
+			case skipped && !after:
+				f.metrics.Inc(MetricTestsUnskipped)
+			}
+		}()
+	}
+	if f.report == nil {
+		f.visitAction(funcDecl)
+		return
+	}
+	var pkg string
+	if f.file != nil {
+		pkg = f.file.Name.Name
+	}
+	reportingVisitAction(f.report, f.fileSet, f.visitAction, pkg, funcDecl, f.skipCalls)
+}
+
+func (f testFuncVisitor) accepts(funcDecl *ast.FuncDecl) bool {
+	if !f.helpers && isHelper(funcDecl) {
+		return false
+	}
+	for _, filter := range f.filters {
+		if !filter(f.fileSet, funcDecl) {
+			return false
+		}
+	}
+	return true
+}
+
+// isHelper reports whether the body of funcDecl contains a top-level
+//
+//	t.Helper()
+//
+// call on its testing parameter
+func isHelper(funcDecl *ast.FuncDecl) bool {
+	params := funcDecl.Type.Params.List
+	if funcDecl.Body == nil || len(params) == 0 || len(params[0].Names) == 0 {
+		return false
+	}
+	for _, stmt := range funcDecl.Body.List {
+		if isCallStmt(stmt, params[0].Names[0].Name, "Helper") {
+			return true
+		}
+	}
+	return false
+}
+
 // isTest tells whether name looks like a test (or benchmark, according to prefix).
 // It is a Test (say) if there is a character after Test that is not a lower-case letter.
 // We don't want TesticularCancer.
@@ -60,63 +473,492 @@ func isTest(name, prefix string) bool {
 	return !unicode.IsLower(rune)
 }
 
+// FuncVisitAction is called on the matched functions. It has no way to
+// return an error, so the predefined actions panic with the errors of
+// ApplySkip and ApplyUnskip. WalkFile and WalkDir recover these panics and
+// return them as errors naming the file. Callers which want to handle
+// the errors themselves can call ApplySkip directly.
 type FuncVisitAction func(*ast.FuncDecl)
 
+// FuncFilter decides whether a test function should be visited. The
+// token.FileSet can be used to resolve the positions of funcDecl and may be
+// nil if the visitor was not provided with one.
+type FuncFilter func(fileSet *token.FileSet, funcDecl *ast.FuncDecl) bool
+
+// PathFilter decides whether the file at path should be parsed and visited
+type PathFilter func(path string) bool
+
+// ExcludePaths returns a PathFilter rejecting the files matched by any of
+// the patterns. A pattern as understood by filepath.Match matches a file if
+// it matches any sequence of consecutive elements of its path, so that e.g.
+// legacy excludes all files below directories named legacy, while
+// *_generated_test.go excludes these files in any directory.
+func ExcludePaths(patterns ...string) PathFilter {
+	return func(path string) bool {
+		elements := strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+		for _, pattern := range patterns {
+			for i := range elements {
+				for j := i + 1; j <= len(elements); j++ {
+					if matched, _ := filepath.Match(pattern, strings.Join(elements[i:j], "/")); matched {
+						return false
+					}
+				}
+			}
+		}
+		return true
+	}
+}
+
+// MatchBuildContext returns a PathFilter accepting the files which ctxt
+// would build, considering their GOOS and GOARCH name suffixes like
+// _windows_test.go as well as their build constraints. It only reads the
+// header of a file. Files which cannot be read are accepted, so that their
+// error is reported when they are parsed.
+func MatchBuildContext(ctxt *build.Context) PathFilter {
+	return func(path string) bool {
+		match, err := ctxt.MatchFile(filepath.Split(path))
+		return err != nil || match
+	}
+}
+
+// FileFilter decides whether the test functions of a file should be visited
+type FileFilter func(file *ast.File) bool
+
+// HasTestMain is a FileFilter accepting files which declare a TestMain
+// function
+func HasTestMain(file *ast.File) bool {
+	for _, decl := range file.Decls {
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok && funcDecl.Recv == nil && funcDecl.Name.Name == "TestMain" {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportsAny returns a FileFilter accepting files which import at least one
+// of the given package paths, e.g. database/sql or net/http
+func ImportsAny(paths ...string) FileFilter {
+	wanted := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		wanted[path] = true
+	}
+	return func(file *ast.File) bool {
+		for _, importSpec := range file.Imports {
+			if path, err := strconv.Unquote(importSpec.Path.Value); err == nil && wanted[path] {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasBuildTag returns a FileFilter accepting files whose build constraint,
+// in the //go:build or the legacy // +build form, refers to any of the
+// given tags without negating it, like
+//
+//	//go:build integration && !race
+//
+// for the tag integration
+func HasBuildTag(tags ...string) FileFilter {
+	wanted := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		wanted[tag] = true
+	}
+	return func(file *ast.File) bool {
+		for _, group := range file.Comments {
+			if group.Pos() >= file.Package {
+				break
+			}
+			for _, comment := range group.List {
+				if !constraint.IsGoBuild(comment.Text) && !constraint.IsPlusBuild(comment.Text) {
+					continue
+				}
+				expr, err := constraint.Parse(comment.Text)
+				if err == nil && refersToTag(expr, wanted, false) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// refersToTag reports whether expr contains any of the tags in wanted, not
+// negated if negated is false
+func refersToTag(expr constraint.Expr, wanted map[string]bool, negated bool) bool {
+	switch expr := expr.(type) {
+	case *constraint.TagExpr:
+		return !negated && wanted[expr.Tag]
+	case *constraint.NotExpr:
+		return refersToTag(expr.X, wanted, !negated)
+	case *constraint.AndExpr:
+		return refersToTag(expr.X, wanted, negated) || refersToTag(expr.Y, wanted, negated)
+	case *constraint.OrExpr:
+		return refersToTag(expr.X, wanted, negated) || refersToTag(expr.Y, wanted, negated)
+	}
+	return false
+}
+
+// IsStub is a FuncFilter accepting test functions with an empty body, which
+// usually are unimplemented stubs
+func IsStub(fileSet *token.FileSet, funcDecl *ast.FuncDecl) bool {
+	return funcDecl.Body != nil && len(funcDecl.Body.List) == 0
+}
+
+// NameMatching returns a FuncFilter accepting test functions whose name
+// matches re, like the -run flag of go test. A nil re accepts all test
+// functions.
+func NameMatching(re *regexp.Regexp) FuncFilter {
+	return func(fileSet *token.FileSet, funcDecl *ast.FuncDecl) bool {
+		return re == nil || re.MatchString(funcDecl.Name.Name)
+	}
+}
+
+// WithoutDirective returns a FuncFilter rejecting test functions whose doc
+// comment carries any of the given directives, like
+//
+//	//nolint
+//	//nolint:errcheck
+//
+// for the directive nolint. A leading // of a directive is ignored.
+func WithoutDirective(directives ...string) FuncFilter {
+	return func(fileSet *token.FileSet, funcDecl *ast.FuncDecl) bool {
+		for _, directive := range directives {
+			if hasDirective(funcDecl, strings.TrimPrefix(directive, "//")) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// hasDirective reports whether the doc comment of funcDecl contains a line
+// comment consisting of directive, optionally followed by arguments
+// separated by a colon, comma or space
+func hasDirective(funcDecl *ast.FuncDecl, directive string) bool {
+	if funcDecl.Doc == nil || directive == "" {
+		return false
+	}
+	for _, comment := range funcDecl.Doc.List {
+		text := strings.TrimPrefix(comment.Text, "//")
+		if text == directive || strings.HasPrefix(text, directive) && strings.ContainsRune(":, \t", rune(text[len(directive)])) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileSetter is implemented by visitors which need to know the
+// token.FileSet of the files they walk
+type fileSetter interface {
+	SetFileSet(fileSet *token.FileSet)
+}
+
+type pathAcceptor interface {
+	acceptPath(path string) bool
+}
+
+type errorCollector interface {
+	collectsErrors() bool
+}
+
+// SetCollectErrors controls whether WalkDir visits all files of a
+// directory even if some of them cannot be parsed or printed, returning
+// the errors joined by errors.Join, or stops at the first error. Errors
+// are collected by default.
+func (f *testFuncVisitor) SetCollectErrors(collect bool) {
+	f.failFast = !collect
+}
+
+func (f testFuncVisitor) collectsErrors() bool {
+	return !f.failFast
+}
+
+// collectsErrors reports whether WalkDir should collect the errors of
+// visitor, which it does unless the visitor opts out
+func collectsErrors(visitor ast.Visitor) bool {
+	collector, ok := visitor.(errorCollector)
+	return !ok || collector.collectsErrors()
+}
+
+// fileError prefixes err with path, unless it already names the file like
+// the errors of reading or parsing it
+func fileError(path string, err error) error {
+	switch err.(type) {
+	case nil, scanner.ErrorList, *os.PathError:
+		return err
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
+
+func setFileSet(visitor ast.Visitor, fileSet *token.FileSet) {
+	if setter, ok := visitor.(fileSetter); ok {
+		setter.SetFileSet(fileSet)
+	}
+}
+
+// TestFuncVisitor is an ast.Visitor which calls a FuncVisitAction on every
+// test function declaration it visits
+type TestFuncVisitor interface {
+	ast.Visitor
+	// SetTestImport sets the name of the testing package
+	SetTestImport(testImport string)
+	// SetParamType sets the exact testing parameter type to match
+	SetParamType(paramType string)
+	// SetStrict controls whether test functions with trailing parameters
+	// are matched
+	SetStrict(strict bool)
+	// SetSkipHelpers controls whether functions calling t.Helper() are
+	// matched
+	SetSkipHelpers(skipHelpers bool)
+	// AddFilter adds a filter every visited test function must pass
+	AddFilter(filter FuncFilter)
+	// AddFileFilter adds a filter every visited file must pass
+	AddFileFilter(filter FileFilter)
+	// AddPathFilter adds a filter the path of every file parsed by WalkDir
+	// must pass
+	AddPathFilter(filter PathFilter)
+	// SetFileSet sets the token.FileSet of the visited nodes
+	SetFileSet(fileSet *token.FileSet)
+	// SetReport sets the report the visited test functions are added to
+	SetReport(report *Report)
+	// SetSurgical controls whether only modified functions are re-printed
+	SetSurgical(surgical bool)
+	// SetSuite makes the visitor match the test methods of a suite type
+	SetSuite(suiteType string)
+	// SetPrefixes sets the name prefixes of the matched test functions
+	SetPrefixes(prefixes []string)
+	// SetTestMain makes the visitor match the TestMain function instead
+	SetTestMain(testMain bool)
+	// SetFuzz controls whether fuzz targets are matched as well
+	SetFuzz(fuzz bool)
+	// SetBenchmarks controls whether benchmarks are matched as well
+	SetBenchmarks(benchmarks bool)
+	// SetBenchmarkPrefix sets the name prefix of the matched benchmarks
+	SetBenchmarkPrefix(prefix string)
+	// AddSkipCall adds a helper whose calls are reported as skips
+	AddSkipCall(call SkipCall)
+	// SetUnskipNote sets the date of the note added to unskipped functions
+	SetUnskipNote(date time.Time)
+	// SetMetrics sets the Metrics counters are incremented on
+	SetMetrics(metrics Metrics)
+	// SetCollectErrors controls whether WalkDir continues after errors
+	SetCollectErrors(collect bool)
+	// SetConcurrency sets the number of workers of WalkDir
+	SetConcurrency(workers int)
+}
+
 // NewTestFuncVisitor returns an ast.Visitor which performs the action
 // specified in visitAction
 //
 // The visitor will only call the visitAction on test function declarations
-func NewTestFuncVisitor(visitAction FuncVisitAction) ast.Visitor {
+func NewTestFuncVisitor(visitAction FuncVisitAction) TestFuncVisitor {
 	return &testFuncVisitor{
 		visitAction: visitAction,
 		testImport:  defaultTestImport,
+		refs:        make(map[*ast.File]map[string]int),
+		emptied:     make(map[*ast.File][]int),
+		modified:    make(map[*ast.File]bool),
+		metrics:     NopMetrics,
 	}
 }
 
-const skipTestStatementTemplate = "%s.Skip()"
-
 // SkipTestVisitorAction defines a visitAction which adds a
-//  t.Skip()
+//
+//	t.Skip()
+//
 // statement to the test function
 //
 // It is garanteed that the *ast.FuncDecl is a testing function with the
 // signature func TestXXX(*testing.T)
 func SkipTestVisitorAction(f *ast.FuncDecl) {
-	testingParamName := f.Type.Params.List[0].Names[0].Name
-	skipTestString := fmt.Sprintf(skipTestStatementTemplate, testingParamName)
-	skipTestExpr, err := parser.ParseExpr(skipTestString)
-	if err != nil {
+	if err := ApplySkip(nil, f, SkipOptions{}); err != nil {
 		panic(err)
 	}
-	newBodyList := make([]ast.Stmt, len(f.Body.List)+1)
-	newBodyList[0] = &ast.ExprStmt{X: skipTestExpr}
-	for i, stmt := range f.Body.List {
-		newBodyList[i+1] = stmt
+}
+
+// SkipBenchmarkVisitorAction defines a visitAction which adds a
+//
+//	b.Skip()
+//
+// statement to the benchmark function. An unnamed benchmark parameter is
+// named b.
+//
+// It is garanteed that the *ast.FuncDecl is a benchmark function with the
+// signature func BenchmarkXXX(*testing.B)
+func SkipBenchmarkVisitorAction(f *ast.FuncDecl) {
+	if err := ApplySkip(nil, f, SkipOptions{}); err != nil {
+		panic(err)
+	}
+}
+
+// SkipFuzzVisitorAction defines a visitAction which adds a
+//
+//	f.Skip()
+//
+// statement to the fuzz target. An unnamed fuzz parameter is named f. The
+// visitor has to match fuzz targets, see SetFuzz.
+//
+// It is garanteed that the *ast.FuncDecl is a fuzz target with the
+// signature func FuzzXXX(*testing.F)
+func SkipFuzzVisitorAction(f *ast.FuncDecl) {
+	if err := ApplySkip(nil, f, SkipOptions{}); err != nil {
+		panic(err)
+	}
+}
+
+// SkipTestVisitorActionWithReason returns a visitAction which adds a
+//
+//	t.Skip("reason")
+//
+// statement to the test function. If reason is empty a bare t.Skip() is
+// added.
+func SkipTestVisitorActionWithReason(reason string) FuncVisitAction {
+	return func(f *ast.FuncDecl) {
+		if err := ApplySkip(nil, f, SkipOptions{Reason: reason}); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// SkipTestVisitorActionWithOptions returns a visitAction which adds the
+// skip statement described by opts to the test function, see ApplySkip
+func SkipTestVisitorActionWithOptions(opts SkipOptions) FuncVisitAction {
+	return func(f *ast.FuncDecl) {
+		if err := ApplySkip(nil, f, opts); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// SkipAfterCleanupVisitorAction defines a visitAction which adds a
+//
+//	t.Skip()
+//
+// statement to the test function after any leading t.Cleanup calls, so
+// that the cleanups are registered even though the test is skipped
+func SkipAfterCleanupVisitorAction(f *ast.FuncDecl) {
+	if err := ApplySkip(nil, f, SkipOptions{AfterCleanup: true}); err != nil {
+		panic(err)
+	}
+}
+
+// SkipRemovingParallelVisitorAction defines a visitAction which adds a
+//
+//	t.Skip()
+//
+// statement to the test function and removes its t.Parallel() statements.
+// Unskipping the test does not restore them.
+func SkipRemovingParallelVisitorAction(f *ast.FuncDecl) {
+	if err := ApplySkip(nil, f, SkipOptions{RemoveParallel: true}); err != nil {
+		panic(err)
+	}
+}
+
+// SkipInShortModeVisitorAction returns a visitAction which adds a
+//
+//	if testing.Short() {
+//		t.Skip("reason")
+//	}
+//
+// statement to the test function, so that it only gets skipped when the
+// tests are run with -short. The testing package qualifier is taken from
+// the type of the testing parameter.
+func SkipInShortModeVisitorAction(reason string) FuncVisitAction {
+	return func(f *ast.FuncDecl) {
+		if err := ApplySkip(nil, f, SkipOptions{Reason: reason, ShortMode: true}); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// SkipIfEnvVisitorAction returns a visitAction which adds a
+//
+//	if os.Getenv("envVar") != "" {
+//		t.Skip()
+//	}
+//
+// statement to the test function, so that it only gets skipped if the
+// environment variable is set. Run by a TestFuncVisitor, the import of os
+// is added to the file if missing.
+func SkipIfEnvVisitorAction(envVar string) FuncVisitAction {
+	return func(f *ast.FuncDecl) {
+		if err := ApplySkip(nil, f, SkipOptions{EnvVar: envVar}); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// UnskipIfEnvVisitorAction returns a visitAction which removes the statement
+// added by SkipIfEnvVisitorAction for envVar from the test function, see
+// ApplyUnskipEnvGuard
+func UnskipIfEnvVisitorAction(envVar string) FuncVisitAction {
+	return func(f *ast.FuncDecl) {
+		if err := ApplyUnskipEnvGuard(nil, f, envVar); err != nil {
+			panic(err)
+		}
 	}
-	f.Body.List = newBodyList
 }
 
 // UnSkipTestVisitorAction defines a visitAction which removes a
-//  t.Skip()
-// statement from the test function if given at first line of the func body
+//
+//	t.Skip()
+//
+// statement from the test function if given at first line of the func body.
+// When called by a TestFuncVisitor walking a file, comments belonging to the
+// removed statement are removed from the file as well.
 //
 // It is garanteed that the *ast.FuncDecl is a testing function with the
 // signature func TestXXX(*testing.T)
 func UnskipTestVisitorAction(f *ast.FuncDecl) {
-	testingParamName := f.Type.Params.List[0].Names[0].Name
-	skipTestString := fmt.Sprintf(skipTestStatementTemplate, testingParamName)
-	var buffer bytes.Buffer
-	printer.Fprint(&buffer, token.NewFileSet(), f.Body.List[0])
-	if buffer.String() == skipTestString {
-		newBodyList := make([]ast.Stmt, len(f.Body.List)-1)
-		for i, _ := range newBodyList {
-			newBodyList[i] = f.Body.List[i+1]
+	if err := ApplyUnskip(nil, f); err != nil {
+		panic(err)
+	}
+}
+
+// UnskipTestVisitorActionWithSkipCalls returns a visitAction which removes a
+// leading t.Skip() statement or call of any of the given skip helpers from
+// the test function, see ApplyUnskip
+func UnskipTestVisitorActionWithSkipCalls(calls ...SkipCall) FuncVisitAction {
+	return func(f *ast.FuncDecl) {
+		if err := ApplyUnskip(nil, f, calls...); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// UnskipTestVisitorActionWithOptions returns a visitAction which removes the
+// skip statement described by opts from the test function, see
+// ApplyUnskipWithOptions
+func UnskipTestVisitorActionWithOptions(opts UnskipOptions) FuncVisitAction {
+	return func(f *ast.FuncDecl) {
+		if err := ApplyUnskipWithOptions(nil, f, opts); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// UnskipAllTestVisitorAction defines a visitAction which removes all
+// leading skip statements from the test function, see ApplyUnskipAll
+func UnskipAllTestVisitorAction(f *ast.FuncDecl) {
+	if err := ApplyUnskipAll(nil, f); err != nil {
+		panic(err)
+	}
+}
+
+// UnskipAllTestVisitorActionWithSkipCalls returns a visitAction which
+// removes all leading skip statements and calls of any of the given skip
+// helpers from the test function, see ApplyUnskipAll
+func UnskipAllTestVisitorActionWithSkipCalls(calls ...SkipCall) FuncVisitAction {
+	return func(f *ast.FuncDecl) {
+		if err := ApplyUnskipAll(nil, f, calls...); err != nil {
+			panic(err)
 		}
-		f.Body.List = newBodyList
 	}
 }
 
-// PathWriter provides a mapping of paths to buffers
+// PathWriter provides a mapping of paths to buffers. It is not safe for
+// concurrent use, see SyncPathWriter.
 type PathWriter map[string]io.ReadWriter
 
 // ReadWriterForPath returns an io.ReadWriter for the provided path
@@ -131,43 +973,254 @@ func (p PathWriter) ReadWriterForPath(path string) io.ReadWriter {
 	return &writer
 }
 
-func onlyTestFileAndDirFilter(info os.FileInfo) bool {
-	if info.IsDir() {
-		return false
+// Paths returns the paths of p in sorted order, so that iterating over them
+// emits the files in the same order on every run
+func (p PathWriter) Paths() []string {
+	paths := make([]string, 0, len(p))
+	for path := range p {
+		paths = append(paths, path)
 	}
-	if strings.HasSuffix(info.Name(), "_test") {
-		return false
-	}
-	return true
+	sort.Strings(paths)
+	return paths
+}
+
+// onlyTestFileAndDirFilter accepts the test files of a directory, i.e.
+// files whose name ends in _test.go
+func onlyTestFileAndDirFilter(info os.FileInfo) bool {
+	return !info.IsDir() && strings.HasSuffix(info.Name(), "_test.go")
 }
 
 // WalkDir applies the visitor to all files found at path and writes the visited
-// AST into pathWriter.
-func WalkDir(path string, pathWriter PathWriter, visitor ast.Visitor) error {
-	fileSet := token.NewFileSet()
-	packages, err := parser.ParseDir(fileSet, path, onlyTestFileAndDirFilter, parser.ParseComments)
-	if err != nil {
+// AST into pathWriter. Files rejected by a PathFilter of the visitor are not
+// parsed at all. The files may belong to different packages, like loose test
+// scripts. Files which cannot be parsed or printed do not stop the walk,
+// their errors, naming the file, are returned joined after all other files
+// were visited. If the visitor does not collect errors, the walk stops at
+// the first broken file instead.
+//
+// The files are parsed and printed by a pool of workers, see
+// SetConcurrency, while the visitor visits them one after the other in the
+// order of their names. Thus neither the visitor nor pathWriter need to be
+// safe for concurrent use, and the outcome does not depend on the number of
+// workers. Walks sharing a pathWriter concurrently need a SyncPathWriter.
+func WalkDir(path string, pathWriter ReadWriterProvider, visitor ast.Visitor) error {
+	return WalkDirContext(context.Background(), path, pathWriter, visitor)
+}
+
+// WalkDirContext is like WalkDir, but stops visiting further files once ctx
+// is done and returns the error of ctx. The files visited until then are
+// still written into pathWriter, so that it only holds completely processed
+// files, matching the changes the visitor reported.
+func WalkDirContext(ctx context.Context, path string, pathWriter ReadWriterProvider, visitor ast.Visitor) error {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-	for _, pkg := range packages {
-		for path, file := range pkg.Files {
-			writer := pathWriter.ReadWriterForPath(path)
-			ast.Walk(visitor, file)
-			printer.Fprint(writer, fileSet, file)
+	infos, err := ioutil.ReadDir(path)
+	if err != nil {
+		return countFile(visitor, err)
+	}
+	acceptor, filtersPaths := visitor.(pathAcceptor)
+	var paths []string
+	for _, info := range infos {
+		filePath := filepath.Join(path, info.Name())
+		if onlyTestFileAndDirFilter(info) && (!filtersPaths || acceptor.acceptPath(filePath)) {
+			paths = append(paths, filePath)
 		}
 	}
-	return nil
+	workers := concurrencyOf(visitor)
+	fileSet := token.NewFileSet()
+	files := make([]*ast.File, len(paths))
+	errs := make([]error, len(paths))
+	forEachParallel(len(paths), workers, func(i int) {
+		if ctx.Err() == nil {
+			files[i], errs[i] = parser.ParseFile(fileSet, paths[i], nil, parser.ParseComments)
+		}
+	})
+	setFileSet(visitor, fileSet)
+	// visited is the number of files whose results, contents or errors,
+	// are emitted
+	visited := len(paths)
+	cancelErr := ctx.Err()
+	for i, file := range files {
+		if cancelErr = ctx.Err(); cancelErr != nil {
+			visited = i
+			break
+		}
+		if errs[i] == nil {
+			errs[i] = walkFile(visitor, file)
+		}
+		if errs[i] != nil {
+			if !collectsErrors(visitor) {
+				visited = i + 1
+				break
+			}
+			continue
+		}
+		finishFile(visitor, file)
+	}
+	contents := make([][]byte, visited)
+	forEachParallel(visited, workers, func(i int) {
+		if errs[i] == nil {
+			contents[i], errs[i] = renderFile(paths[i], nil, fileSet, files[i], visitor)
+		}
+	})
+	var walkErrs []error
+	for i := 0; i < visited; i++ {
+		if errs[i] == nil {
+			errs[i] = emitFile(pathWriter.ReadWriterForPath(paths[i]), paths[i], contents[i], visitor)
+		}
+		if err := countFile(visitor, fileError(paths[i], errs[i])); err != nil {
+			if !collectsErrors(visitor) {
+				return err
+			}
+			walkErrs = append(walkErrs, err)
+		}
+	}
+	if cancelErr != nil {
+		return errors.Join(append([]error{cancelErr}, walkErrs...)...)
+	}
+	return errors.Join(walkErrs...)
 }
 
 // WalkFile applies the visitor to the file found at path and writes the visited
 // AST into output.
 func WalkFile(path string, output io.Writer, visitor ast.Visitor) error {
+	return walkSource(path, nil, output, visitor)
+}
+
+// WalkReader applies the visitor to the source read from r and writes the
+// visited AST into output. The name is used as file name in positions and
+// error messages, e.g. "<standard input>".
+func WalkReader(r io.Reader, name string, output io.Writer, visitor ast.Visitor) error {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return countFile(visitor, err)
+	}
+	return walkSource(name, src, output, visitor)
+}
+
+// Transform parses src, calls action on its test functions and returns the
+// rewritten source, formatted like gofmt does, without accessing the file
+// system. Comments are preserved. Source without matching test functions
+// is returned unchanged. If src cannot be parsed, the parse error is
+// returned and no source at all.
+func Transform(src []byte, action FuncVisitAction) ([]byte, error) {
+	if src == nil {
+		src = []byte{}
+	}
+	return transformSource(transformName, src, NewTestFuncVisitor(action))
+}
+
+// transformName is the file name of the source passed to Transform. The
+// printer places comments following an inserted statement on its line
+// for an unnamed file.
+const transformName = "<source>"
+
+// walkSource applies the visitor to the source src of the file at path and
+// writes the visited AST into output. If src is nil, the source is read
+// from path.
+func walkSource(path string, src []byte, output io.Writer, visitor ast.Visitor) error {
+	content, err := transformSource(path, src, visitor)
+	if err != nil {
+		return countFile(visitor, err)
+	}
+	return countFile(visitor, fileError(path, emitFile(output, path, content, visitor)))
+}
+
+// transformSource applies the visitor to the source src of the file at path
+// and returns the rendered result, see renderFile. If src is nil, the
+// source is read from path.
+func transformSource(path string, src []byte, visitor ast.Visitor) ([]byte, error) {
+	var source interface{}
+	if src != nil {
+		source = src
+	}
 	fileSet := token.NewFileSet()
-	file, err := parser.ParseFile(fileSet, path, nil, parser.ParseComments)
+	file, err := parser.ParseFile(fileSet, path, source, parser.ParseComments)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	setFileSet(visitor, fileSet)
+	if err := walkFile(visitor, file); err != nil {
+		return nil, fileError(path, err)
+	}
+	finishFile(visitor, file)
+	content, err := renderFile(path, src, fileSet, file, visitor)
+	if err != nil {
+		return nil, fileError(path, err)
 	}
+	return content, nil
+}
+
+// walkFile applies the visitor to file. A panic of the visitAction, like
+// the ones of the predefined actions for errors of ApplySkip, is recovered
+// and returned as error, so that a single file cannot crash the process.
+func walkFile(visitor ast.Visitor, file *ast.File) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if recovered, ok := r.(error); ok {
+				err = recovered
+			} else {
+				err = fmt.Errorf("visit action panicked: %v", r)
+			}
+		}
+	}()
 	ast.Walk(visitor, file)
-	printer.Fprint(output, fileSet, file)
 	return nil
 }
+
+// changeTracker is implemented by visitors which know whether their actions
+// modified a file
+type changeTracker interface {
+	modifiedFile(file *ast.File) bool
+}
+
+// modifiedFile reports whether the visitAction changed any function of
+// file. Without tracking, every file counts as modified.
+func (f testFuncVisitor) modifiedFile(file *ast.File) bool {
+	return f.modified == nil || f.modified[file]
+}
+
+// finishFile lets visitor post-process file after it visited all of its
+// functions
+func finishFile(visitor ast.Visitor, file *ast.File) {
+	if finisher, ok := visitor.(fileFinisher); ok {
+		finisher.finishFile(file)
+	}
+}
+
+// renderFile returns the source of the visited file, formatted like gofmt
+// does. A file the visitor did not modify is returned as its original
+// source instead, so that it is not reformatted and compares equal to its
+// source. If visitor performs surgical edits, only the modified functions
+// are printed into the original source src, which is read from path if nil.
+// renderFile does not modify visitor and may be called concurrently for
+// different files.
+func renderFile(path string, src []byte, fileSet *token.FileSet, file *ast.File, visitor ast.Visitor) ([]byte, error) {
+	if tracker, ok := visitor.(changeTracker); ok && !tracker.modifiedFile(file) {
+		if src == nil {
+			return ioutil.ReadFile(path)
+		}
+		return src, nil
+	}
+	var buffer bytes.Buffer
+	if editor, ok := visitor.(surgicalEditor); ok && editor.changedDecls() != nil {
+		if err := printSurgical(&buffer, path, src, fileSet, file, editor.changedDecls()); err != nil {
+			return nil, err
+		}
+	} else if err := format.Node(&buffer, fileSet, file); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// emitFile writes the rendered content of the file at path to output. If
+// visitor holds a Report, the positions of the statements inserted into the
+// reported functions are resolved within content.
+func emitFile(output io.Writer, path string, content []byte, visitor ast.Visitor) error {
+	if holder, ok := visitor.(reportHolder); ok && holder.currentReport() != nil {
+		holder.currentReport().resolveInserted(path, content)
+	}
+	_, err := output.Write(content)
+	return err
+}
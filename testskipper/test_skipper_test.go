@@ -2,13 +2,19 @@ package testskipper
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"go/ast"
+	"go/build"
+	"go/format"
 	"go/parser"
 	"go/printer"
 	"go/token"
 	"io/ioutil"
 	"os"
 	"path"
+	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 )
@@ -80,6 +86,707 @@ func TestTestFuncVisitorSetTestImport(t *testing.T) {
 	}
 }
 
+func TestTestFuncVisitorResolvesTestingImport(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected []string
+	}{
+		{
+			`package main
+
+			import . "testing"
+
+			func TestFoo(t *T) {}
+			func TestBar(t *testing.T) {}
+			func BenchmarkFoo(b *B) {}
+			`,
+			[]string{"TestFoo", "BenchmarkFoo"},
+		},
+		{
+			`package main
+
+			import tst "testing"
+
+			func TestFoo(t *tst.T) {}
+			func TestBar(t *testing.T) {}
+			func TestBaz(t *T) {}
+			func BenchmarkFoo(b *tst.B) {}
+			`,
+			[]string{"TestFoo", "BenchmarkFoo"},
+		},
+		{
+			`package main
+
+			import "testing"
+
+			func TestFoo(t * testing.T) {}
+			func TestBar(t *(testing.T)) {}
+			func TestBaz(t *T) {}
+			`,
+			[]string{"TestFoo", "TestBar"},
+		},
+	}
+
+	for _, test := range tests {
+		file, err := parser.ParseFile(token.NewFileSet(), "", test.src, parser.AllErrors)
+		if err != nil {
+			t.Fatalf("Error parsing source code: `%s`", test.src)
+		}
+		var actual []string
+		visitAction := func(f *ast.FuncDecl) {
+			actual = append(actual, f.Name.Name)
+		}
+		visitor := NewTestFuncVisitor(visitAction)
+		visitor.SetBenchmarks(true)
+
+		ast.Walk(visitor, file)
+
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Fatalf("Expected %v to match in \n`%s`\n, got %v\n", test.expected, test.src, actual)
+		}
+	}
+}
+
+func TestSkipTestVisitorActionDotImport(t *testing.T) {
+	src := `package main
+
+import . "testing"
+
+func TestFoo(*T) {}
+`
+	expected := `package main
+
+import . "testing"
+
+// gotestskipper:skip
+func TestFoo(t *T) {
+	t.Skip()
+
+}
+`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Error parsing source code: `%s`", src)
+	}
+	visitor := NewTestFuncVisitor(SkipTestVisitorAction)
+	ast.Walk(visitor, file)
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+
+	actual := buffer.String()
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	if replacer.Replace(actual) != replacer.Replace(expected) {
+		t.Fatalf("Expected \n`%s`\n, got \n`%s`\n", expected, actual)
+	}
+	assertOnlySkipChanged(t, strings.Replace(src, "(*T)", "(t *T)", 1), actual)
+}
+
+func TestTestFuncVisitorSetBenchmarks(t *testing.T) {
+	src := `
+		package main
+
+		import foobar "testing"
+
+		func TestFoo(t *foobar.T) {}
+		func BenchmarkFoo(b *foobar.B) {}
+		func Benchmarkfoo(b *foobar.B) {}
+		func BenchmarkBar(b *foobar.T) {}
+		func PerfBaz(b *foobar.B) {}
+	`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.AllErrors)
+	if err != nil {
+		t.Fatalf("Error parsing source code: `%s`", src)
+	}
+
+	tests := []struct {
+		benchmarks bool
+		prefix     string
+		expected   []string
+	}{
+		{false, "", []string{"TestFoo"}},
+		{true, "", []string{"TestFoo", "BenchmarkFoo"}},
+		{true, "Perf", []string{"TestFoo", "PerfBaz"}},
+	}
+
+	for _, test := range tests {
+		var actual []string
+		visitAction := func(f *ast.FuncDecl) {
+			actual = append(actual, f.Name.Name)
+		}
+		visitor := NewTestFuncVisitor(visitAction)
+		visitor.SetTestImport("foobar")
+		visitor.SetBenchmarks(test.benchmarks)
+		visitor.SetBenchmarkPrefix(test.prefix)
+
+		ast.Walk(visitor, file)
+
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Fatalf("Expected %v for benchmarks %t and prefix '%s', got %v\n", test.expected, test.benchmarks, test.prefix, actual)
+		}
+	}
+}
+
+func TestTestFuncVisitorSetPrefixes(t *testing.T) {
+	src := `
+		package main
+
+		import "testing"
+
+		func TestFoo(t *testing.T) {}
+		func Test(t *testing.T) {}
+		func TesticularCancer(t *testing.T) {}
+		func IntegrationTestFoo(t *testing.T) {}
+		func IntegrationTest_bar(t *testing.T) {}
+		func IntegrationTestfoo(t *testing.T) {}
+		func AcceptanceTestFoo(t *testing.T) {}
+		func AcceptanceTestBar(b *testing.B) {}
+	`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.AllErrors)
+	if err != nil {
+		t.Fatalf("Error parsing source code: `%s`", src)
+	}
+
+	tests := []struct {
+		prefixes []string
+		expected []string
+	}{
+		{nil, []string{"TestFoo", "Test"}},
+		{[]string{"IntegrationTest"}, []string{"IntegrationTestFoo", "IntegrationTest_bar"}},
+		{[]string{"Test", "IntegrationTest", "AcceptanceTest"}, []string{"TestFoo", "Test", "IntegrationTestFoo", "IntegrationTest_bar", "AcceptanceTestFoo"}},
+	}
+
+	for _, test := range tests {
+		var actual []string
+		visitAction := func(f *ast.FuncDecl) {
+			actual = append(actual, f.Name.Name)
+		}
+		visitor := NewTestFuncVisitor(visitAction)
+		visitor.SetPrefixes(test.prefixes)
+
+		ast.Walk(visitor, file)
+
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Fatalf("Expected %v for prefixes %v, got %v\n", test.expected, test.prefixes, actual)
+		}
+	}
+}
+
+func TestTestFuncVisitorSetFuzz(t *testing.T) {
+	src := `
+		package main
+
+		import "testing"
+
+		func TestFoo(t *testing.T) {}
+		func BenchmarkFoo(b *testing.B) {}
+		func FuzzFoo(f *testing.F) {}
+		func Fuzzfoo(f *testing.F) {}
+		func FuzzBar(f *testing.T) {}
+		func FuzzBaz(f *testing.F, s string) {}
+	`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.AllErrors)
+	if err != nil {
+		t.Fatalf("Error parsing source code: `%s`", src)
+	}
+
+	tests := []struct {
+		fuzz       bool
+		benchmarks bool
+		expected   []string
+	}{
+		{false, false, []string{"TestFoo"}},
+		{true, false, []string{"TestFoo", "FuzzFoo"}},
+		{true, true, []string{"TestFoo", "BenchmarkFoo", "FuzzFoo"}},
+	}
+
+	for _, test := range tests {
+		var actual []string
+		visitAction := func(f *ast.FuncDecl) {
+			actual = append(actual, f.Name.Name)
+		}
+		visitor := NewTestFuncVisitor(visitAction)
+		visitor.SetFuzz(test.fuzz)
+		visitor.SetBenchmarks(test.benchmarks)
+		visitor.SetStrict(false)
+
+		ast.Walk(visitor, file)
+
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Fatalf("Expected %v for fuzz %t and benchmarks %t, got %v\n", test.expected, test.fuzz, test.benchmarks, actual)
+		}
+	}
+}
+
+func TestTestFuncVisitorSetParamType(t *testing.T) {
+	src := `
+		package main
+
+		import (
+			"testing"
+
+			qt "github.com/frankban/quicktest"
+		)
+
+		func TestFoo(t *testing.T) {}
+		func TestBar(c *qt.C) {}
+		func TestBaz(t *qt.T) {}
+	`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.AllErrors)
+	if err != nil {
+		t.Fatalf("Error parsing source code: `%s`", src)
+	}
+	var names []string
+	visitAction := func(f *ast.FuncDecl) {
+		names = append(names, f.Name.Name)
+	}
+
+	visitor := NewTestFuncVisitor(visitAction)
+	visitor.SetParamType("* qt.T")
+	ast.Walk(visitor, file)
+
+	if len(names) != 1 || names[0] != "TestBaz" {
+		t.Fatalf("Expected only 'TestBaz' to match, got %v\n", names)
+	}
+
+	// Reset to default detection
+	names = nil
+	visitor.SetParamType("")
+	ast.Walk(visitor, file)
+
+	if len(names) != 1 || names[0] != "TestFoo" {
+		t.Fatalf("Expected only 'TestFoo' to match, got %v\n", names)
+	}
+}
+
+func TestTestFuncVisitorSetStrict(t *testing.T) {
+	src := `
+		package main
+
+		import (
+			"context"
+			"testing"
+		)
+
+		func TestFoo(t *testing.T, ctx context.Context) {}
+		func TestBar(ctx context.Context, t *testing.T) {}
+	`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.AllErrors)
+	if err != nil {
+		t.Fatalf("Error parsing source code: `%s`", src)
+	}
+	var names []string
+	visitAction := func(f *ast.FuncDecl) {
+		names = append(names, f.Name.Name)
+	}
+
+	// Strict
+	visitor := NewTestFuncVisitor(visitAction)
+	ast.Walk(visitor, file)
+
+	if len(names) != 0 {
+		t.Fatalf("Expected no matches in strict mode, got %v\n", names)
+	}
+
+	// Relaxed
+	visitor.SetStrict(false)
+	ast.Walk(visitor, file)
+
+	if len(names) != 1 || names[0] != "TestFoo" {
+		t.Fatalf("Expected only 'TestFoo' to match, got %v\n", names)
+	}
+
+	// The skip targets the testing parameter
+	var funcDecl *ast.FuncDecl
+	for _, decl := range file.Decls {
+		if fDecl, ok := decl.(*ast.FuncDecl); ok && fDecl.Name.Name == "TestFoo" {
+			funcDecl = fDecl
+		}
+	}
+	SkipTestVisitorAction(funcDecl)
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, token.NewFileSet(), funcDecl.Body.List[0])
+
+	expected := "t.Skip()"
+	if buffer.String() != expected {
+		t.Fatalf("Expected '%s', got '%s'\n", expected, buffer.String())
+	}
+}
+
+func TestTestFuncVisitorAddFilter(t *testing.T) {
+	src := `
+		package main
+
+		import "testing"
+
+		func TestFoo(t *testing.T) {}
+		func TestBar(t *testing.T) {}
+	`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.AllErrors)
+	if err != nil {
+		t.Fatalf("Error parsing source code: `%s`", src)
+	}
+	var names []string
+	visitAction := func(f *ast.FuncDecl) {
+		names = append(names, f.Name.Name)
+	}
+
+	visitor := NewTestFuncVisitor(visitAction)
+	visitor.SetFileSet(fileSet)
+	visitor.AddFilter(func(fs *token.FileSet, f *ast.FuncDecl) bool {
+		if fs != fileSet {
+			t.Fatal("Expected filter to receive the file set")
+		}
+		return fs.Position(f.Pos()).Line == 7
+	})
+	ast.Walk(visitor, file)
+
+	if len(names) != 1 || names[0] != "TestBar" {
+		t.Fatalf("Expected only 'TestBar' to match, got %v\n", names)
+	}
+}
+
+func TestTestFuncVisitorSetSkipHelpers(t *testing.T) {
+	src := `
+		package main
+
+		import "testing"
+
+		func TestFoo(t *testing.T) {
+			t.Log("foo")
+		}
+
+		func TestHelper(t *testing.T) {
+			t.Helper()
+			t.Log("helper")
+		}
+
+		func TestNestedHelper(t *testing.T) {
+			func() {
+				t.Helper()
+			}()
+		}
+	`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.AllErrors)
+	if err != nil {
+		t.Fatalf("Error parsing source code: `%s`", src)
+	}
+	var names []string
+	visitAction := func(f *ast.FuncDecl) {
+		names = append(names, f.Name.Name)
+	}
+
+	// Helpers are excluded by default
+	visitor := NewTestFuncVisitor(visitAction)
+	ast.Walk(visitor, file)
+
+	expected := "TestFoo,TestNestedHelper"
+	if strings.Join(names, ",") != expected {
+		t.Fatalf("Expected '%s' to match, got %v\n", expected, names)
+	}
+
+	names = nil
+	visitor.SetSkipHelpers(true)
+	ast.Walk(visitor, file)
+
+	expected = "TestFoo,TestHelper,TestNestedHelper"
+	if strings.Join(names, ",") != expected {
+		t.Fatalf("Expected '%s' to match, got %v\n", expected, names)
+	}
+}
+
+func TestTestFuncVisitorAddFileFilterHasTestMain(t *testing.T) {
+	srcs := []string{`
+		package main
+
+		import (
+			"os"
+			"testing"
+		)
+
+		func TestMain(m *testing.M) {
+			os.Exit(m.Run())
+		}
+
+		func TestFoo(t *testing.T) {}
+		func TestBar(t *testing.T) {}
+	`, `
+		package main
+
+		import "testing"
+
+		func TestBaz(t *testing.T) {}
+	`}
+	var names []string
+	visitAction := func(f *ast.FuncDecl) {
+		names = append(names, f.Name.Name)
+	}
+	visitor := NewTestFuncVisitor(visitAction)
+	visitor.AddFileFilter(HasTestMain)
+
+	for _, src := range srcs {
+		file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.AllErrors)
+		if err != nil {
+			t.Fatalf("Error parsing source code: `%s`", src)
+		}
+		ast.Walk(visitor, file)
+	}
+
+	expected := "TestFoo,TestBar"
+	if strings.Join(names, ",") != expected {
+		t.Fatalf("Expected '%s' to match, got %v\n", expected, names)
+	}
+}
+
+func TestTestFuncVisitorAddFileFilterImportsAny(t *testing.T) {
+	srcs := []string{`
+		package main
+
+		import (
+			"database/sql"
+			"testing"
+		)
+
+		func TestFoo(t *testing.T) {}
+	`, `
+		package main
+
+		import (
+			h "net/http"
+			"testing"
+		)
+
+		func TestBar(t *testing.T) {}
+	`, `
+		package main
+
+		import (
+			"net/http/httptest"
+			"testing"
+		)
+
+		func TestBaz(t *testing.T) {}
+	`, `
+		package main
+
+		import "testing"
+
+		func TestQux(t *testing.T) {}
+	`}
+	var names []string
+	visitAction := func(f *ast.FuncDecl) {
+		names = append(names, f.Name.Name)
+	}
+	visitor := NewTestFuncVisitor(visitAction)
+	visitor.AddFileFilter(ImportsAny("database/sql", "net/http"))
+
+	for _, src := range srcs {
+		file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.AllErrors)
+		if err != nil {
+			t.Fatalf("Error parsing source code: `%s`", src)
+		}
+		ast.Walk(visitor, file)
+	}
+
+	expected := "TestFoo,TestBar"
+	if strings.Join(names, ",") != expected {
+		t.Fatalf("Expected '%s' to match, got %v\n", expected, names)
+	}
+}
+
+func TestTestFuncVisitorAddFileFilterHasBuildTag(t *testing.T) {
+	srcs := []string{
+		"//go:build integration\n\npackage main\n\nfunc TestFoo(t *testing.T) {}\n",
+		"// +build integration,linux\n\npackage main\n\nfunc TestBar(t *testing.T) {}\n",
+		"// Copyright\n\n//go:build e2e || (linux && !integration)\n// +build e2e linux,!integration\n\npackage main\n\nfunc TestBaz(t *testing.T) {}\n",
+		"//go:build !integration\n\npackage main\n\nfunc TestQux(t *testing.T) {}\n",
+		"//go:build linux\n\npackage main\n\nfunc TestQuux(t *testing.T) {}\n",
+		"package main\n\n//go:build integration\n\nfunc TestCorge(t *testing.T) {}\n",
+	}
+	var names []string
+	visitAction := func(f *ast.FuncDecl) {
+		names = append(names, f.Name.Name)
+	}
+	visitor := NewTestFuncVisitor(visitAction)
+	visitor.AddFileFilter(HasBuildTag("integration", "e2e"))
+
+	for _, src := range srcs {
+		file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("Error parsing source code: `%s`", src)
+		}
+		ast.Walk(visitor, file)
+	}
+
+	expected := "TestFoo,TestBar,TestBaz"
+	if strings.Join(names, ",") != expected {
+		t.Fatalf("Expected '%s' to match, got %v\n", expected, names)
+	}
+}
+
+func TestTestFuncVisitorAddFilterIsStub(t *testing.T) {
+	src := `
+	package main
+
+	import "testing"
+
+	func TestFoo(t *testing.T) {}
+
+	func TestBar(t *testing.T) {
+		t.Log("bar")
+	}
+
+	func TestBaz(t *testing.T) {
+		// TODO
+	}`
+
+	expected := `
+	package main
+
+	import "testing"
+
+	// gotestskipper:skip
+	func TestFoo(t *testing.T) { t.Skip("not implemented") }
+
+	func TestBar(t *testing.T) {
+		t.Log("bar")
+	}
+
+	// gotestskipper:skip
+	func TestBaz(t *testing.T) {
+		t.Skip("not implemented")
+		// TODO
+	}`
+
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Error parsing source code: `%s`", src)
+	}
+	visitor := NewTestFuncVisitor(SkipTestVisitorActionWithReason("not implemented"))
+	visitor.AddFilter(IsStub)
+	ast.Walk(visitor, file)
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	if replacer.Replace(expected) != replacer.Replace(buffer.String()) {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, buffer.String())
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "", buffer.Bytes(), parser.AllErrors); err != nil {
+		t.Fatalf("Expected valid output, got '%T' with message: '%s'\n", err, err.Error())
+	}
+}
+
+func TestTestFuncVisitorAddFilterNameMatching(t *testing.T) {
+	src := `
+		package main
+
+		import "testing"
+
+		func TestFoo(t *testing.T) {}
+		func TestNetworkFoo(t *testing.T) {}
+		func TestNetworkBar(t *testing.T) {}
+	`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.AllErrors)
+	if err != nil {
+		t.Fatalf("Error parsing source code: `%s`", src)
+	}
+
+	tests := []struct {
+		re       *regexp.Regexp
+		expected []string
+	}{
+		{nil, []string{"TestFoo", "TestNetworkFoo", "TestNetworkBar"}},
+		{regexp.MustCompile("TestNetwork.*"), []string{"TestNetworkFoo", "TestNetworkBar"}},
+		{regexp.MustCompile("Bar$"), []string{"TestNetworkBar"}},
+	}
+
+	for _, test := range tests {
+		var actual []string
+		visitor := NewTestFuncVisitor(func(f *ast.FuncDecl) {
+			actual = append(actual, f.Name.Name)
+		})
+		visitor.AddFilter(NameMatching(test.re))
+
+		ast.Walk(visitor, file)
+
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Fatalf("Expected %v for %v, got %v\n", test.expected, test.re, actual)
+		}
+	}
+}
+
+func TestTestFuncVisitorAddFilterWithoutDirective(t *testing.T) {
+	src := `
+	package main
+
+	import "testing"
+
+	// TestFoo is flaky
+	//
+	//nolint
+	func TestFoo(t *testing.T) {
+		t.Log("foo")
+	}
+
+	//skip:keep reason
+	func TestBar(t *testing.T) {
+		t.Log("bar")
+	}
+
+	//nolint:errcheck
+	func TestBaz(t *testing.T) {
+		t.Log("baz")
+	}
+
+	// nolint is mentioned, //nolintx is no directive
+	//nolintx
+	func TestQux(t *testing.T) {
+		t.Log("qux")
+	}`
+
+	tests := []struct {
+		directives []string
+		skipped    []string
+	}{
+		{nil, []string{"TestFoo", "TestBar", "TestBaz", "TestQux"}},
+		{[]string{"nolint"}, []string{"TestBar", "TestQux"}},
+		{[]string{"//nolint", "skip:keep"}, []string{"TestQux"}},
+	}
+
+	for _, test := range tests {
+		fileSet := token.NewFileSet()
+		file, err := parser.ParseFile(fileSet, "", src, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("Error parsing source code: `%s`", src)
+		}
+		visitor := NewTestFuncVisitor(SkipTestVisitorAction)
+		visitor.AddFilter(WithoutDirective(test.directives...))
+		ast.Walk(visitor, file)
+
+		var skipped []string
+		for _, decl := range file.Decls {
+			if funcDecl, ok := decl.(*ast.FuncDecl); ok && isSkipped(funcDecl) {
+				skipped = append(skipped, funcDecl.Name.Name)
+			}
+		}
+		if !reflect.DeepEqual(test.skipped, skipped) {
+			t.Fatalf("Expected %v to be skipped with directives %v, got %v\n", test.skipped, test.directives, skipped)
+		}
+	}
+}
+
 func TestNewTestFuncVisitor(t *testing.T) {
 	var actual string
 	visitAction := func(*ast.FuncDecl) {
@@ -129,6 +836,7 @@ func TestSkipTestVisitorAction(t *testing.T) {
 
 	import "fmt"
 
+	// gotestskipper:skip
 	func TestFoo(t *testing.T) {
 		t.Skip()
 
@@ -143,6 +851,174 @@ func TestSkipTestVisitorAction(t *testing.T) {
 	}
 }
 
+func TestSkipBenchmarkVisitorAction(t *testing.T) {
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{
+			src:      "package main\n\nfunc BenchmarkFoo(b *testing.B) {\n\tfor i := 0; i < b.N; i++ {\n\t}\n}\n",
+			expected: "package main\n\n// gotestskipper:skip\nfunc BenchmarkFoo(b *testing.B) {\n\tb.Skip()\n\n\tfor i := 0; i < b.N; i++ {\n\t}\n}\n",
+		},
+		{
+			src:      "package main\n\nfunc BenchmarkFoo(*testing.B) {}\n",
+			expected: "package main\n\n// gotestskipper:skip\nfunc BenchmarkFoo(b *testing.B) {\n\tb.Skip()\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		fileSet, file, funcDecl := parseFuncDecl(t, test.src)
+
+		SkipBenchmarkVisitorAction(funcDecl)
+
+		var buffer bytes.Buffer
+		printer.Fprint(&buffer, fileSet, file)
+
+		expected := replacer.Replace(test.expected)
+		actual := replacer.Replace(buffer.String())
+
+		if expected != actual {
+			t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+		}
+	}
+}
+
+func TestSkipFuzzVisitorAction(t *testing.T) {
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{
+			src:      "package main\n\nfunc FuzzFoo(f *testing.F) {\n\tf.Fuzz(func(t *testing.T, s string) {})\n}\n",
+			expected: "package main\n\n// gotestskipper:skip\nfunc FuzzFoo(f *testing.F) {\n\tf.Skip()\n\n\tf.Fuzz(func(t *testing.T, s string) {})\n}\n",
+		},
+		{
+			src:      "package main\n\nfunc FuzzFoo(*testing.F) {}\n",
+			expected: "package main\n\n// gotestskipper:skip\nfunc FuzzFoo(f *testing.F) {\n\tf.Skip()\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		fileSet, file, funcDecl := parseFuncDecl(t, test.src)
+
+		SkipFuzzVisitorAction(funcDecl)
+
+		var buffer bytes.Buffer
+		printer.Fprint(&buffer, fileSet, file)
+
+		expected := replacer.Replace(test.expected)
+		actual := replacer.Replace(buffer.String())
+
+		if expected != actual {
+			t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+		}
+	}
+}
+
+func TestSkipRemovingParallelVisitorAction(t *testing.T) {
+	src := "package main\n\nfunc TestFoo(t *testing.T) {\n\tt.Parallel()\n\tt.Log(\"foo\")\n}\n"
+	fileSet, file, funcDecl := parseFuncDecl(t, src)
+
+	SkipRemovingParallelVisitorAction(funcDecl)
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	expected := replacer.Replace("package main\n\n// gotestskipper:skip\nfunc TestFoo(t *testing.T) {\n\tt.Skip()\n\n\tt.Log(\"foo\")\n}\n")
+	actual := replacer.Replace(buffer.String())
+	if expected != actual {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+	}
+}
+
+func TestSkipTestVisitorActionWithReason(t *testing.T) {
+	src := `
+	package main
+
+	import "testing"
+
+	func TestFoo(t *testing.T) {
+		t.Log("foo")
+	}`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.AllErrors)
+	if err != nil {
+		panic(err)
+	}
+
+	ast.Walk(NewTestFuncVisitor(SkipTestVisitorActionWithReason(`flaky on "CI"`)), file)
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+
+	expected := `
+	package main
+
+	import "testing"
+
+	// gotestskipper:skip
+	func TestFoo(t *testing.T) {
+		t.Skip("flaky on \"CI\"")
+
+		t.Log("foo")
+	}`
+	expected = replacer.Replace(expected)
+	actual := replacer.Replace(buffer.String())
+
+	if expected != actual {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+	}
+}
+
+func TestSkipInShortModeVisitorAction(t *testing.T) {
+	src := `
+	package main
+
+	import customtesting "testing"
+
+	func TestFoo(t *customtesting.T) {
+		t.Log("foo")
+	}`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.AllErrors)
+	if err != nil {
+		panic(err)
+	}
+
+	visitor := NewTestFuncVisitor(SkipInShortModeVisitorAction("slow"))
+	visitor.SetTestImport("customtesting")
+	ast.Walk(visitor, file)
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+
+	expected := `
+	package main
+
+	import customtesting "testing"
+
+	// gotestskipper:skip
+	func TestFoo(t *customtesting.T) {
+		if customtesting.Short() {
+			t.Skip("slow")
+		}
+
+		t.Log("foo")
+	}`
+	expected = replacer.Replace(expected)
+	actual := replacer.Replace(buffer.String())
+
+	if expected != actual {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+	}
+}
+
 func TestUnskipTestVisitorAction(t *testing.T) {
 	src := `
 	package main
@@ -248,15 +1124,110 @@ func TestUnskipTestVisitorAction(t *testing.T) {
 	}
 }
 
-type testVisitor struct{}
+type testVisitor struct{}
+
+func (v testVisitor) Visit(node ast.Node) ast.Visitor {
+	if f, ok := node.(*ast.FuncDecl); ok {
+		name := ast.NewIdent("TestBar")
+		f.Name = name
+		return nil
+	}
+	return v
+}
+
+func TestUnskipTestVisitorActionEmptyBody(t *testing.T) {
+	src := "package main\n\nfunc TestFoo(t *testing.T) {}\n"
+	fileSet, file, funcDecl := parseFuncDecl(t, src)
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Expected no panic, got '%v'\n", r)
+		}
+	}()
+	UnskipTestVisitorAction(funcDecl)
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fileSet, file)
+
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	expected := replacer.Replace(src)
+	actual := replacer.Replace(buffer.String())
+
+	if expected != actual {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+	}
+}
+
+func TestWalkFileUnmodified(t *testing.T) {
+	src := `package main
+import "testing"
+func TestFoo(t *testing.T)   {
+	t.Skip()
+}
+`
+	var buffer bytes.Buffer
+
+	err := walkSource("foo_test.go", []byte(src), &buffer, NewTestFuncVisitor(SkipTestVisitorAction))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if buffer.String() != src {
+		t.Fatalf("Expected the unmodified source \n`%s`\n, got \n`%s`\n", src, buffer.String())
+	}
+}
+
+func TestWalkFileGofmt(t *testing.T) {
+	src := `package main
+
+import (
+	"testing"
+	"fmt"
+)
+
+var (
+	a = 1
+	bcd  = 2
+)
+
+func TestFoo(t *testing.T)   {
+	s := struct{
+		a int
+		bcd string
+	}{a: 1, bcd: "foo"}
+	fmt.Println(s)
+}
+
+func TestBar(t *testing.T) {}
+`
+	var buffer bytes.Buffer
 
-func (v testVisitor) Visit(node ast.Node) ast.Visitor {
-	if f, ok := node.(*ast.FuncDecl); ok {
-		name := ast.NewIdent("TestBar")
-		f.Name = name
-		return nil
+	err := walkSource("foo_test.go", []byte(src), &buffer, NewTestFuncVisitor(SkipTestVisitorAction))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "foo_test.go", src, parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+	ast.Walk(NewTestFuncVisitor(SkipTestVisitorAction), file)
+	var expected bytes.Buffer
+	if err := format.Node(&expected, fileSet, file); err != nil {
+		panic(err)
+	}
+
+	if expected.String() != buffer.String() {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected.String(), buffer.String())
+	}
+	formatted, err := format.Source(buffer.Bytes())
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if string(formatted) != buffer.String() {
+		t.Fatalf("Expected the output to be gofmt clean, got \n`%s`\n", buffer.String())
 	}
-	return v
 }
 
 func TestWalkFile(t *testing.T) {
@@ -310,6 +1281,139 @@ func TestWalkFile(t *testing.T) {
 	}
 }
 
+func TestWalkReader(t *testing.T) {
+	src := `
+	package main
+
+	import "testing"
+
+	func TestFoo(t *testing.T) {
+		t.Log("foo")
+	}`
+
+	var buffer bytes.Buffer
+
+	err := WalkReader(strings.NewReader(src), "<standard input>", &buffer, NewTestFuncVisitor(SkipTestVisitorAction))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+
+	expected := `
+	package main
+
+	import "testing"
+
+	// gotestskipper:skip
+	func TestFoo(t *testing.T) {
+		t.Skip()
+		t.Log("foo")
+	}`
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	expected = replacer.Replace(expected)
+	actual := replacer.Replace(buffer.String())
+
+	if expected != actual {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+	}
+
+	// Invalid source
+	buffer.Reset()
+	err = WalkReader(strings.NewReader("package"), "<standard input>", &buffer, NewTestFuncVisitor(SkipTestVisitorAction))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if !strings.HasPrefix(err.Error(), "<standard input>:") {
+		t.Fatalf("Expected the error to name the input, got '%s'\n", err.Error())
+	}
+}
+
+func TestTransform(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{
+			name:     "matching test",
+			src:      "package main\n\nimport \"testing\"\n\n// TestFoo logs\nfunc TestFoo(t *testing.T) {\n\t// log foo\n\tt.Log(\"foo\")\n}\n",
+			expected: "package main\n\nimport \"testing\"\n\n// TestFoo logs\n// gotestskipper:skip\nfunc TestFoo(t *testing.T) {\n\tt.Skip()\n\n\t// log foo\n\tt.Log(\"foo\")\n}\n",
+		},
+		{
+			name:     "no matching test",
+			src:      "package main\n\n// foo  is left  as is\nfunc foo()   {}\n",
+			expected: "package main\n\n// foo  is left  as is\nfunc foo()   {}\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := Transform([]byte(test.src), SkipTestVisitorAction)
+
+			if err != nil {
+				t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+			}
+			if string(actual) != test.expected {
+				t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", test.expected, actual)
+			}
+		})
+	}
+
+	// Invalid source
+	actual, err := Transform([]byte("package main\n\nfunc TestFoo(t *testing.T) {"), SkipTestVisitorAction)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if actual != nil {
+		t.Fatalf("Expected no output, got \n`%s`\n", actual)
+	}
+}
+
+func TestPathWriterPaths(t *testing.T) {
+	pathWriter := make(PathWriter)
+	for _, path := range []string{"c_test.go", "a/b_test.go", "b_test.go", "a_test.go"} {
+		pathWriter.ReadWriterForPath(path)
+	}
+
+	paths := pathWriter.Paths()
+
+	expected := []string{"a/b_test.go", "a_test.go", "b_test.go", "c_test.go"}
+	if !reflect.DeepEqual(expected, paths) {
+		t.Fatalf("Expected paths %v, got %v\n", expected, paths)
+	}
+}
+
+func TestOnlyTestFileAndDirFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"foo.go", "foo_test.go", "foo_test", "foo_test.go.orig"} {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte("package main\n"), 0644); err != nil {
+			panic(err)
+		}
+	}
+	if err := os.Mkdir(path.Join(dir, "bar_test.go"), 0755); err != nil {
+		panic(err)
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		panic(err)
+	}
+
+	var actual []string
+	for _, info := range infos {
+		if onlyTestFileAndDirFilter(info) {
+			actual = append(actual, info.Name())
+		}
+	}
+
+	expected := []string{"foo_test.go"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("Expected %v to be accepted, got %v\n", expected, actual)
+	}
+}
+
 func TestWalkDir(t *testing.T) {
 	src := `
 	package main
@@ -331,8 +1435,8 @@ func TestWalkDir(t *testing.T) {
 	}`
 
 	tmpDir := "/tmp/gotestskipper"
-	tmpFilePath := "tempFile.go"
-	tmpFilePath2 := "tempFile2.go"
+	tmpFilePath := "tempFile_test.go"
+	tmpFilePath2 := "tempFile2_test.go"
 	err := os.Mkdir(tmpDir, 0777)
 	err = ioutil.WriteFile(path.Join(tmpDir, tmpFilePath), []byte(src), 0777)
 	if err != nil {
@@ -395,3 +1499,417 @@ func TestWalkDir(t *testing.T) {
 		t.Fatal("Expected an error")
 	}
 }
+
+func TestWalkDirExcludePaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a_test.go": "package main\n\nfunc TestFoo(t *testing.T) {}\n",
+		// the excluded file does not parse, so that parsing it would fail
+		"b_generated_test.go": "package\n",
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(src), 0644); err != nil {
+			panic(err)
+		}
+	}
+	visitor := NewTestFuncVisitor(SkipTestVisitorAction)
+	visitor.AddPathFilter(ExcludePaths("*_generated_test.go"))
+	pWriter := make(PathWriter)
+
+	err = WalkDir(dir, pWriter, visitor)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	expected := []string{path.Join(dir, "a_test.go")}
+	if !reflect.DeepEqual(expected, pWriter.Paths()) {
+		t.Fatalf("Expected paths %v, got %v\n", expected, pWriter.Paths())
+	}
+	content, err := ioutil.ReadFile(path.Join(dir, "b_generated_test.go"))
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if string(content) != files["b_generated_test.go"] {
+		t.Fatalf("Expected the excluded file to be unchanged, got \n`%s`\n", content)
+	}
+}
+
+func TestWalkDirMatchBuildContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a_test.go":         "package main\n\nfunc TestA(t *testing.T) {}\n",
+		"b_windows_test.go": "package main\n\nfunc TestB(t *testing.T) {}\n",
+		"c_test.go":         "//go:build integration\n\npackage main\n\nfunc TestC(t *testing.T) {}\n",
+		"d_test.go":         "//go:build !integration\n\npackage main\n\nfunc TestD(t *testing.T) {}\n",
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(src), 0644); err != nil {
+			panic(err)
+		}
+	}
+
+	tests := []struct {
+		goos     string
+		tags     []string
+		expected []string
+	}{
+		{"linux", nil, []string{"a_test.go", "d_test.go"}},
+		{"linux", []string{"integration"}, []string{"a_test.go", "c_test.go"}},
+		{"windows", nil, []string{"a_test.go", "b_windows_test.go", "d_test.go"}},
+	}
+
+	for _, test := range tests {
+		ctxt := build.Default
+		ctxt.GOOS = test.goos
+		ctxt.BuildTags = test.tags
+		visitor := NewTestFuncVisitor(SkipTestVisitorAction)
+		visitor.AddPathFilter(MatchBuildContext(&ctxt))
+		pathWriter := make(PathWriter)
+
+		err := WalkDir(dir, pathWriter, visitor)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		var actual []string
+		for _, filePath := range pathWriter.Paths() {
+			actual = append(actual, path.Base(filePath))
+		}
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Fatalf("Expected %v for GOOS %s and tags %v, got %v\n", test.expected, test.goos, test.tags, actual)
+		}
+	}
+}
+
+func TestWalkDirUnrelatedPackages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a_test.go": "package foo\n\nfunc TestFoo(t *testing.T) {}\n",
+		"b_test.go": "package bar_test\n\nfunc TestBar(t *testing.T) {}\n",
+		"c_test.go": "package main\n\nfunc TestBaz(t *testing.T) {}\n",
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(src), 0644); err != nil {
+			panic(err)
+		}
+	}
+
+	pathWriter := make(PathWriter)
+	err = WalkDir(dir, pathWriter, NewTestFuncVisitor(SkipTestVisitorAction))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	for name, src := range files {
+		reader, ok := pathWriter[path.Join(dir, name)]
+		if !ok {
+			t.Fatalf("Expected %s to be visited\n", name)
+		}
+		bytes, _ := ioutil.ReadAll(reader)
+		expected := replacer.Replace(strings.Replace(strings.Replace(src, "func", "// gotestskipper:skip\nfunc", 1), "{}", "{t.Skip()}", 1))
+		actual := replacer.Replace(string(bytes))
+		if expected != actual {
+			t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, actual)
+		}
+	}
+
+	// A broken file does not prevent visiting the preceding files
+	if err := ioutil.WriteFile(path.Join(dir, "b_test.go"), []byte("package bar_test\n\nfunc TestBar(\n"), 0644); err != nil {
+		panic(err)
+	}
+	pathWriter = make(PathWriter)
+	err = WalkDir(dir, pathWriter, NewTestFuncVisitor(SkipTestVisitorAction))
+
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if !strings.Contains(err.Error(), "b_test.go") {
+		t.Fatalf("Expected the error to name b_test.go, got '%s'\n", err.Error())
+	}
+	if _, ok := pathWriter[path.Join(dir, "a_test.go")]; !ok {
+		t.Fatal("Expected a_test.go to be visited")
+	}
+}
+
+func TestWalkDirContinuesAfterParseError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a_test.go": "package main\n\nfunc TestBar(\n",
+		"b_test.go": "package main\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {}\n",
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(src), 0644); err != nil {
+			panic(err)
+		}
+	}
+	visitor := NewTestFuncVisitor(SkipTestVisitorAction)
+	pathWriter := make(PathWriter)
+
+	err = WalkDir(dir, pathWriter, visitor)
+
+	if err == nil {
+		t.Fatalf("Expected an error, got nil\n")
+	}
+	if !strings.Contains(err.Error(), path.Join(dir, "a_test.go")) {
+		t.Fatalf("Expected the error to name the broken file, got '%s'\n", err.Error())
+	}
+	reader, ok := pathWriter[path.Join(dir, "b_test.go")]
+	if !ok {
+		t.Fatalf("Expected b_test.go to be rewritten\n")
+	}
+	bytes, _ := ioutil.ReadAll(reader)
+	if !strings.Contains(string(bytes), "t.Skip()") {
+		t.Fatalf("Expected b_test.go to be skipped, got \n`%s`\n", bytes)
+	}
+}
+
+func TestWalkDirCollectErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a_test.go": "package main\n\nfunc TestFoo(t *testing.T) {}\n",
+		"b_test.go": "package main\n\nfunc TestBar(\n",
+		"c_test.go": "package main\n\nfunc TestBaz(t *testing.T) {}\n",
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(src), 0644); err != nil {
+			panic(err)
+		}
+	}
+
+	tests := []struct {
+		collect  bool
+		expected []string
+	}{
+		{false, []string{"a_test.go"}},
+		{true, []string{"a_test.go", "c_test.go"}},
+	}
+
+	for _, test := range tests {
+		visitor := NewTestFuncVisitor(SkipTestVisitorAction)
+		visitor.SetCollectErrors(test.collect)
+		pathWriter := make(PathWriter)
+
+		err = WalkDir(dir, pathWriter, visitor)
+
+		if err == nil {
+			t.Fatalf("Expected an error collecting errors %t\n", test.collect)
+		}
+		if !strings.Contains(err.Error(), "b_test.go") {
+			t.Fatalf("Expected the error to name b_test.go, got '%s'\n", err.Error())
+		}
+		var actual []string
+		for _, name := range []string{"a_test.go", "b_test.go", "c_test.go"} {
+			if reader, ok := pathWriter[path.Join(dir, name)]; ok {
+				if bytes, _ := ioutil.ReadAll(reader); len(bytes) > 0 {
+					actual = append(actual, name)
+				}
+			}
+		}
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Fatalf("Expected %v to be visited collecting errors %t, got %v\n", test.expected, test.collect, actual)
+		}
+	}
+}
+
+func TestWalkDirRecoversVisitActionPanic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a_test.go": "package main\n\nfunc TestFoo(t *testing.T) {}\n",
+		"b_test.go": "package main\n\nfunc TestBar(t *testing.T) {}\n",
+		"c_test.go": "package main\n\nfunc TestBaz(t *testing.T) {}\n",
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(src), 0644); err != nil {
+			panic(err)
+		}
+	}
+	tests := []struct {
+		value   interface{}
+		message string
+	}{
+		{errors.New("TestBar cannot be skipped"), "b_test.go: TestBar cannot be skipped"},
+		{"boom", "b_test.go: visit action panicked: boom"},
+	}
+
+	for _, test := range tests {
+		visitAction := func(f *ast.FuncDecl) {
+			if f.Name.Name == "TestBar" {
+				panic(test.value)
+			}
+			SkipTestVisitorAction(f)
+		}
+		visitor := NewTestFuncVisitor(visitAction)
+		pathWriter := make(PathWriter)
+
+		err = WalkDir(dir, pathWriter, visitor)
+
+		if err == nil {
+			t.Fatalf("Expected an error for a panic with %v\n", test.value)
+		}
+		if !strings.Contains(err.Error(), test.message) {
+			t.Fatalf("Expected the error to contain '%s', got '%s'\n", test.message, err.Error())
+		}
+		expected := []string{path.Join(dir, "a_test.go"), path.Join(dir, "c_test.go")}
+		if !reflect.DeepEqual(expected, pathWriter.Paths()) {
+			t.Fatalf("Expected paths %v, got %v\n", expected, pathWriter.Paths())
+		}
+	}
+
+	// WalkFile
+	var buffer bytes.Buffer
+	err = WalkFile(path.Join(dir, "b_test.go"), &buffer, NewTestFuncVisitor(func(*ast.FuncDecl) {
+		panic("boom")
+	}))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if !strings.Contains(err.Error(), "b_test.go: visit action panicked: boom") {
+		t.Fatalf("Expected the error to name b_test.go, got '%s'\n", err.Error())
+	}
+}
+
+func TestWalkDirContextCancel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testskipper")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		src := "package main\n\nfunc Test" + strings.ToUpper(name) + "(t *testing.T) {}\n"
+		if err := ioutil.WriteFile(path.Join(dir, name+"_test.go"), []byte(src), 0644); err != nil {
+			panic(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	visitAction := func(f *ast.FuncDecl) {
+		SkipTestVisitorAction(f)
+		if f.Name.Name == "TestB" {
+			cancel()
+		}
+	}
+	visitor := NewTestFuncVisitor(visitAction)
+	report := &Report{}
+	visitor.SetReport(report)
+	pathWriter := make(PathWriter)
+
+	err = WalkDirContext(ctx, dir, pathWriter, visitor)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the error of the context, got '%v'\n", err)
+	}
+	expected := []string{path.Join(dir, "a_test.go"), path.Join(dir, "b_test.go")}
+	if !reflect.DeepEqual(expected, pathWriter.Paths()) {
+		t.Fatalf("Expected paths %v, got %v\n", expected, pathWriter.Paths())
+	}
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	for _, name := range []string{"A", "B"} {
+		content, _ := ioutil.ReadAll(pathWriter[path.Join(dir, strings.ToLower(name)+"_test.go")])
+		expected := "package main\n\n// gotestskipper:skip\nfunc Test" + name + "(t *testing.T) {\n\tt.Skip()\n}\n"
+		if replacer.Replace(string(content)) != replacer.Replace(expected) {
+			t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", expected, content)
+		}
+	}
+	if len(report.Changed()) != 2 {
+		t.Fatalf("Expected 2 changed functions to be reported, got %d\n", len(report.Changed()))
+	}
+
+	// An already cancelled walk does not touch any file
+	pathWriter = make(PathWriter)
+	err = WalkDirContext(ctx, dir, pathWriter, NewTestFuncVisitor(SkipTestVisitorAction))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the error of the context, got '%v'\n", err)
+	}
+	if len(pathWriter) != 0 {
+		t.Fatalf("Expected no files, got %v\n", pathWriter.Paths())
+	}
+}
+
+func TestSkipUnskipRoundTrip(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+// TestFoo does foo.
+func TestFoo(t *testing.T) {
+	t.Parallel()
+	t.Log("foo")
+}
+
+// TestBar does bar.
+//
+// It cleans up.
+func TestBar(t *testing.T) {
+	t.Cleanup(func() {}) // c1
+	// log
+	t.Log("bar")
+}
+
+func TestBaz(t *testing.T) {
+	t.Log("baz")
+
+	t.Log("baz")
+}
+`
+	tests := []struct {
+		name   string
+		skip   FuncVisitAction
+		unskip FuncVisitAction
+	}{
+		{"skip", SkipTestVisitorAction, UnskipTestVisitorAction},
+		{"tight", SkipTestVisitorActionWithOptions(SkipOptions{Tight: true}), UnskipTestVisitorAction},
+		{"reason", SkipTestVisitorActionWithReason("flaky"), UnskipTestVisitorAction},
+		{"after cleanup", SkipTestVisitorActionWithOptions(SkipOptions{AfterCleanup: true}), UnskipTestVisitorAction},
+		{"short mode", SkipInShortModeVisitorAction(""), UnskipAllTestVisitorAction},
+		{"env", SkipTestVisitorActionWithOptions(SkipOptions{EnvVar: "SKIP_FLAKY"}), UnskipIfEnvVisitorAction("SKIP_FLAKY")},
+	}
+
+	for _, test := range tests {
+		for _, surgical := range []bool{false, true} {
+			run := func(visitAction FuncVisitAction, src string) string {
+				visitor := NewTestFuncVisitor(visitAction)
+				visitor.SetSurgical(surgical)
+				var buffer bytes.Buffer
+				if err := walkSource("foo_test.go", []byte(src), &buffer, visitor); err != nil {
+					t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+				}
+				return buffer.String()
+			}
+
+			skipped := run(test.skip, src)
+			if strings.Count(skipped, "// "+SkipMarker) != 3 {
+				t.Fatalf("Expected %s with surgical %t to skip all tests, got \n`%s`\n", test.name, surgical, skipped)
+			}
+			unskipped := run(test.unskip, skipped)
+
+			if unskipped != src {
+				t.Fatalf("Expected %s with surgical %t to restore \n`%s`\n\n, got \n`%s`\n", test.name, surgical, src, unskipped)
+			}
+		}
+	}
+}
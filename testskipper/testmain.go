@@ -0,0 +1,128 @@
+package testskipper
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+// SetTestMain controls whether the visitor matches the TestMain function
+//
+//	func TestMain(m *testing.M)
+//
+// of a package instead of its test functions, see SkipTestMainVisitorAction
+func (f *testFuncVisitor) SetTestMain(testMain bool) {
+	f.testMain = testMain
+}
+
+// isTestMain reports whether funcDecl is the TestMain function of a package
+func (f testFuncVisitor) isTestMain(funcDecl *ast.FuncDecl) bool {
+	return funcDecl.Name.Name == "TestMain" && hasParamType(funcDecl, f.testingType("M"), false)
+}
+
+// ApplyTestMainExit inserts a
+//
+//	if os.Getenv("envVar") != "" {
+//		os.Exit(0)
+//	}
+//
+// statement as the first statement of decl, the TestMain function of a
+// package, so that no test of the package runs if the environment variable
+// is set. If decl already starts with such a statement for any environment
+// variable, decl is left unchanged.
+//
+// fileSet is only used to add position information to errors and may be
+// nil.
+func ApplyTestMainExit(fileSet *token.FileSet, decl *ast.FuncDecl, envVar string) error {
+	if envVar == "" {
+		return funcError(fileSet, decl, "needs an environment variable to guard the exit")
+	}
+	if len(decl.Body.List) > 0 && isExitGuard(decl.Body.List[0], "") {
+		return nil
+	}
+	pos := decl.Body.Lbrace
+	if len(decl.Body.List) > 0 {
+		pos = detachedPos
+	}
+	exit := &ast.CallExpr{
+		Fun:    &ast.SelectorExpr{X: &ast.Ident{NamePos: pos, Name: "os"}, Sel: &ast.Ident{NamePos: pos, Name: "Exit"}},
+		Lparen: pos,
+		Args:   []ast.Expr{&ast.BasicLit{ValuePos: pos, Kind: token.INT, Value: "0"}},
+		Rparen: pos,
+	}
+	insertStmt(decl, 0, &ast.IfStmt{
+		If:   pos,
+		Cond: envSetExpr(envVar, pos),
+		Body: &ast.BlockStmt{Lbrace: pos, List: []ast.Stmt{&ast.ExprStmt{X: exit}}, Rbrace: pos},
+	})
+	return nil
+}
+
+// ApplyUnskipTestMainExit removes the statement inserted by
+// ApplyTestMainExit for envVar, or for any environment variable if envVar
+// is empty, from decl
+func ApplyUnskipTestMainExit(fileSet *token.FileSet, decl *ast.FuncDecl, envVar string) error {
+	removeFirstStmt(&decl.Body.List, func(stmt ast.Stmt) bool {
+		return isExitGuard(stmt, envVar)
+	}, false)
+	return nil
+}
+
+// isExitGuard reports whether stmt is an if statement without else branch
+// checking os.Getenv("envVar") != "", or any environment variable if envVar
+// is empty, whose body only is an os.Exit(0) call
+func isExitGuard(stmt ast.Stmt, envVar string) bool {
+	ifStmt, ok := stmt.(*ast.IfStmt)
+	if !ok || ifStmt.Init != nil || ifStmt.Else != nil || len(ifStmt.Body.List) != 1 || !isEnvSetExpr(ifStmt.Cond, envVar) {
+		return false
+	}
+	exprStmt, ok := ifStmt.Body.List[0].(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := exprStmt.X.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return false
+	}
+	fun, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || fun.Sel.Name != "Exit" {
+		return false
+	}
+	if pkg, ok := fun.X.(*ast.Ident); !ok || pkg.Name != "os" {
+		return false
+	}
+	code, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || code.Kind != token.INT {
+		return false
+	}
+	value, err := strconv.ParseInt(code.Value, 0, 64)
+	return err == nil && value == 0
+}
+
+// SkipTestMainVisitorAction returns a visitAction which adds a
+//
+//	if os.Getenv("envVar") != "" {
+//		os.Exit(0)
+//	}
+//
+// statement to the TestMain function, see ApplyTestMainExit. The visitor
+// has to match TestMain functions, see SetTestMain. The import of os is
+// added to the file if missing.
+func SkipTestMainVisitorAction(envVar string) FuncVisitAction {
+	return func(f *ast.FuncDecl) {
+		if err := ApplyTestMainExit(nil, f, envVar); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// UnskipTestMainVisitorAction returns a visitAction which removes the
+// statement added by SkipTestMainVisitorAction for envVar from the TestMain
+// function
+func UnskipTestMainVisitorAction(envVar string) FuncVisitAction {
+	return func(f *ast.FuncDecl) {
+		if err := ApplyUnskipTestMainExit(nil, f, envVar); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package testskipper
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTestFuncVisitorSetTestMain(t *testing.T) {
+	src := `
+		package main
+
+		import "testing"
+
+		func TestMain(m *testing.M) {}
+		func TestFoo(t *testing.T) {}
+	`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.AllErrors)
+	if err != nil {
+		t.Fatalf("Error parsing source code: `%s`", src)
+	}
+
+	tests := []struct {
+		testMain bool
+		expected []string
+	}{
+		{false, []string{"TestFoo"}},
+		{true, []string{"TestMain"}},
+	}
+
+	for _, test := range tests {
+		var actual []string
+		visitAction := func(f *ast.FuncDecl) {
+			actual = append(actual, f.Name.Name)
+		}
+		visitor := NewTestFuncVisitor(visitAction)
+		visitor.SetTestMain(test.testMain)
+
+		ast.Walk(visitor, file)
+
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Fatalf("Expected %v for testMain %t, got %v\n", test.expected, test.testMain, actual)
+		}
+	}
+}
+
+func TestSkipTestMainVisitorAction(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+func TestMain(m *testing.M) {
+	m.Run()
+}
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+`
+	visitor := NewTestFuncVisitor(SkipTestMainVisitorAction("SKIP_PACKAGE"))
+	visitor.SetTestMain(true)
+	var buffer bytes.Buffer
+
+	err := walkSource("foo_test.go", []byte(src), &buffer, visitor)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	skipped := `package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("SKIP_PACKAGE") != "" {
+		os.Exit(0)
+	}
+
+	m.Run()
+}
+
+func TestFoo(t *testing.T) {
+	t.Log("foo")
+}
+`
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+	if replacer.Replace(skipped) != replacer.Replace(buffer.String()) {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", skipped, buffer.String())
+	}
+
+	// skipping is idempotent
+	skippedOnce := buffer.String()
+	buffer.Reset()
+	err = walkSource("foo_test.go", []byte(skippedOnce), &buffer, visitor)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if buffer.String() != skippedOnce {
+		t.Fatalf("Expected \n`%s`\n\n, got \n`%s`\n", skippedOnce, buffer.String())
+	}
+
+	// unskipping removes the exit and the import of os
+	visitor = NewTestFuncVisitor(UnskipTestMainVisitorAction("SKIP_PACKAGE"))
+	visitor.SetTestMain(true)
+	buffer.Reset()
+	err = walkSource("foo_test.go", []byte(skippedOnce), &buffer, visitor)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+	}
+	if strings.Contains(buffer.String(), "os") {
+		t.Fatalf("Expected the exit and the import of os to be removed, got \n`%s`\n", buffer.String())
+	}
+}
+
+func TestApplyTestMainExitWithoutEnvVar(t *testing.T) {
+	fileSet, _, funcDecl := parseFuncDecl(t, "package main\n\nfunc TestMain(m *testing.M) {\n\tm.Run()\n}\n")
+
+	err := ApplyTestMainExit(fileSet, funcDecl, "")
+
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	expectedMessage := "foo_test.go:3:1: TestMain needs an environment variable to guard the exit"
+	if err.Error() != expectedMessage {
+		t.Fatalf("Expected error message '%s', got '%s'\n", expectedMessage, err.Error())
+	}
+}
@@ -0,0 +1,102 @@
+package testskipper
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+)
+
+// SkipMarker is the text of the line comment marking a test function as
+// skipped by the tool:
+//
+//	// gotestskipper:skip
+//	func TestFoo(t *testing.T) {
+//		t.Skip()
+//	}
+const SkipMarker = "gotestskipper:skip"
+
+// Inconsistency describes a test function whose marker comment and skip
+// statement do not match
+type Inconsistency struct {
+	// Position is the position of the function declaration. It is only
+	// valid if a token.FileSet was provided.
+	Position token.Position
+	// Name is the name of the test function
+	Name string
+	// Message describes the inconsistency
+	Message string
+}
+
+func (i Inconsistency) String() string {
+	if i.Position.IsValid() {
+		return fmt.Sprintf("%s: %s: %s", i.Position, i.Name, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", i.Name, i.Message)
+}
+
+const (
+	markerWithoutSkip = "marker without skip"
+	skipWithoutMarker = "skip without marker"
+)
+
+// Validate cross-checks the marker comments of the test functions in file
+// against their skip statements. It reports functions with a marker which
+// have no skip among their leading statements, where ApplySkip places it,
+// as well as functions starting with a
+//
+//	t.Skip()
+//
+// statement as added by the tool which lack a marker. fileSet is used to
+// resolve the positions and may be nil.
+func Validate(fileSet *token.FileSet, file *ast.File) []Inconsistency {
+	var funcDecls []*ast.FuncDecl
+	visitor := NewTestFuncVisitor(func(funcDecl *ast.FuncDecl) {
+		funcDecls = append(funcDecls, funcDecl)
+	})
+	visitor.SetSkipHelpers(true)
+	ast.Walk(visitor, file)
+
+	var inconsistencies []Inconsistency
+	for _, funcDecl := range funcDecls {
+		var message string
+		switch marked := hasSkipMarker(funcDecl); {
+		case marked && !isLeadingSkipped(funcDecl):
+			message = markerWithoutSkip
+		case !marked && isToolSkipped(funcDecl):
+			message = skipWithoutMarker
+		default:
+			continue
+		}
+		inconsistency := Inconsistency{Name: funcDecl.Name.Name, Message: message}
+		if fileSet != nil {
+			inconsistency.Position = fileSet.Position(funcDecl.Pos())
+		}
+		inconsistencies = append(inconsistencies, inconsistency)
+	}
+	return inconsistencies
+}
+
+// hasSkipMarker reports whether the doc comment of funcDecl contains the
+// SkipMarker line comment
+func hasSkipMarker(funcDecl *ast.FuncDecl) bool {
+	if funcDecl.Doc == nil {
+		return false
+	}
+	for _, comment := range funcDecl.Doc.List {
+		if isSkipMarker(comment) {
+			return true
+		}
+	}
+	return false
+}
+
+// isToolSkipped reports whether the first statement of funcDecl is a bare
+// skip in the form added by ApplySkip without a reason. Skips with a reason
+// are taken to be added by hand.
+func isToolSkipped(funcDecl *ast.FuncDecl) bool {
+	target, err := testingTargetOf(nil, funcDecl)
+	if err != nil || len(funcDecl.Body.List) == 0 {
+		return false
+	}
+	return nodeString(funcDecl.Body.List[0]) == fmt.Sprintf("%s.Skip()", target)
+}
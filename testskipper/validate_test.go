@@ -0,0 +1,133 @@
+package testskipper
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+// gotestskipper:skip
+func TestMarkedAndSkipped(t *testing.T) {
+	t.Skip()
+	t.Log("foo")
+}
+
+// TestMarkerWithoutSkip lost its skip.
+//
+// gotestskipper:skip
+func TestMarkerWithoutSkip(t *testing.T) {
+	t.Log("foo")
+}
+
+func TestSkipWithoutMarker(t *testing.T) {
+	t.Skip()
+	t.Log("foo")
+}
+
+func TestSkippedByHand(t *testing.T) {
+	t.Skip("flaky")
+}
+
+// gotestskipper:skip
+func TestMarkedAndSkippedWithReason(t *testing.T) {
+	t.Skip("flaky")
+}
+
+func TestUnskipped(t *testing.T) {
+	t.Log("foo")
+}
+`
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "foo_test.go", src, parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+
+	inconsistencies := Validate(fileSet, file)
+
+	expected := []string{
+		"foo_test.go:14:1: TestMarkerWithoutSkip: marker without skip",
+		"foo_test.go:18:1: TestSkipWithoutMarker: skip without marker",
+	}
+	if len(inconsistencies) != len(expected) {
+		t.Fatalf("Expected %d inconsistencies, got %v\n", len(expected), inconsistencies)
+	}
+	for i, inconsistency := range inconsistencies {
+		if inconsistency.String() != expected[i] {
+			t.Fatalf("Expected '%s', got '%s'\n", expected[i], inconsistency)
+		}
+	}
+}
+
+func TestValidateAfterSkip(t *testing.T) {
+	src := `package main
+
+import "testing"
+
+// TestFoo logs
+func TestFoo(t *testing.T) {
+	t.Parallel()
+	t.Log("foo")
+}
+func TestIntegrationBar(t *testing.T) {
+	t.Log("bar")
+}
+
+func TestShortBaz(t *testing.T) {
+	t.Log("baz")
+}
+`
+	rules := []Rule{
+		{RegexpMatcher(regexp.MustCompile("Integration")), SkipTestVisitorActionWithReason("integration")},
+		{RegexpMatcher(regexp.MustCompile("Short")), SkipInShortModeVisitorAction("")},
+		{NameMatcher("TestFoo"), SkipTestVisitorAction},
+	}
+	replacer := strings.NewReplacer("\n", "", "\t", "", " ", "")
+
+	for _, surgical := range []bool{false, true} {
+		// the skipped functions are marked
+		visitor := NewTestFuncVisitor(RulesVisitAction(rules))
+		visitor.SetSurgical(surgical)
+		var buffer bytes.Buffer
+
+		err := walkSource("foo_test.go", []byte(src), &buffer, visitor)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		skipped := buffer.String()
+		if count := strings.Count(skipped, "// "+SkipMarker+"\n"); count != 3 {
+			t.Fatalf("Expected 3 markers with surgical %t, got %d in \n`%s`\n", surgical, count, skipped)
+		}
+		fileSet := token.NewFileSet()
+		file, err := parser.ParseFile(fileSet, "foo_test.go", skipped, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("Expected valid output, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		if inconsistencies := Validate(fileSet, file); len(inconsistencies) != 0 {
+			t.Fatalf("Expected no inconsistencies with surgical %t, got %v in \n`%s`\n", surgical, inconsistencies, skipped)
+		}
+
+		// unskipping removes the markers again
+		visitor = NewTestFuncVisitor(UnskipAllTestVisitorAction)
+		visitor.SetSurgical(surgical)
+		buffer.Reset()
+
+		err = walkSource("foo_test.go", []byte(skipped), &buffer, visitor)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got '%T' with message: '%s'\n", err, err.Error())
+		}
+		if replacer.Replace(buffer.String()) != replacer.Replace(src) {
+			t.Fatalf("Expected with surgical %t \n`%s`\n\n, got \n`%s`\n", surgical, src, buffer.String())
+		}
+	}
+}